@@ -0,0 +1,116 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/bevm/protocol"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// errNoKeyStore is returned when the node has no keystore to sign with.
+var errNoKeyStore = errors.New("no keystore backend")
+
+// KeyStore returns the keystore backend of am, or nil if it has none.
+func KeyStore(am *accounts.Manager) *keystore.KeyStore {
+	if am == nil {
+		return nil
+	}
+	backends := am.Backends(keystore.KeyStoreType)
+	if len(backends) == 0 {
+		return nil
+	}
+	return backends[0].(*keystore.KeyStore)
+}
+
+// accountSigner signs with a keystore account, unlocking it with passphrase if
+// not nil, otherwise it must be unlocked already.
+func accountSigner(ks *keystore.KeyStore, account accounts.Account, passphrase *string) protocol.HashSigner {
+	return func(hash []byte) ([]byte, error) {
+		if passphrase != nil {
+			return ks.SignHashWithPassphrase(account, *passphrase, hash)
+		}
+		return ks.SignHash(account, hash)
+	}
+}
+
+// AccountPubKey recovers the public key of a keystore account, which the key
+// files do not store, by signing with it. The account is unlocked with
+// passphrase if not nil, otherwise it must be unlocked already.
+func AccountPubKey(ks *keystore.KeyStore, account accounts.Account, passphrase *string) (*btcec.PublicKey, error) {
+	return protocol.SignerPubKey(accountSigner(ks, account, passphrase))
+}
+
+// AccountInfo returns the bevm forms of the key of a keystore account on the
+// given BTC network: the EVM script address invocations are sent from, the
+// P2WSH address funding it, the EVM account of the script and the unified EVM
+// account, which is the keystore address.
+func AccountInfo(ks *keystore.KeyStore, account accounts.Account, passphrase *string, net *chaincfg.Params) (*protocol.AddressInfo, error) {
+	pub, err := AccountPubKey(ks, account, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return protocol.ConvertAddress(hex.EncodeToString(pub.SerializeCompressed()), net)
+}
+
+// SignInvocationArgs represents the arguments to sign the input of a BTC
+// transaction spending the EVM call script of a keystore account.
+type SignInvocationArgs struct {
+	From   common.Address `json:"from"`   // keystore account owning the EVM script
+	To     common.Address `json:"to"`     // contract called by the invocation
+	Data   hexutil.Bytes  `json:"data"`   // calldata of the invocation
+	Tx     hexutil.Bytes  `json:"tx"`     // BTC transaction in wire format
+	Input  hexutil.Uint   `json:"input"`  // index of the input spending the EVM script
+	Amount hexutil.Uint64 `json:"amount"` // value in satoshi of the output spent by the input
+}
+
+// SignInvocationResult is the witness of a signed invocation along with the
+// transaction it was set in.
+type SignInvocationResult struct {
+	Witness []hexutil.Bytes `json:"witness"`
+	Tx      hexutil.Bytes   `json:"tx"`
+}
+
+// SignInvocation builds the witness of the args.Input input of args.Tx spending
+// the EVM call script of the account, which invokes args.To with args.Data. The
+// input is signed with SIGHASH_ALL according to BIP-143.
+func SignInvocation(ks *keystore.KeyStore, passphrase *string, args *SignInvocationArgs) (*SignInvocationResult, error) {
+	account, err := ks.Find(accounts.Account{Address: args.From})
+	if err != nil {
+		return nil, err
+	}
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(args.Tx)); err != nil {
+		return nil, fmt.Errorf("invalid btc transaction: %v", err)
+	}
+	idx := int(args.Input)
+	if idx >= len(tx.TxIn) {
+		return nil, fmt.Errorf("input %d out of range, transaction has %d inputs", idx, len(tx.TxIn))
+	}
+	sign := accountSigner(ks, account, passphrase)
+	witness, err := protocol.EVMWitnessSignWith(&tx, idx, int64(args.Amount), sign, &protocol.EVMCall{To: args.To, Data: args.Data})
+	if err != nil {
+		return nil, err
+	}
+	tx.TxIn[idx].Witness = witness
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	result := &SignInvocationResult{Tx: buf.Bytes()}
+	for _, item := range witness {
+		result.Witness = append(result.Witness, item)
+	}
+	return result, nil
+}
@@ -0,0 +1,120 @@
+package bevm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/bevm/protocol"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestAccountInfo(t *testing.T) {
+	ks := keystore.NewKeyStore(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	key, _ := crypto.GenerateKey()
+	account, err := ks.ImportECDSA(key, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pass, wrong := "pass", "wrong"
+	if _, err := AccountInfo(ks, account, nil, &chaincfg.RegressionNetParams); err != keystore.ErrLocked {
+		t.Fatalf("error mismatch: have %v, want %v", err, keystore.ErrLocked)
+	}
+	if _, err := AccountInfo(ks, account, &wrong, &chaincfg.RegressionNetParams); err == nil {
+		t.Fatal("derived addresses with a wrong passphrase")
+	}
+	info, err := AccountInfo(ks, account, &pass, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("failed to derive addresses: %v", err)
+	}
+	pub, _ := btcec.ParsePubKey(crypto.FromECDSAPub(&key.PublicKey))
+	want := protocol.NewAddressEVMFromPubKey(pub, &chaincfg.RegressionNetParams)
+	if info.EVMScriptAddress != want.EncodeAddress() || info.P2WSHAddress != want.Compat().EncodeAddress() {
+		t.Fatalf("btc address mismatch: have %s/%s, want %s/%s", info.EVMScriptAddress, info.P2WSHAddress, want.EncodeAddress(), want.Compat().EncodeAddress())
+	}
+	if *info.EVMAddress != want.EvmAddress() || *info.UnifiedAddress != account.Address {
+		t.Fatalf("evm address mismatch: have %x/%x, want %x/%x", *info.EVMAddress, *info.UnifiedAddress, want.EvmAddress(), account.Address)
+	}
+	// Unlocked accounts need no passphrase.
+	if err := ks.Unlock(account, pass); err != nil {
+		t.Fatal(err)
+	}
+	if unlocked, err := AccountInfo(ks, account, nil, &chaincfg.RegressionNetParams); err != nil || unlocked.EVMScriptAddress != info.EVMScriptAddress {
+		t.Fatalf("unlocked account mismatch: %v, %v", unlocked, err)
+	}
+}
+
+func TestSignInvocation(t *testing.T) {
+	ks := keystore.NewKeyStore(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	key, _ := crypto.GenerateKey()
+	account, err := ks.ImportECDSA(key, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := btcec.ParsePubKey(crypto.FromECDSAPub(&key.PublicKey))
+	scriptHash := sha256.Sum256(protocol.NewEVMScriptFromPubKey(pub))
+	pkScript, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(scriptHash[:]).Script()
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{2}, 1), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(9e4, []byte{txscript.OP_TRUE}))
+	var raw bytes.Buffer
+	tx.Serialize(&raw)
+
+	pass := "pass"
+	args := &SignInvocationArgs{
+		From:   account.Address,
+		To:     common.HexToAddress("0xdead"),
+		Data:   []byte{0x01, 0x02, 0x03},
+		Tx:     raw.Bytes(),
+		Input:  1,
+		Amount: 1e5,
+	}
+	if _, err := SignInvocation(ks, nil, args); err != keystore.ErrLocked {
+		t.Fatalf("error mismatch: have %v, want %v", err, keystore.ErrLocked)
+	}
+	result, err := SignInvocation(ks, &pass, args)
+	if err != nil {
+		t.Fatalf("failed to sign invocation: %v", err)
+	}
+	var signed wire.MsgTx
+	if err := signed.Deserialize(bytes.NewReader(result.Tx)); err != nil {
+		t.Fatal(err)
+	}
+	if len(signed.TxIn[0].Witness) != 0 || len(signed.TxIn[1].Witness) != len(result.Witness) {
+		t.Fatalf("witness set on the wrong input")
+	}
+	// The witness satisfies the EVM script and carries the call.
+	fetcher := txscript.NewCannedPrevOutputFetcher(pkScript, 1e5)
+	vm, err := txscript.NewEngine(pkScript, &signed, 1, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(&signed, fetcher), 1e5, fetcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("invalid witness: %v", err)
+	}
+	invocations := protocol.ExtractEVMWitness(&signed, fetcher)
+	if len(invocations) != 1 {
+		t.Fatalf("invocation count mismatch: have %d, want 1", len(invocations))
+	}
+	call, ok := invocations[0].(*protocol.EVMCall)
+	if !ok || call.To != args.To || !bytes.Equal(call.Data, args.Data) {
+		t.Fatalf("invocation mismatch: have %+v", invocations[0])
+	}
+	if call.From != protocol.NewAddressEVMFromPubKey(pub, &chaincfg.MainNetParams).EvmAddress() {
+		t.Fatalf("sender mismatch: have %x", call.From)
+	}
+
+	args.Input = 2
+	if _, err := SignInvocation(ks, &pass, args); err == nil {
+		t.Fatal("signed input out of range")
+	}
+}
@@ -18,7 +18,6 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
-	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/miner"
@@ -31,7 +30,7 @@ type EthAPIBackend struct {
 	extRPCEnabled       bool
 	allowUnprotectedTxs bool
 	eth                 *Ethereum
-	gpo                 *gasprice.Oracle
+	gpo                 *FeeOracle
 }
 
 // ChainConfig returns the active chain configuration.
@@ -232,8 +231,9 @@ func (b *EthAPIBackend) GetPoolTransactions() (types.Transactions, error) {
 	return txs, nil
 }
 
+// GetPoolTransaction returns nil, bevm has no transaction pool.
 func (b *EthAPIBackend) GetPoolTransaction(hash common.Hash) *types.Transaction {
-	panic("not support")
+	return nil
 }
 
 func (b *EthAPIBackend) GetTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
@@ -278,7 +278,7 @@ func (b *EthAPIBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.S
 }
 
 func (b *EthAPIBackend) SyncProgress() ethereum.SyncProgress {
-	return b.eth.Downloader().Progress()
+	return b.eth.miner.sync.Progress()
 }
 
 func (b *EthAPIBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
@@ -368,3 +368,22 @@ func (b *EthAPIBackend) ReadStorageProofAtBlock(ctx context.Context, blockNrOrHa
 	}
 	return mock.GetReadStorageKeyProof()
 }
+
+// StatelessBlockAt returns the given block along with the header of its parent
+// and the witness needed to verify it without the state. The state of the
+// parent must be available.
+func (b *EthAPIBackend) StatelessBlockAt(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*StatelessBlock, error) {
+	block, err := b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errors.New("block not found")
+	}
+	witness, err := BuildWitness(b.eth.blockchain, block)
+	if err != nil {
+		return nil, err
+	}
+	parent := b.eth.blockchain.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	return &StatelessBlock{Parent: parent, Block: block, Witness: witness}, nil
+}
@@ -0,0 +1,310 @@
+package bevm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/bevm/protocol"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// PublicBevmAPI provides the bevm specific APIs, exposed over the bevm
+// namespace.
+type PublicBevmAPI struct {
+	eth *Ethereum
+}
+
+// NewPublicBevmAPI creates a new bevm API instance.
+func NewPublicBevmAPI(eth *Ethereum) *PublicBevmAPI {
+	return &PublicBevmAPI{eth: eth}
+}
+
+// ConvertAddress converts an EVM P2WSH address, plain P2WSH address, 0x EVM
+// address or hex encoded public key into all of its bevm forms, and reports the
+// network and script class of the address. Public keys are resolved on the
+// given network, which defaults to the network of the chain or mainnet.
+func (api *PublicBevmAPI) ConvertAddress(address string, network *string) (*protocol.AddressInfo, error) {
+	net := api.eth.btcNet()
+	if network != nil {
+		var err error
+		if net, err = protocol.NetParamsByName(*network); err != nil {
+			return nil, err
+		}
+	}
+	return protocol.ConvertAddress(address, net)
+}
+
+// FeeHistory returns the fee rates in sat/vB paid at the given percentiles by
+// the BTC transactions carrying the invocations of each of the blockCount blocks
+// up to lastBlock.
+func (api *PublicBevmAPI) FeeHistory(ctx context.Context, blockCount rpc.DecimalOrHex, lastBlock rpc.BlockNumber, percentiles []float64) (*BtcFeeHistory, error) {
+	return api.eth.APIBackend.gpo.BtcFeeHistory(ctx, int(blockCount), lastBlock, percentiles)
+}
+
+// LatestCheckpoint returns the latest checkpoint of the chain found on the BTC
+// chain, and whether it matches the local chain.
+func (api *PublicBevmAPI) LatestCheckpoint() *CheckpointRecord {
+	return ReadLatestCheckpoint(api.eth.ChainDb())
+}
+
+// SyncStatus returns the progress of translating the BTC chain.
+func (api *PublicBevmAPI) SyncStatus() SyncStatus {
+	return api.eth.miner.sync.Status()
+}
+
+// GetWitness returns the RLP encoded StatelessBlock of the given block: the
+// block, the header of its parent and the witness needed to verify its state
+// root without the state. The state of the parent must be available.
+func (api *PublicBevmAPI) GetWitness(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	bundle, err := api.eth.APIBackend.StatelessBlockAt(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(bundle)
+}
+
+// GetInvocations returns the EVM invocations found in the BTC block the given
+// block was translated from, rejected ones included. The BTC block is fetched
+// again from the BTC node, which must still have it in its best chain.
+func (api *PublicBevmAPI) GetInvocations(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]*RPCInvocation, error) {
+	header, err := api.eth.APIBackend.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, errors.New("block not found")
+	}
+	result := make([]*RPCInvocation, 0)
+	if header.Number.Sign() == 0 {
+		return result, nil // the genesis is not translated
+	}
+	bt := api.eth.miner.bt
+	bblock, prevOuts, err := bt.FetchBlock(header.Number.Int64())
+	if err != nil {
+		return nil, err
+	}
+	if BtcHashToEvmHash(bblock.BlockHash()) != header.UncleHash {
+		return nil, fmt.Errorf("btc block %x of block %d is not in the best chain of the btc node", header.UncleHash, header.Number)
+	}
+	signer := types.LatestSigner(api.eth.blockchain.Config())
+	for _, ev := range bt.extractInvocations(bblock, prevOuts) {
+		ev.BlockHash, ev.BlockNumber = header.Hash(), header.Number.Uint64()
+		result = append(result, newRPCInvocation(ev, signer))
+	}
+	return result, nil
+}
+
+// GetMessageProof returns the inclusion proof of the cross layer message with
+// the given index in the message MMR of the given block, to be relayed to the
+// other layer.
+func (api *PublicBevmAPI) GetMessageProof(ctx context.Context, messageIndex hexutil.Uint64, blockNumber rpc.BlockNumber) (*MessageProof, error) {
+	header, err := api.eth.APIBackend.HeaderByNumber(ctx, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, errors.New("block not found")
+	}
+	return messageProof(api.eth.ChainDb(), header, uint64(messageIndex))
+}
+
+// PrivateBevmAPI provides the bevm specific APIs using the keys of the node.
+// They are exposed over the bevmadmin namespace, kept apart from the public
+// bevm one so that serving bevm over HTTP or WS does not expose the keystore.
+type PrivateBevmAPI struct {
+	eth *Ethereum
+}
+
+// NewPrivateBevmAPI creates a new private bevm API instance.
+func NewPrivateBevmAPI(eth *Ethereum) *PrivateBevmAPI {
+	return &PrivateBevmAPI{eth: eth}
+}
+
+// AccountInfo returns the BTC EVM script address, P2WSH funding address and EVM
+// addresses of the key of a keystore account on the network of the chain. The
+// key files do not store the public key, so the account must be unlocked or
+// its passphrase given.
+func (api *PrivateBevmAPI) AccountInfo(address common.Address, passphrase *string) (*protocol.AddressInfo, error) {
+	ks := KeyStore(api.eth.AccountManager())
+	if ks == nil {
+		return nil, errNoKeyStore
+	}
+	account, err := ks.Find(accounts.Account{Address: address})
+	if err != nil {
+		return nil, err
+	}
+	return AccountInfo(ks, account, passphrase, api.eth.btcNet())
+}
+
+// SignInvocation signs the input of a BTC transaction spending the EVM call
+// script of a keystore account and returns its witness, which invokes args.To
+// with args.Data. The account must be unlocked or its passphrase given.
+func (api *PrivateBevmAPI) SignInvocation(args SignInvocationArgs, passphrase *string) (*SignInvocationResult, error) {
+	ks := KeyStore(api.eth.AccountManager())
+	if ks == nil {
+		return nil, errNoKeyStore
+	}
+	return SignInvocation(ks, passphrase, &args)
+}
+
+// RPCBtcBlock is the notification of a translated BTC block.
+type RPCBtcBlock struct {
+	BtcHash      common.Hash    `json:"btcHash"` // in the display byte order of BTC, like the uncle hash of the block
+	Number       hexutil.Uint64 `json:"number"`
+	Hash         common.Hash    `json:"hash"`
+	Transactions hexutil.Uint64 `json:"transactions"` // number of accepted invocations
+}
+
+// RPCInvocation is the notification of an EVM invocation extracted from a
+// translated BTC block.
+type RPCInvocation struct {
+	BlockHash   common.Hash     `json:"blockHash"`
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+	RefHash     common.Hash     `json:"refHash"`
+	RefIndex    hexutil.Uint64  `json:"refIndex"`
+	Hash        *common.Hash    `json:"hash,omitempty"` // hash of the transaction, nil if rejected
+	From        *common.Address `json:"from,omitempty"`
+	To          *common.Address `json:"to,omitempty"`
+	Reason      string          `json:"reason,omitempty"` // disabled, invalid, unprotected, signature, unknown or other
+	Error       string          `json:"error,omitempty"`
+}
+
+// RPCBlockRange is an inclusive range of block numbers.
+type RPCBlockRange struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"`
+}
+
+// RPCReorg is the notification of a BTC reorg. Added is the range of the new
+// BTC branch, whose blocks are notified on the btcBlocks subscription after it.
+type RPCReorg struct {
+	Removed RPCBlockRange  `json:"removed"`
+	Added   *RPCBlockRange `json:"added"` // nil if the new branch is not longer than the fork point
+}
+
+func newRPCInvocation(ev *InvocationEvent, signer types.Signer) *RPCInvocation {
+	result := &RPCInvocation{
+		BlockHash:   ev.BlockHash,
+		BlockNumber: hexutil.Uint64(ev.BlockNumber),
+		RefHash:     ev.RefHash,
+		RefIndex:    hexutil.Uint64(ev.Index),
+	}
+	if ev.Tx != nil {
+		hash := ev.Tx.Hash()
+		result.Hash, result.To = &hash, ev.Tx.To()
+		if from, err := types.Sender(signer, ev.Tx); err == nil {
+			result.From = &from
+		}
+	}
+	if ev.Err != nil {
+		result.Reason, result.Error = rejectReason(ev.Err), ev.Err.Error()
+	}
+	return result
+}
+
+func newRPCReorg(ev *ReorgEvent) *RPCReorg {
+	result := &RPCReorg{Removed: RPCBlockRange{From: hexutil.Uint64(ev.Fork + 1), To: hexutil.Uint64(ev.OldHead)}}
+	if ev.NewHead > ev.Fork {
+		result.Added = &RPCBlockRange{From: hexutil.Uint64(ev.Fork + 1), To: hexutil.Uint64(ev.NewHead)}
+	}
+	return result
+}
+
+// BtcBlocks sends a notification each time a BTC block was translated and its
+// bevm block appended to the chain.
+func (api *PublicBevmAPI) BtcBlocks(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	blocks := make(chan BtcBlockEvent, eventChanSize)
+	blocksSub := api.eth.miner.SubscribeBtcBlockEvent(blocks)
+	go func() {
+		defer blocksSub.Unsubscribe()
+		for {
+			select {
+			case ev := <-blocks:
+				notifier.Notify(rpcSub.ID, &RPCBtcBlock{
+					BtcHash:      BtcHashToEvmHash(ev.BtcHash),
+					Number:       hexutil.Uint64(ev.Block.NumberU64()),
+					Hash:         ev.Block.Hash(),
+					Transactions: hexutil.Uint64(len(ev.Block.Transactions())),
+				})
+			case <-blocksSub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// Invocations sends a notification for each EVM invocation extracted from a
+// translated BTC block, including the rejected ones along with the reason.
+func (api *PublicBevmAPI) Invocations(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	signer := types.LatestSigner(api.eth.blockchain.Config())
+	invocations := make(chan InvocationEvent, eventChanSize)
+	invocationsSub := api.eth.miner.SubscribeInvocationEvent(invocations)
+	go func() {
+		defer invocationsSub.Unsubscribe()
+		for {
+			select {
+			case ev := <-invocations:
+				notifier.Notify(rpcSub.ID, newRPCInvocation(&ev, signer))
+			case <-invocationsSub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// Reorgs sends a notification each time a BTC reorg removed translated blocks,
+// with the range of the removed blocks and of the new branch.
+func (api *PublicBevmAPI) Reorgs(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	reorgs := make(chan ReorgEvent, eventChanSize)
+	reorgsSub := api.eth.miner.SubscribeReorgEvent(reorgs)
+	go func() {
+		defer reorgsSub.Unsubscribe()
+		for {
+			select {
+			case ev := <-reorgs:
+				notifier.Notify(rpcSub.ID, newRPCReorg(&ev))
+			case <-reorgsSub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
@@ -19,7 +20,6 @@ import (
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/eth/filters"
-	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
@@ -44,8 +44,9 @@ type Ethereum struct {
 	// DB interfaces
 	chainDb ethdb.Database // Block chain database
 
-	eventMux *event.TypeMux
-	engine   consensus.Engine
+	eventMux       *event.TypeMux
+	engine         consensus.Engine
+	accountManager *accounts.Manager
 
 	bloomRequests     chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
@@ -61,6 +62,7 @@ type Ethereum struct {
 	netRPCService *ethapi.PublicNetAPI
 
 	p2pServer *p2p.Server
+	handler   *peerHandler // serves and tracks the bevm/1 peers
 
 	rollupBackend *rollup.RollupBackend
 	lock          sync.RWMutex // Protects the variadic fields (e.g. gas price and etherbase)
@@ -99,11 +101,12 @@ func New(stack *node.Node, config *ethconfig.Config, rpcConfig *BtcRpcConfig) (*
 		log.Error("Failed to recover state", "error", err)
 	}
 	// TODO: replace to new engine
-	engine := layer2.New(&params.Layer2InstantConfig{})
+	engine := CreateConsensusEngine(chainConfig)
 	eth := &Ethereum{
 		config:            config,
 		chainDb:           chainDb,
 		eventMux:          stack.EventMux(),
+		accountManager:    stack.AccountManager(),
 		engine:            engine,
 		closeBloomHandler: make(chan struct{}),
 		networkID:         config.NetworkId,
@@ -159,7 +162,13 @@ func New(stack *node.Node, config *ethconfig.Config, rpcConfig *BtcRpcConfig) (*
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
 
-	eth.miner = NewMiner(eth, rpcConfig)
+	if eth.miner, err = NewMiner(eth, rpcConfig); err != nil {
+		return nil, err
+	}
+	eth.handler = newPeerHandler(eth.blockchain, config.NetworkId)
+	if rpcConfig.PeerSync {
+		eth.miner.peers = eth.handler.peers
+	}
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
@@ -180,10 +189,12 @@ func New(stack *node.Node, config *ethconfig.Config, rpcConfig *BtcRpcConfig) (*
 	if gpoParams.Default == nil {
 		gpoParams.Default = config.Miner.GasPrice
 	}
-	eth.APIBackend.gpo = gasprice.NewOracle(eth.APIBackend, gpoParams)
+	eth.APIBackend.gpo = NewFeeOracle(eth.APIBackend, eth.miner.bt.Client, gpoParams)
 
 	// Register the backend on the node
 	stack.RegisterAPIs(eth.APIs())
+	stack.RegisterProtocols(eth.handler.Protocols())
+	stack.RegisterHandler("bevm health", "/health", NewHealthHandler(eth.miner, rpcConfig.HealthMaxLag))
 	stack.RegisterLifecycle(eth)
 
 	// Check for unclean shutdown
@@ -202,6 +213,12 @@ func New(stack *node.Node, config *ethconfig.Config, rpcConfig *BtcRpcConfig) (*
 	return eth, nil
 }
 
+// CreateConsensusEngine creates the consensus engine of a bevm chain. Every
+// bevm chain runs the layer2 engine, whatever the stored config says.
+func CreateConsensusEngine(config *params.ChainConfig) *layer2.Layer2Instant {
+	return layer2.NewForChain(config)
+}
+
 // APIs return the collection of RPC services the ethereum package offers.
 // NOTE, some of these services probably need to be moved to somewhere else.
 func (s *Ethereum) APIs() []rpc.API {
@@ -217,6 +234,15 @@ func (s *Ethereum) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   filters.NewPublicFilterAPI(s.APIBackend, false, 5*time.Minute),
 			Public:    true,
+		}, {
+			Namespace: "bevm",
+			Version:   "1.0",
+			Service:   NewPublicBevmAPI(s),
+			Public:    true,
+		}, {
+			Namespace: "bevmadmin",
+			Version:   "1.0",
+			Service:   NewPrivateBevmAPI(s),
 		}, {
 			Namespace: "admin",
 			Version:   "1.0",
@@ -271,7 +297,7 @@ func (s *Ethereum) shouldPreserve(block *types.Block) bool {
 	return false
 }
 
-func (s *Ethereum) AccountManager() *accounts.Manager  { return nil }
+func (s *Ethereum) AccountManager() *accounts.Manager  { return s.accountManager }
 func (s *Ethereum) BlockChain() *core.BlockChain       { return s.blockchain }
 func (s *Ethereum) TxPool() *core.TxPool               { return nil }
 func (s *Ethereum) EventMux() *event.TypeMux           { return s.eventMux }
@@ -283,6 +309,14 @@ func (s *Ethereum) Synced() bool                       { panic("not supported")
 func (s *Ethereum) ArchiveMode() bool                  { return s.config.NoPruning }
 func (s *Ethereum) BloomIndexer() *core.ChainIndexer   { return s.bloomIndexer }
 
+// btcNet returns the BTC network of the chain, or mainnet if unrecorded.
+func (s *Ethereum) btcNet() *chaincfg.Params {
+	if s.miner != nil && s.miner.net != nil {
+		return s.miner.net
+	}
+	return &chaincfg.MainNetParams
+}
+
 // Start implements node.Lifecycle, starting all internal goroutines needed by the
 // Ethereum protocol implementation.
 func (s *Ethereum) Start() error {
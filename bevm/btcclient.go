@@ -0,0 +1,336 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	btcRetryAttempts = 5                      // rounds over all endpoints before a request fails
+	btcRetryBackoff  = 500 * time.Millisecond // delay before the second round, doubled per round
+	btcMaxBackoff    = 8 * time.Second
+	btcDownTime      = 30 * time.Second // time a failed endpoint is skipped while others are healthy
+)
+
+// errNoWallet is returned by endpoints without the wallet rpc. It is reported as
+// a node error, so that it is not failed over.
+var errNoWallet = btcjson.NewRPCError(btcjson.ErrRPCMethodNotFound.Code, "btc client has no wallet rpc")
+
+// btcEndpoint is a single BTC node behind a MultiClient.
+type btcEndpoint struct {
+	host string
+	dial func() (BtcClient, error) // creates the client, re-reading credentials
+
+	lock      sync.Mutex
+	client    BtcClient // nil until dialed and after a failure
+	failures  int       // consecutive failures
+	downUntil time.Time
+}
+
+func (ep *btcEndpoint) get() (BtcClient, error) {
+	ep.lock.Lock()
+	defer ep.lock.Unlock()
+	if ep.client == nil {
+		client, err := ep.dial()
+		if err != nil {
+			return nil, err
+		}
+		ep.client = client
+	}
+	return ep.client, nil
+}
+
+func (ep *btcEndpoint) healthy() bool {
+	ep.lock.Lock()
+	defer ep.lock.Unlock()
+	return time.Now().After(ep.downUntil)
+}
+
+func (ep *btcEndpoint) succeed() {
+	ep.lock.Lock()
+	defer ep.lock.Unlock()
+	if ep.failures > 0 {
+		log.Info("BTC endpoint recovered", "host", ep.host)
+	}
+	ep.failures = 0
+	ep.downUntil = time.Time{}
+}
+
+// fail marks the endpoint down and drops its client, so that it is dialed again
+// with fresh credentials, e.g. a rotated cookie, on its next use.
+func (ep *btcEndpoint) fail(err error) {
+	ep.lock.Lock()
+	defer ep.lock.Unlock()
+	if client, ok := ep.client.(interface{ Shutdown() }); ok {
+		client.Shutdown() // stops the request handler of HTTP POST mode clients
+	}
+	ep.client = nil
+	ep.failures++
+	ep.downUntil = time.Now().Add(btcDownTime)
+	log.Warn("BTC endpoint failed", "host", ep.host, "failures", ep.failures, "err", err)
+}
+
+// MultiClient is a BtcClient spreading requests over several BTC nodes. Requests
+// go to the first healthy endpoint in configuration order and fail over to the
+// next one on transport errors, retrying with exponential backoff once every
+// endpoint failed. Errors reported by a node itself, e.g. an unknown block, are
+// returned as is. Block hashes are cross checked between the healthy endpoints,
+// so that the chain is never built on a block only some of them agree on.
+type MultiClient struct {
+	endpoints []*btcEndpoint
+	attempts  int
+	backoff   time.Duration
+}
+
+// NewBtcClient creates the client for the BTC nodes given by conf. Host may list
+// several comma separated endpoints. No connection is made until first use.
+func NewBtcClient(conf *BtcRpcConfig) (*MultiClient, error) {
+	var certs []byte
+	if conf.TLS && conf.CACert != "" {
+		var err error
+		if certs, err = os.ReadFile(conf.CACert); err != nil {
+			return nil, fmt.Errorf("read btc rpc certificate error: %v", err)
+		}
+	}
+	if conf.CookieFile != "" {
+		if _, _, err := readCookie(conf.CookieFile); err != nil {
+			return nil, err
+		}
+	}
+	var endpoints []*btcEndpoint
+	for _, host := range strings.Split(conf.Host, ",") {
+		if host = strings.TrimSpace(host); host == "" {
+			continue
+		}
+		connCfg := &rpcclient.ConnConfig{
+			Host:         host,
+			User:         conf.User,
+			Pass:         conf.Pass,
+			HTTPPostMode: true,
+			DisableTLS:   !conf.TLS,
+			Certificates: certs,
+		}
+		cookie := conf.CookieFile
+		endpoints = append(endpoints, &btcEndpoint{
+			host: host,
+			dial: func() (BtcClient, error) {
+				cfg := *connCfg
+				if cookie != "" {
+					var err error
+					if cfg.User, cfg.Pass, err = readCookie(cookie); err != nil {
+						return nil, err
+					}
+				}
+				return rpcclient.New(&cfg, nil)
+			},
+		})
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("no btc rpc host configured")
+	}
+	return newMultiClient(endpoints), nil
+}
+
+func newMultiClient(endpoints []*btcEndpoint) *MultiClient {
+	return &MultiClient{
+		endpoints: endpoints,
+		attempts:  btcRetryAttempts,
+		backoff:   btcRetryBackoff,
+	}
+}
+
+// readCookie reads the credentials from a bitcoind cookie file. The file is
+// rewritten on every bitcoind restart, so it is read again on each dial.
+func readCookie(path string) (string, string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("read btc rpc cookie error: %v", err)
+	}
+	parts := strings.SplitN(strings.TrimSpace(string(raw)), ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("malformed btc rpc cookie %s", path)
+	}
+	return parts[0], parts[1], nil
+}
+
+// isNodeError reports whether err was returned by a healthy node, rather than
+// caused by the connection to it. A node still warming up is not healthy.
+func isNodeError(err error) bool {
+	var rpcErr *btcjson.RPCError
+	return errors.As(err, &rpcErr) && rpcErr.Code != btcjson.ErrRPCInWarmup
+}
+
+// candidates returns the healthy endpoints, or all of them if none is healthy.
+func (c *MultiClient) candidates() []*btcEndpoint {
+	var healthy []*btcEndpoint
+	for _, ep := range c.endpoints {
+		if ep.healthy() {
+			healthy = append(healthy, ep)
+		}
+	}
+	if len(healthy) == 0 {
+		return c.endpoints
+	}
+	return healthy
+}
+
+// do runs req against the endpoints until one of them answers, and returns the
+// endpoint that did.
+func (c *MultiClient) do(req func(BtcClient) error) (*btcEndpoint, error) {
+	var err error
+	delay := c.backoff
+	for attempt := 0; attempt < c.attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			if delay *= 2; delay > btcMaxBackoff {
+				delay = btcMaxBackoff
+			}
+		}
+		for _, ep := range c.candidates() {
+			var client BtcClient
+			if client, err = ep.get(); err == nil {
+				if err = req(client); err == nil || isNodeError(err) {
+					ep.succeed()
+					return ep, err
+				}
+			}
+			btcRPCErrorCounter.Inc(1)
+			ep.fail(err)
+		}
+	}
+	return nil, err
+}
+
+// checkAgreement makes sure every other healthy endpoint either reports hash at
+// height or has not reached it yet.
+func (c *MultiClient) checkAgreement(height int64, hash *chainhash.Hash, source *btcEndpoint) error {
+	for _, ep := range c.endpoints {
+		if ep == source || !ep.healthy() {
+			continue
+		}
+		client, err := ep.get()
+		if err != nil {
+			ep.fail(err)
+			continue
+		}
+		other, err := client.GetBlockHash(height)
+		if err != nil {
+			if !isNodeError(err) {
+				ep.fail(err)
+			}
+			continue
+		}
+		if !other.IsEqual(hash) {
+			return fmt.Errorf("btc endpoints disagree on block %d: %s has %s, %s has %s", height, source.host, hash, ep.host, other)
+		}
+	}
+	return nil
+}
+
+// GetBlockCount returns the height of the best block of the first healthy endpoint.
+func (c *MultiClient) GetBlockCount() (count int64, err error) {
+	_, err = c.do(func(client BtcClient) (err error) {
+		count, err = client.GetBlockCount()
+		return err
+	})
+	return count, err
+}
+
+// GetBlockHash returns the hash of the block at the given height, provided all
+// healthy endpoints that know the height agree on it.
+func (c *MultiClient) GetBlockHash(blockHeight int64) (hash *chainhash.Hash, err error) {
+	source, err := c.do(func(client BtcClient) (err error) {
+		hash, err = client.GetBlockHash(blockHeight)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkAgreement(blockHeight, hash, source); err != nil {
+		return nil, err
+	}
+	return hash, nil
+}
+
+// GetBlock returns the block with the given hash, checking it hashes to it.
+func (c *MultiClient) GetBlock(blockHash *chainhash.Hash) (block *wire.MsgBlock, err error) {
+	defer btcGetBlockTimer.UpdateSince(time.Now())
+	_, err = c.do(func(client BtcClient) (err error) {
+		if block, err = client.GetBlock(blockHash); err != nil {
+			return err
+		}
+		if hash := block.BlockHash(); !hash.IsEqual(blockHash) {
+			return fmt.Errorf("btc node returned block %s for %s", hash, blockHash)
+		}
+		return nil
+	})
+	return block, err
+}
+
+// GetRawTransaction returns the transaction with the given hash.
+func (c *MultiClient) GetRawTransaction(txHash *chainhash.Hash) (tx *btcutil.Tx, err error) {
+	defer btcGetTxTimer.UpdateSince(time.Now())
+	_, err = c.do(func(client BtcClient) (err error) {
+		tx, err = client.GetRawTransaction(txHash)
+		return err
+	})
+	return tx, err
+}
+
+// GetBlockChainInfo returns the chain info of the first healthy endpoint.
+func (c *MultiClient) GetBlockChainInfo() (info *btcjson.GetBlockChainInfoResult, err error) {
+	_, err = c.do(func(client BtcClient) (err error) {
+		info, err = client.GetBlockChainInfo()
+		return err
+	})
+	return info, err
+}
+
+// EstimateSmartFee returns the fee rate estimate of the first healthy endpoint.
+func (c *MultiClient) EstimateSmartFee(confTarget int64, mode *btcjson.EstimateSmartFeeMode) (res *btcjson.EstimateSmartFeeResult, err error) {
+	_, err = c.do(func(client BtcClient) (err error) {
+		res, err = client.EstimateSmartFee(confTarget, mode)
+		return err
+	})
+	return res, err
+}
+
+// ListUnspentMinMaxAddresses returns the outputs paying to addrs known to the
+// wallet of the first healthy endpoint.
+func (c *MultiClient) ListUnspentMinMaxAddresses(minConf, maxConf int, addrs []btcutil.Address) (res []btcjson.ListUnspentResult, err error) {
+	_, err = c.do(func(client BtcClient) (err error) {
+		wallet, ok := client.(CheckpointClient)
+		if !ok {
+			return errNoWallet
+		}
+		res, err = wallet.ListUnspentMinMaxAddresses(minConf, maxConf, addrs)
+		return err
+	})
+	return res, err
+}
+
+// SendRawTransaction broadcasts tx through the first healthy endpoint.
+func (c *MultiClient) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (hash *chainhash.Hash, err error) {
+	_, err = c.do(func(client BtcClient) (err error) {
+		wallet, ok := client.(CheckpointClient)
+		if !ok {
+			return errNoWallet
+		}
+		hash, err = wallet.SendRawTransaction(tx, allowHighFees)
+		return err
+	})
+	return hash, err
+}
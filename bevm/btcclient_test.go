@@ -0,0 +1,225 @@
+package bevm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var errConnRefused = errors.New("connection refused")
+
+// flakyClient fails its next fails requests with failErr, or a transport
+// error if nil.
+type flakyClient struct {
+	BtcClient
+	fails     int
+	failErr   error
+	calls     int
+	shutdowns int
+}
+
+func (c *flakyClient) check() error {
+	c.calls++
+	if c.fails > 0 {
+		c.fails--
+		if c.failErr != nil {
+			return c.failErr
+		}
+		return errConnRefused
+	}
+	return nil
+}
+
+func (c *flakyClient) Shutdown() {
+	c.shutdowns++
+}
+
+func (c *flakyClient) GetBlockCount() (int64, error) {
+	if err := c.check(); err != nil {
+		return 0, err
+	}
+	return c.BtcClient.GetBlockCount()
+}
+
+func (c *flakyClient) GetBlockHash(height int64) (*chainhash.Hash, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	return c.BtcClient.GetBlockHash(height)
+}
+
+func (c *flakyClient) GetBlock(hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	return c.BtcClient.GetBlock(hash)
+}
+
+func (c *flakyClient) GetRawTransaction(hash *chainhash.Hash) (*btcutil.Tx, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	return c.BtcClient.GetRawTransaction(hash)
+}
+
+// newTestEndpoint returns an endpoint serving client, counting its dials.
+func newTestEndpoint(host string, client BtcClient, dials *int) *btcEndpoint {
+	return &btcEndpoint{
+		host: host,
+		dial: func() (BtcClient, error) {
+			*dials++
+			return client, nil
+		},
+	}
+}
+
+func TestMultiClientFailover(t *testing.T) {
+	blocks := newBtcChain(2)
+	primary := &flakyClient{BtcClient: NewFixtureClient(0, blocks, nil), fails: 1}
+	fallback := &flakyClient{BtcClient: NewFixtureClient(0, blocks, nil)}
+	var primaryDials, fallbackDials int
+	c := newMultiClient([]*btcEndpoint{
+		newTestEndpoint("primary", primary, &primaryDials),
+		newTestEndpoint("fallback", fallback, &fallbackDials),
+	})
+
+	hash, err := c.GetBlockHash(1)
+	if err != nil {
+		t.Fatalf("failover failed: %v", err)
+	}
+	if want := blocks[1].BlockHash(); !hash.IsEqual(&want) {
+		t.Fatalf("hash mismatch: have %s, want %s", hash, want)
+	}
+	if c.endpoints[0].healthy() || !c.endpoints[1].healthy() {
+		t.Fatal("failed endpoint not marked down")
+	}
+	if primary.shutdowns != 1 {
+		t.Fatalf("failed client not shut down: %d shutdowns", primary.shutdowns)
+	}
+	// Requests stick to the fallback while the primary is down.
+	if _, err := c.GetBlockCount(); err != nil || primary.calls != 1 {
+		t.Fatalf("down endpoint used: %v, %d calls", err, primary.calls)
+	}
+	// Once it is due again, the primary is dialed again and preferred.
+	c.endpoints[0].downUntil = time.Time{}
+	if _, err := c.GetBlockCount(); err != nil || primary.calls != 2 || primaryDials != 2 {
+		t.Fatalf("primary not redialed: %v, %d calls, %d dials", err, primary.calls, primaryDials)
+	}
+}
+
+func TestMultiClientNodeError(t *testing.T) {
+	blocks := newBtcChain(2)
+	primary := &flakyClient{BtcClient: NewFixtureClient(0, blocks, nil)}
+	fallback := &flakyClient{BtcClient: NewFixtureClient(0, blocks, nil)}
+	var dials int
+	c := newMultiClient([]*btcEndpoint{
+		newTestEndpoint("primary", primary, &dials),
+		newTestEndpoint("fallback", fallback, &dials),
+	})
+
+	_, err := c.GetBlockHash(5)
+	var rpcErr *btcjson.RPCError
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("node error not returned: %v", err)
+	}
+	if fallback.calls != 0 || !c.endpoints[0].healthy() {
+		t.Fatal("node error caused a failover")
+	}
+}
+
+func TestMultiClientWarmup(t *testing.T) {
+	blocks := newBtcChain(2)
+	warmup := btcjson.NewRPCError(btcjson.ErrRPCInWarmup, "Loading block index...")
+	primary := &flakyClient{BtcClient: NewFixtureClient(0, blocks, nil), fails: 1, failErr: warmup}
+	fallback := &flakyClient{BtcClient: NewFixtureClient(0, blocks, nil)}
+	var dials int
+	c := newMultiClient([]*btcEndpoint{
+		newTestEndpoint("primary", primary, &dials),
+		newTestEndpoint("fallback", fallback, &dials),
+	})
+
+	if height, err := c.GetBlockCount(); err != nil || height != 1 {
+		t.Fatalf("warming up node not failed over: %d, %v", height, err)
+	}
+	if fallback.calls != 1 || c.endpoints[0].healthy() {
+		t.Fatal("warming up node not marked down")
+	}
+}
+
+func TestMultiClientDisagreement(t *testing.T) {
+	blocks := newBtcChain(2)
+	fork := newBtcBlock(blocks[0], 1)
+	fork.Header.Nonce = 99
+	var dials int
+	c := newMultiClient([]*btcEndpoint{
+		newTestEndpoint("primary", NewFixtureClient(0, blocks, nil), &dials),
+		newTestEndpoint("fork", NewFixtureClient(0, []*wire.MsgBlock{blocks[0], fork}, nil), &dials),
+	})
+	if _, err := c.GetBlockHash(0); err != nil {
+		t.Fatalf("agreeing endpoints rejected: %v", err)
+	}
+	if _, err := c.GetBlockHash(1); err == nil {
+		t.Fatal("disagreeing endpoints accepted")
+	}
+
+	// An endpoint that has not reached the height yet does not block progress.
+	c = newMultiClient([]*btcEndpoint{
+		newTestEndpoint("primary", NewFixtureClient(0, blocks, nil), &dials),
+		newTestEndpoint("lagging", NewFixtureClient(0, blocks[:1], nil), &dials),
+	})
+	if _, err := c.GetBlockHash(1); err != nil {
+		t.Fatalf("lagging endpoint blocked progress: %v", err)
+	}
+}
+
+func TestMultiClientRetry(t *testing.T) {
+	client := &flakyClient{BtcClient: NewFixtureClient(0, newBtcChain(1), nil), fails: 2}
+	var dials int
+	c := newMultiClient([]*btcEndpoint{newTestEndpoint("single", client, &dials)})
+	c.backoff = time.Millisecond
+
+	if _, err := c.GetBlockCount(); err != nil {
+		t.Fatalf("request not retried: %v", err)
+	}
+	if client.calls != 3 || dials != 3 {
+		t.Fatalf("retry mismatch: %d calls, %d dials", client.calls, dials)
+	}
+	client.fails = c.attempts
+	if _, err := c.GetBlockCount(); err != errConnRefused {
+		t.Fatalf("exhausted retries returned %v", err)
+	}
+}
+
+func TestNewBtcClient(t *testing.T) {
+	cookie := filepath.Join(t.TempDir(), ".cookie")
+	if err := os.WriteFile(cookie, []byte("__cookie__:secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewBtcClient(&BtcRpcConfig{Host: "127.0.0.1:8332, 127.0.0.1:18332", CookieFile: cookie})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if len(c.endpoints) != 2 || c.endpoints[1].host != "127.0.0.1:18332" {
+		t.Fatalf("endpoints mismatch: %v", c.endpoints)
+	}
+	if user, pass, err := readCookie(cookie); err != nil || user != "__cookie__" || pass != "secret" {
+		t.Fatalf("cookie mismatch: %s, %s, %v", user, pass, err)
+	}
+
+	if _, err := NewBtcClient(&BtcRpcConfig{Host: " , "}); err == nil {
+		t.Fatal("accepted a config without hosts")
+	}
+	if _, err := NewBtcClient(&BtcRpcConfig{Host: "127.0.0.1:8332", CookieFile: cookie + ".missing"}); err == nil {
+		t.Fatal("accepted a missing cookie file")
+	}
+	if _, err := NewBtcClient(&BtcRpcConfig{Host: "127.0.0.1:8332", TLS: true, CACert: cookie + ".missing"}); err == nil {
+		t.Fatal("accepted a missing certificate file")
+	}
+}
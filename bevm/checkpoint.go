@@ -0,0 +1,266 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/bevm/protocol"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// DefaultCheckpointInterval is the number of blocks between checkpoints, about
+// one a day.
+const DefaultCheckpointInterval = 144
+
+// CheckpointClient is the wallet RPC of the BTC node checkpoints are funded and
+// broadcast through. The committer address must be watched by its wallet.
+type CheckpointClient interface {
+	ListUnspentMinMaxAddresses(minConf, maxConf int, addrs []btcutil.Address) ([]btcjson.ListUnspentResult, error)
+	SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error)
+}
+
+// CheckpointRecord is a checkpoint found on the BTC chain, along with whether it
+// matches the local chain.
+type CheckpointRecord struct {
+	Height    uint64      `json:"height"`
+	BlockHash common.Hash `json:"blockHash"`
+	StateRoot common.Hash `json:"stateRoot"`
+	BtcTxHash common.Hash `json:"btcTxHash"` // same byte order as the RefHash of invocations
+	BtcHeight uint64      `json:"btcHeight"`
+	Valid     bool        `json:"valid"`
+}
+
+// ReadLatestCheckpoint retrieves the latest checkpoint found on the BTC chain.
+func ReadLatestCheckpoint(db ethdb.KeyValueReader) *CheckpointRecord {
+	data := rawdb.ReadLatestCheckpoint(db)
+	if len(data) == 0 {
+		return nil
+	}
+	record := new(CheckpointRecord)
+	if err := rlp.DecodeBytes(data, record); err != nil {
+		log.Error("Invalid checkpoint RLP", "err", err)
+		return nil
+	}
+	return record
+}
+
+// WriteLatestCheckpoint stores the latest checkpoint found on the BTC chain.
+func WriteLatestCheckpoint(db ethdb.KeyValueWriter, record *CheckpointRecord) {
+	data, err := rlp.EncodeToBytes(record)
+	if err != nil {
+		log.Crit("Failed to RLP encode checkpoint", "err", err)
+	}
+	rawdb.WriteLatestCheckpoint(db, data)
+}
+
+// checkpointer commits the state of the chain to Bitcoin every interval blocks
+// and verifies the checkpoints of the trusted committer found in BTC blocks.
+type checkpointer struct {
+	client    BtcClient
+	net       *chaincfg.Params
+	committer []byte // script of the trusted committer, nil to ignore checkpoints
+
+	key      *btcec.PrivateKey // nil if this node does not commit checkpoints
+	addr     btcutil.Address
+	interval uint64
+	feeRate  int64  // sat/vB, 0 to use the estimatesmartfee rate
+	last     uint64 // height of the latest checkpoint committed or seen
+}
+
+// newCheckpointer creates the checkpointer configured by config, or returns nil
+// if checkpoints are disabled. It resumes from the latest checkpoint of db.
+func newCheckpointer(client BtcClient, net *chaincfg.Params, db ethdb.KeyValueReader, config *BtcRpcConfig) (*checkpointer, error) {
+	if config.CheckpointKey == "" && config.CheckpointAddress == "" {
+		return nil, nil
+	}
+	if net == nil {
+		return nil, errors.New("checkpoints require the btc network of the chain")
+	}
+	c := &checkpointer{
+		client:   client,
+		net:      net,
+		interval: config.CheckpointInterval,
+		feeRate:  config.CheckpointFeeRate,
+	}
+	if c.interval == 0 {
+		c.interval = DefaultCheckpointInterval
+	}
+	if record := ReadLatestCheckpoint(db); record != nil {
+		c.last = record.Height
+	}
+	if config.CheckpointKey != "" {
+		key, err := crypto.LoadECDSA(config.CheckpointKey)
+		if err != nil {
+			return nil, fmt.Errorf("load checkpoint key error: %v", err)
+		}
+		c.key, _ = btcec.PrivKeyFromBytes(crypto.FromECDSA(key))
+		if c.addr, err = protocol.CheckpointCommitter(c.key, net); err != nil {
+			return nil, err
+		}
+		if config.CheckpointAddress != "" && config.CheckpointAddress != c.addr.EncodeAddress() {
+			return nil, fmt.Errorf("checkpoint key commits from %s, not %s", c.addr, config.CheckpointAddress)
+		}
+	} else {
+		addr, err := btcutil.DecodeAddress(config.CheckpointAddress, net)
+		if err != nil {
+			return nil, fmt.Errorf("invalid checkpoint address: %v", err)
+		}
+		c.addr = addr
+	}
+	committer, err := txscript.PayToAddrScript(c.addr)
+	if err != nil {
+		return nil, err
+	}
+	c.committer = committer
+	log.Info("BTC checkpoints enabled", "committer", c.addr, "commit", c.key != nil, "interval", c.interval)
+	return c, nil
+}
+
+// verify checks the checkpoints carried by bblock, at the given height, against
+// chain and records the latest of them. Checkpoints older than the recorded one
+// are verified but not recorded.
+func (c *checkpointer) verify(chain *core.BlockChain, db ethdb.KeyValueStore, bblock *wire.MsgBlock, fetcher txscript.PrevOutputFetcher, height uint64) {
+	if fetcher == nil {
+		return
+	}
+	for _, tx := range bblock.Transactions {
+		for _, cp := range protocol.ExtractCheckpoints(tx, fetcher, c.committer) {
+			record := &CheckpointRecord{
+				Height:    cp.Height,
+				BlockHash: cp.BlockHash,
+				StateRoot: cp.StateRoot,
+				BtcTxHash: common.Hash(tx.TxHash()),
+				BtcHeight: height,
+			}
+			header := chain.GetHeaderByNumber(cp.Height)
+			record.Valid = header != nil && header.Hash() == cp.BlockHash && header.Root == cp.StateRoot
+			if record.Valid {
+				log.Info("Verified btc checkpoint", "number", cp.Height, "hash", cp.BlockHash, "btctx", tx.TxHash())
+			} else {
+				reportCheckpoint(record, header)
+			}
+			if cp.Height > c.last {
+				c.last = cp.Height
+			}
+			if latest := ReadLatestCheckpoint(db); latest == nil || cp.Height > latest.Height {
+				WriteLatestCheckpoint(db, record)
+			}
+		}
+	}
+}
+
+// reportCheckpoint logs a checkpoint diverging from the local chain.
+func reportCheckpoint(record *CheckpointRecord, local *types.Header) {
+	var localHash, localRoot common.Hash
+	if local != nil {
+		localHash, localRoot = local.Hash(), local.Root
+	}
+	log.Error(fmt.Sprintf(`
+########## BTC CHECKPOINT MISMATCH #########
+Number: %v
+Checkpoint hash: %v
+Checkpoint root: %v
+Local hash: %v
+Local root: %v
+BTC tx: %v at %d
+############################################
+`, record.Height, record.BlockHash.Hex(), record.StateRoot.Hex(), localHash.Hex(), localRoot.Hex(), chainhash.Hash(record.BtcTxHash), record.BtcHeight))
+}
+
+// rewind forgets the checkpoints of the blocks above fork, which were removed
+// from the chain along with the BTC blocks carrying them. The recorded one is
+// dropped if it checkpoints such a block or was carried by one.
+func (c *checkpointer) rewind(db ethdb.KeyValueStore, fork uint64) {
+	if latest := ReadLatestCheckpoint(db); latest != nil && (latest.Height > fork || latest.BtcHeight > fork) {
+		rawdb.DeleteLatestCheckpoint(db)
+	}
+	if c.last > fork {
+		c.last = fork
+	}
+}
+
+// due reports whether head should be committed.
+func (c *checkpointer) due(head *types.Header) bool {
+	return c.key != nil && head.Number.Uint64() >= c.last+c.interval
+}
+
+// commit commits head to Bitcoin, funding the transaction with the outputs of
+// the committer address known to the wallet of the BTC node.
+func (c *checkpointer) commit(head *types.Header) (*chainhash.Hash, error) {
+	wallet, ok := c.client.(CheckpointClient)
+	if !ok {
+		return nil, errNoWallet
+	}
+	unspent, err := wallet.ListUnspentMinMaxAddresses(1, math.MaxInt32, []btcutil.Address{c.addr})
+	if err != nil {
+		return nil, fmt.Errorf("list checkpoint funds error: %v", err)
+	}
+	var (
+		points   []wire.OutPoint
+		prevOuts []*wire.TxOut
+	)
+	for _, utxo := range unspent {
+		hash, err := chainhash.NewHashFromStr(utxo.TxID)
+		if err != nil {
+			return nil, err
+		}
+		script, err := hex.DecodeString(utxo.ScriptPubKey)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := btcutil.NewAmount(utxo.Amount)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, *wire.NewOutPoint(hash, utxo.Vout))
+		prevOuts = append(prevOuts, wire.NewTxOut(int64(amount), script))
+	}
+	feeRate := c.feeRate
+	if feeRate == 0 {
+		feeRate = c.estimateFeeRate()
+	}
+	cp := &protocol.Checkpoint{Height: head.Number.Uint64(), BlockHash: head.Hash(), StateRoot: head.Root}
+	tx, err := protocol.NewCheckpointTx(cp, c.key, c.net, points, prevOuts, feeRate)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := wallet.SendRawTransaction(tx, false)
+	if err != nil {
+		return nil, fmt.Errorf("send checkpoint error: %v", err)
+	}
+	c.last = cp.Height
+	log.Info("Committed btc checkpoint", "number", cp.Height, "hash", cp.BlockHash, "root", cp.StateRoot, "btctx", hash)
+	return hash, nil
+}
+
+// estimateFeeRate returns the estimatesmartfee rate of the BTC node in sat/vB,
+// or the minimum relay rate if it has no estimate.
+func (c *checkpointer) estimateFeeRate() int64 {
+	res, err := c.client.EstimateSmartFee(smartFeeTarget, nil)
+	if err != nil || res.FeeRate == nil {
+		return 1
+	}
+	rate := int64(math.Ceil(*res.FeeRate * btcutil.SatoshiPerBitcoin / 1000))
+	if rate < 1 {
+		return 1
+	}
+	return rate
+}
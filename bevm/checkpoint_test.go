@@ -0,0 +1,162 @@
+package bevm
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/bevm/protocol"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// walletClient is a fixture client with a wallet holding fixed outputs.
+type walletClient struct {
+	*FixtureClient
+	unspent []btcjson.ListUnspentResult
+	sent    []*wire.MsgTx
+}
+
+func (c *walletClient) ListUnspentMinMaxAddresses(minConf, maxConf int, addrs []btcutil.Address) ([]btcjson.ListUnspentResult, error) {
+	return c.unspent, nil
+}
+
+func (c *walletClient) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error) {
+	c.sent = append(c.sent, tx)
+	hash := tx.TxHash()
+	return &hash, nil
+}
+
+func TestCheckpoints(t *testing.T) {
+	ecdsaKey, _ := crypto.GenerateKey()
+	keyFile := filepath.Join(t.TempDir(), "checkpoint.key")
+	if err := crypto.SaveECDSA(keyFile, ecdsaKey); err != nil {
+		t.Fatal(err)
+	}
+	key, _ := btcec.PrivKeyFromBytes(crypto.FromECDSA(ecdsaKey))
+	net := &chaincfg.RegressionNetParams
+	addr, _ := protocol.CheckpointCommitter(key, net)
+	committer, _ := txscript.PayToAddrScript(addr)
+
+	funding := wire.NewMsgTx(wire.TxVersion)
+	funding.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	funding.AddTxOut(wire.NewTxOut(1e5, committer))
+	genesis := newBtcBlock(nil, 0)
+	block1 := newBtcBlock(genesis, 1, funding)
+	block2 := newBtcBlock(block1, 2)
+	client := &walletClient{
+		FixtureClient: NewFixtureClient(0, []*wire.MsgBlock{genesis, block1, block2}, nil),
+		unspent: []btcjson.ListUnspentResult{{
+			TxID:         funding.TxHash().String(),
+			ScriptPubKey: hex.EncodeToString(committer),
+			Amount:       0.001,
+		}},
+	}
+	chain, db := newVerifyTestChain(t, genesis)
+	defer chain.Stop()
+	config := &BtcRpcConfig{CheckpointKey: keyFile, CheckpointInterval: 2, CheckpointFeeRate: 1}
+	checkpoints, err := newCheckpointer(client, net, db, config)
+	if err != nil {
+		t.Fatalf("failed to create checkpointer: %v", err)
+	}
+	miner := &Miner{
+		eth:         NewMockBackend(chain, db),
+		bt:          NewBlockTranslatorWithClient(client),
+		checkpoints: checkpoints,
+	}
+
+	// Block 2 is committed once the chain caught up.
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	if len(client.sent) != 1 {
+		t.Fatalf("committed %d checkpoints, want 1", len(client.sent))
+	}
+	block3 := newBtcBlock(block2, 3, client.sent[0])
+	client.addBlock(3, block3)
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	head := chain.GetHeaderByNumber(2)
+	record := ReadLatestCheckpoint(db)
+	if record == nil || !record.Valid || record.Height != 2 || record.BlockHash != head.Hash() || record.StateRoot != head.Root || record.BtcHeight != 3 {
+		t.Fatalf("checkpoint record mismatch: %+v", record)
+	}
+	if len(client.sent) != 1 {
+		t.Fatalf("committed again before the interval: %d", len(client.sent))
+	}
+
+	// A checkpoint diverging from the local chain is recorded as invalid.
+	change := client.sent[0].TxHash()
+	forged, err := protocol.NewCheckpointTx(&protocol.Checkpoint{Height: 3, BlockHash: chain.GetHeaderByNumber(3).Hash(), StateRoot: common.Hash{0xff}},
+		key, net, []wire.OutPoint{*wire.NewOutPoint(&change, 1)}, []*wire.TxOut{client.sent[0].TxOut[1]}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.addBlock(4, newBtcBlock(block3, 4, forged))
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	if record = ReadLatestCheckpoint(db); record == nil || record.Valid || record.Height != 3 {
+		t.Fatalf("forged checkpoint not flagged: %+v", record)
+	}
+
+	// A restarted checkpointer resumes from the recorded checkpoint.
+	restarted, err := newCheckpointer(client, net, db, config)
+	if err != nil {
+		t.Fatalf("failed to restart checkpointer: %v", err)
+	}
+	if restarted.last != 3 || restarted.due(chain.GetHeaderByNumber(4)) {
+		t.Fatalf("restarted checkpointer not resumed: last %d", restarted.last)
+	}
+	// An older checkpoint found later does not replace the recorded one.
+	change = forged.TxHash()
+	older, err := protocol.NewCheckpointTx(&protocol.Checkpoint{Height: 2, BlockHash: head.Hash(), StateRoot: head.Root},
+		key, net, []wire.OutPoint{*wire.NewOutPoint(&change, 1)}, []*wire.TxOut{forged.TxOut[1]}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block5 := newBtcBlock(client.blocks[4], 5, older)
+	fetcher, err := miner.bt.FetchPrevOuts(block5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.verify(chain, db, block5, fetcher, 5)
+	if record = ReadLatestCheckpoint(db); record == nil || record.Height != 3 {
+		t.Fatalf("older checkpoint recorded: %+v", record)
+	}
+
+	// Replacing the BTC block carrying the recorded checkpoint drops it.
+	replaced := newBtcBlock(block3, 4)
+	replaced.Header.Nonce = 100
+	client.addBlock(4, replaced)
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to rewind: %v", err)
+	}
+	if record = ReadLatestCheckpoint(db); record != nil {
+		t.Fatalf("checkpoint of a replaced block still recorded: %+v", record)
+	}
+	if head := chain.CurrentHeader().Number.Uint64(); checkpoints.last > head {
+		t.Fatalf("checkpointer above the fork: last %d, head %d", checkpoints.last, head)
+	}
+
+	// Checkpoints of other committers are ignored.
+	checkpoints, err = newCheckpointer(client, net, db, &BtcRpcConfig{CheckpointAddress: "bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080"})
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	fetcher, err = miner.bt.FetchPrevOuts(&wire.MsgBlock{Transactions: []*wire.MsgTx{forged}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoints.key != nil || protocol.ExtractCheckpoints(forged, fetcher, checkpoints.committer) != nil {
+		t.Fatal("foreign checkpoint accepted")
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// eventChanSize is the size of the channels subscribing to the miner events.
+const eventChanSize = 256
+
+// BtcBlockEvent is posted by the miner when a BTC block was translated and its
+// bevm block appended to the chain, including blocks imported from bevm/1 peers
+// once translated again.
+type BtcBlockEvent struct {
+	BtcHash chainhash.Hash
+	Block   *types.Block
+}
+
+// InvocationEvent is posted by the miner for each EVM invocation extracted from
+// a translated BTC block, once the bevm block is appended to the chain.
+// Rejected invocations have no transaction and carry the error they were
+// rejected with.
+type InvocationEvent struct {
+	BlockHash   common.Hash
+	BlockNumber uint64
+	RefHash     common.Hash        // hash of the btc transaction carrying the invocation
+	Index       uint64             // index of the invocation in the btc transaction
+	Tx          *types.Transaction // nil if rejected
+	Err         error              // reason of the rejection, nil if accepted
+}
+
+// ReorgEvent is posted by the miner when a BTC reorg replaced translated
+// blocks. Blocks Fork+1 to OldHead were removed from the chain, and blocks
+// Fork+1 to NewHead of the new BTC branch are translated and posted after it.
+type ReorgEvent struct {
+	Fork    uint64 // number of the last block kept
+	OldHead uint64 // head of the chain before the reorg
+	NewHead uint64 // height of the new BTC branch
+}
+
+// SubscribeBtcBlockEvent registers a subscription of BtcBlockEvent.
+func (self *Miner) SubscribeBtcBlockEvent(ch chan<- BtcBlockEvent) event.Subscription {
+	return self.scope.Track(self.btcBlockFeed.Subscribe(ch))
+}
+
+// SubscribeInvocationEvent registers a subscription of InvocationEvent.
+func (self *Miner) SubscribeInvocationEvent(ch chan<- InvocationEvent) event.Subscription {
+	return self.scope.Track(self.invocationFeed.Subscribe(ch))
+}
+
+// SubscribeReorgEvent registers a subscription of ReorgEvent.
+func (self *Miner) SubscribeReorgEvent(ch chan<- ReorgEvent) event.Subscription {
+	return self.scope.Track(self.reorgFeed.Subscribe(ch))
+}
+
+// postBlock posts the events of a translated BTC block whose bevm block was
+// appended to the chain.
+func (self *Miner) postBlock(bblock chainhash.Hash, block *types.Block, invocations []*InvocationEvent) {
+	self.btcBlockFeed.Send(BtcBlockEvent{BtcHash: bblock, Block: block})
+	for _, ev := range invocations {
+		ev.BlockHash, ev.BlockNumber = block.Hash(), block.NumberU64()
+		self.invocationFeed.Send(*ev)
+	}
+}
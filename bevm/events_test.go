@@ -0,0 +1,115 @@
+package bevm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestMinerEvents(t *testing.T) {
+	funding, spend := newDeployTxs(t, []byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x00}) // sstore(0, 1)
+	relayFunding, relaySpend := newRelayTxs(t, types.NewTx(&types.LegacyTx{Gas: 50000}))
+	genesis := newBtcBlock(nil, 0)
+	block1 := newBtcBlock(genesis, 1, spend, relaySpend)
+	block2 := newBtcBlock(block1, 2)
+	block3 := newBtcBlock(block2, 3)
+	client := NewFixtureClient(0, []*wire.MsgBlock{genesis, block1, block2, block3}, []*wire.MsgTx{funding, relayFunding})
+
+	chain, db := newVerifyTestChain(t, genesis)
+	defer chain.Stop()
+	miner := &Miner{
+		eth: NewMockBackend(chain, db),
+		bt:  NewBlockTranslatorWithClient(client),
+	}
+	blocks := make(chan BtcBlockEvent, eventChanSize)
+	invocations := make(chan InvocationEvent, eventChanSize)
+	reorgs := make(chan ReorgEvent, eventChanSize)
+	defer miner.SubscribeBtcBlockEvent(blocks).Unsubscribe()
+	defer miner.SubscribeInvocationEvent(invocations).Unsubscribe()
+	defer miner.SubscribeReorgEvent(reorgs).Unsubscribe()
+
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to sync fixture blocks: %v", err)
+	}
+	for i, want := range []*wire.MsgBlock{block1, block2, block3} {
+		ev := <-blocks
+		if ev.BtcHash != want.BlockHash() || ev.Block.NumberU64() != uint64(i+1) || ev.Block.UncleHash() != BtcHashToEvmHash(want.BlockHash()) {
+			t.Fatalf("block event %d mismatch: have %v at %d", i+1, ev.BtcHash, ev.Block.NumberU64())
+		}
+	}
+	// The deployment is accepted, the relayed transaction rejected as relaying
+	// is disabled without chain id.
+	head1 := chain.GetBlockByNumber(1)
+	deploy, relay := <-invocations, <-invocations
+	if deploy.BlockHash != head1.Hash() || deploy.BlockNumber != 1 || deploy.RefHash != [32]byte(spend.TxHash()) || deploy.Err != nil {
+		t.Fatalf("deploy event mismatch: %+v", deploy)
+	}
+	if deploy.Tx == nil || deploy.Tx.Hash() != head1.Transactions()[0].Hash() {
+		t.Fatalf("deploy tx mismatch: %+v", deploy.Tx)
+	}
+	if relay.BlockHash != head1.Hash() || relay.RefHash != [32]byte(relaySpend.TxHash()) || relay.Tx != nil || !errors.Is(relay.Err, errRelayDisabled) {
+		t.Fatalf("relay event mismatch: %+v", relay)
+	}
+	if rpc := newRPCInvocation(&relay, types.HomesteadSigner{}); rpc.Hash != nil || rpc.Reason != "disabled" {
+		t.Fatalf("rpc relay mismatch: %+v", rpc)
+	}
+	select {
+	case ev := <-invocations:
+		t.Fatalf("unexpected invocation event: %+v", ev)
+	default:
+	}
+
+	// Replace blocks 2 and 3 with a longer branch forking from block 1.
+	fork2 := newBtcBlock(block1, 2)
+	fork2.Header.Nonce = 100
+	fork3 := newBtcBlock(fork2, 3)
+	fork4 := newBtcBlock(fork3, 4)
+	for i, block := range []*wire.MsgBlock{fork2, fork3, fork4} {
+		client.addBlock(int64(i+2), block)
+	}
+	// The first round rewinds to the fork, the second translates the branch.
+	for i := 0; i < 2; i++ {
+		if err := miner.loop(); err != nil {
+			t.Fatalf("failed to sync forked blocks: %v", err)
+		}
+	}
+	ev := <-reorgs
+	if ev.OldHead != 3 || ev.NewHead != 4 || ev.Fork > 1 {
+		t.Fatalf("reorg event mismatch: %+v", ev)
+	}
+	if rpc := newRPCReorg(&ev); rpc.Removed.To != 3 || rpc.Added == nil || rpc.Added.To != 4 {
+		t.Fatalf("rpc reorg mismatch: %+v", rpc)
+	}
+	for n := ev.Fork + 1; n <= 4; n++ {
+		block := (<-blocks).Block
+		if block.NumberU64() != n || block.Hash() != chain.GetBlockByNumber(n).Hash() {
+			t.Fatalf("branch block event %d mismatch: have %d", n, block.NumberU64())
+		}
+	}
+	if head := chain.CurrentBlock(); head.NumberU64() != 4 || head.UncleHash() != BtcHashToEvmHash(fork4.BlockHash()) {
+		t.Fatalf("head mismatch: have %d %x", head.NumberU64(), head.UncleHash())
+	}
+
+	// Replace the tip at the height of the head.
+	tip := newBtcBlock(fork3, 4)
+	tip.Header.Nonce = 200
+	client.addBlock(4, tip)
+	for i := 0; i < 2; i++ {
+		if err := miner.loop(); err != nil {
+			t.Fatalf("failed to sync replaced tip: %v", err)
+		}
+	}
+	select {
+	case ev = <-reorgs:
+		if ev.OldHead != 4 || ev.NewHead != 4 || ev.Fork != 3 {
+			t.Fatalf("tip reorg event mismatch: %+v", ev)
+		}
+	default:
+		t.Fatal("replaced tip not detected")
+	}
+	if head := chain.CurrentBlock(); head.NumberU64() != 4 || head.UncleHash() != BtcHashToEvmHash(tip.BlockHash()) {
+		t.Fatalf("replaced tip not synced: have %d %x", head.NumberU64(), head.UncleHash())
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"math/big"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// WeiPerSat converts satoshis into wei of the native token, which is BTC with
+// 18 decimals.
+const WeiPerSat = 1e10
+
+// BtcFee is the fee paid by a BTC transaction carrying EVM invocations, along
+// with the gas its invocations used.
+type BtcFee struct {
+	TxHash  common.Hash // hash of the btc transaction, as referenced by its invocations
+	Fee     uint64      // in satoshi
+	VSize   uint64      // in virtual bytes
+	GasUsed uint64      // total gas used by the invocations of the transaction
+}
+
+// FeeRate returns the fee rate of the transaction in sat/vB.
+func (fee *BtcFee) FeeRate() float64 {
+	if fee.VSize == 0 {
+		return 0
+	}
+	return float64(fee.Fee) / float64(fee.VSize)
+}
+
+// GasPrice returns the fee of the transaction spread over the gas used by its
+// invocations, in wei per gas.
+func (fee *BtcFee) GasPrice() *big.Int {
+	if fee.GasUsed == 0 {
+		return new(big.Int)
+	}
+	price := new(big.Int).Mul(new(big.Int).SetUint64(fee.Fee), big.NewInt(WeiPerSat))
+	return price.Div(price, new(big.Int).SetUint64(fee.GasUsed))
+}
+
+// btcTxFee computes the fee paid by tx, provided fetcher knows all of the
+// outputs it spends.
+func btcTxFee(tx *wire.MsgTx, fetcher txscript.PrevOutputFetcher) (uint64, bool) {
+	var in, out int64
+	for _, txin := range tx.TxIn {
+		prevOut := fetcher.FetchPrevOutput(txin.PreviousOutPoint)
+		if prevOut == nil {
+			return 0, false
+		}
+		in += prevOut.Value
+	}
+	for _, txout := range tx.TxOut {
+		out += txout.Value
+	}
+	if in < out {
+		return 0, false
+	}
+	return uint64(in - out), true
+}
+
+// collectBtcFees gathers the fees of the BTC transactions carrying the
+// invocations of an executed block. Transactions whose inputs are not all known
+// to fetcher are left out.
+func collectBtcFees(bblock *wire.MsgBlock, fetcher txscript.PrevOutputFetcher, txs types.Transactions, receipts types.Receipts) []*BtcFee {
+	if fetcher == nil || len(txs) != len(receipts) {
+		return nil
+	}
+	gasUsed := make(map[common.Hash]uint64)
+	for i, tx := range txs {
+		if hash, _, ok := tx.BtcRef(); ok {
+			gasUsed[hash] += receipts[i].GasUsed
+		}
+	}
+	var fees []*BtcFee
+	for _, tx := range bblock.Transactions {
+		hash := common.Hash(tx.TxHash())
+		gas, ok := gasUsed[hash]
+		if !ok {
+			continue
+		}
+		fee, ok := btcTxFee(tx, fetcher)
+		if !ok {
+			continue
+		}
+		weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+		fees = append(fees, &BtcFee{
+			TxHash:  hash,
+			Fee:     fee,
+			VSize:   uint64((weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor),
+			GasUsed: gas,
+		})
+	}
+	return fees
+}
+
+// ReadBtcFees retrieves the fees of the BTC transactions carrying the
+// invocations of the bevm block with the given hash.
+func ReadBtcFees(db ethdb.KeyValueReader, hash common.Hash) []*BtcFee {
+	data := rawdb.ReadBtcFees(db, hash)
+	if len(data) == 0 {
+		return nil
+	}
+	var fees []*BtcFee
+	if err := rlp.DecodeBytes(data, &fees); err != nil {
+		log.Error("Invalid btc fees RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return fees
+}
+
+// WriteBtcFees stores the fees of the BTC transactions carrying the
+// invocations of the bevm block with the given hash.
+func WriteBtcFees(db ethdb.KeyValueWriter, hash common.Hash, fees []*BtcFee) {
+	data, err := rlp.EncodeToBytes(fees)
+	if err != nil {
+		log.Crit("Failed to RLP encode btc fees", "err", err)
+	}
+	rawdb.WriteBtcFees(db, hash, data)
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// FixtureClient serves a fixed set of BTC blocks and transactions from memory.
+// It lets the translator re-derive bevm blocks without a live BTC node. Missing
+// items are reported with the RPC errors of bitcoind.
+type FixtureClient struct {
+	Chain   string  // chain reported by GetBlockChainInfo
+	FeeRate float64 // fee rate in BTC/kvB reported by EstimateSmartFee, 0 for none
+
+	heights map[chainhash.Hash]int64
+	blocks  map[int64]*wire.MsgBlock
+	txs     map[chainhash.Hash]*wire.MsgTx
+	tip     int64
+}
+
+// NewFixtureClient creates a client serving blocks[i] at height first+i. Every
+// transaction of the blocks can be looked up, extra holds any further
+// transactions whose outputs are spent by the blocks.
+func NewFixtureClient(first int64, blocks []*wire.MsgBlock, extra []*wire.MsgTx) *FixtureClient {
+	c := &FixtureClient{
+		heights: make(map[chainhash.Hash]int64),
+		blocks:  make(map[int64]*wire.MsgBlock),
+		txs:     make(map[chainhash.Hash]*wire.MsgTx),
+		tip:     -1,
+	}
+	for i, block := range blocks {
+		c.addBlock(first+int64(i), block)
+	}
+	for _, tx := range extra {
+		c.txs[tx.TxHash()] = tx
+	}
+	return c
+}
+
+// LoadFixtureClient loads a fixture directory. Blocks are read from files named
+// block-<height>.hex and standalone transactions from tx-<txid>.hex, both hex
+// encoded in the BTC wire format.
+func LoadFixtureClient(dir string) (*FixtureClient, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	c := NewFixtureClient(0, nil, nil)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".hex") {
+			continue
+		}
+		raw, err := readHexFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		switch base := strings.TrimSuffix(name, ".hex"); {
+		case strings.HasPrefix(base, "block-"):
+			height, err := strconv.ParseInt(strings.TrimPrefix(base, "block-"), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid fixture block file %s: %v", name, err)
+			}
+			block := new(wire.MsgBlock)
+			if err := block.Deserialize(bytes.NewReader(raw)); err != nil {
+				return nil, fmt.Errorf("invalid fixture block %s: %v", name, err)
+			}
+			c.addBlock(height, block)
+		case strings.HasPrefix(base, "tx-"):
+			tx := new(wire.MsgTx)
+			if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+				return nil, fmt.Errorf("invalid fixture transaction %s: %v", name, err)
+			}
+			c.txs[tx.TxHash()] = tx
+		}
+	}
+	return c, nil
+}
+
+func readHexFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(strings.TrimSpace(string(data)))
+}
+
+func (c *FixtureClient) addBlock(height int64, block *wire.MsgBlock) {
+	c.blocks[height] = block
+	c.heights[block.BlockHash()] = height
+	for _, tx := range block.Transactions {
+		c.txs[tx.TxHash()] = tx
+	}
+	if height > c.tip {
+		c.tip = height
+	}
+}
+
+// GetBlockCount returns the height of the highest fixture block.
+func (c *FixtureClient) GetBlockCount() (int64, error) {
+	return c.tip, nil
+}
+
+// GetBlockHash returns the hash of the fixture block at the given height.
+func (c *FixtureClient) GetBlockHash(blockHeight int64) (*chainhash.Hash, error) {
+	block, ok := c.blocks[blockHeight]
+	if !ok {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, fmt.Sprintf("no fixture block at height %d", blockHeight))
+	}
+	hash := block.BlockHash()
+	return &hash, nil
+}
+
+// GetBlock returns the fixture block with the given hash.
+func (c *FixtureClient) GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error) {
+	height, ok := c.heights[*blockHash]
+	if !ok {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCBlockNotFound, fmt.Sprintf("unknown fixture block %s", blockHash))
+	}
+	return c.blocks[height], nil
+}
+
+// GetRawTransaction returns the fixture transaction with the given hash.
+func (c *FixtureClient) GetRawTransaction(txHash *chainhash.Hash) (*btcutil.Tx, error) {
+	tx, ok := c.txs[*txHash]
+	if !ok {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCNoTxInfo, fmt.Sprintf("unknown fixture transaction %s", txHash))
+	}
+	return btcutil.NewTx(tx), nil
+}
+
+// GetBlockChainInfo reports the fixture chain and its highest block.
+func (c *FixtureClient) GetBlockChainInfo() (*btcjson.GetBlockChainInfoResult, error) {
+	info := &btcjson.GetBlockChainInfoResult{Chain: c.Chain, Blocks: int32(c.tip)}
+	if block, ok := c.blocks[c.tip]; ok {
+		info.BestBlockHash = block.BlockHash().String()
+	}
+	return info, nil
+}
+
+// EstimateSmartFee reports the fixture fee rate for any confirmation target.
+func (c *FixtureClient) EstimateSmartFee(confTarget int64, mode *btcjson.EstimateSmartFeeMode) (*btcjson.EstimateSmartFeeResult, error) {
+	if c.FeeRate == 0 {
+		return &btcjson.EstimateSmartFeeResult{Errors: []string{"Insufficient data or no feerate found"}, Blocks: confTarget}, nil
+	}
+	rate := c.FeeRate
+	return &btcjson.EstimateSmartFeeResult{FeeRate: &rate, Blocks: confTarget}, nil
+}
@@ -0,0 +1,356 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	smartFeeTarget      = 6 // confirmation target of the estimatesmartfee fallback
+	defaultVBytesPerGas = 0.005
+)
+
+var (
+	errInvalidPercentile = errors.New("invalid reward percentile")
+	errMissingBlock      = errors.New("requested block not found")
+)
+
+// FeeOracleBackend includes the chain access the fee oracle needs.
+type FeeOracleBackend interface {
+	HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error)
+	ChainDb() ethdb.Database
+}
+
+// FeeOracle recommends gas prices from the BTC fee market. Invocations carry no
+// gas price of their own, what they cost is the fee of the BTC transaction that
+// carries them. The oracle spreads the fees paid in recent blocks over the gas
+// the invocations used, and falls back to the estimatesmartfee rate of the BTC
+// node, converted at defaultVBytesPerGas, when no invocations were made lately.
+type FeeOracle struct {
+	backend FeeOracleBackend
+	client  BtcClient // nil to skip the estimatesmartfee fallback
+
+	checkBlocks, percentile           int
+	maxHeaderHistory, maxBlockHistory int
+	defaultPrice, maxPrice            *big.Int
+
+	lock      sync.Mutex
+	lastHead  common.Hash
+	lastPrice *big.Int
+}
+
+// NewFeeOracle creates a fee oracle sampling recent blocks as configured by
+// params. Invalid settings are sanitized the way gasprice.NewOracle does.
+func NewFeeOracle(backend FeeOracleBackend, client BtcClient, params gasprice.Config) *FeeOracle {
+	blocks := params.Blocks
+	if blocks < 1 {
+		blocks = 1
+		log.Warn("Sanitizing invalid fee oracle sample blocks", "provided", params.Blocks, "updated", blocks)
+	}
+	percent := params.Percentile
+	if percent < 0 {
+		percent = 0
+		log.Warn("Sanitizing invalid fee oracle sample percentile", "provided", params.Percentile, "updated", percent)
+	} else if percent > 100 {
+		percent = 100
+		log.Warn("Sanitizing invalid fee oracle sample percentile", "provided", params.Percentile, "updated", percent)
+	}
+	maxPrice := params.MaxPrice
+	if maxPrice == nil || maxPrice.Sign() <= 0 {
+		maxPrice = gasprice.DefaultMaxPrice
+		log.Warn("Sanitizing invalid fee oracle price cap", "provided", params.MaxPrice, "updated", maxPrice)
+	}
+	defaultPrice := params.Default
+	if defaultPrice == nil {
+		defaultPrice = new(big.Int)
+	}
+	maxHeaderHistory := params.MaxHeaderHistory
+	if maxHeaderHistory < 1 {
+		maxHeaderHistory = 1
+		log.Warn("Sanitizing invalid fee oracle max header history", "provided", params.MaxHeaderHistory, "updated", maxHeaderHistory)
+	}
+	maxBlockHistory := params.MaxBlockHistory
+	if maxBlockHistory < 1 {
+		maxBlockHistory = 1
+		log.Warn("Sanitizing invalid fee oracle max block history", "provided", params.MaxBlockHistory, "updated", maxBlockHistory)
+	}
+	return &FeeOracle{
+		backend:          backend,
+		client:           client,
+		checkBlocks:      blocks,
+		percentile:       percent,
+		maxHeaderHistory: maxHeaderHistory,
+		maxBlockHistory:  maxBlockHistory,
+		defaultPrice:     defaultPrice,
+		maxPrice:         maxPrice,
+	}
+}
+
+// SuggestTipCap returns the per gas price invocations currently pay through
+// their BTC transactions. As bevm blocks have no base fee, this is the full gas
+// price. The result is cached until the head changes.
+func (o *FeeOracle) SuggestTipCap(ctx context.Context) (*big.Int, error) {
+	head, err := o.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if head.Hash() == o.lastHead && o.lastPrice != nil {
+		return new(big.Int).Set(o.lastPrice), nil
+	}
+	price, err := o.pastPrice(ctx, head)
+	if err != nil {
+		return nil, err
+	}
+	if price == nil {
+		price = o.estimatePrice()
+	}
+	if price == nil {
+		price = o.defaultPrice
+	}
+	if price.Cmp(o.maxPrice) > 0 {
+		price = new(big.Int).Set(o.maxPrice)
+	}
+	o.lastHead, o.lastPrice = head.Hash(), price
+	return new(big.Int).Set(price), nil
+}
+
+// pastPrice returns the configured percentile of the per gas prices paid in the
+// blocks up to head, or nil if they carry no invocations.
+func (o *FeeOracle) pastPrice(ctx context.Context, head *types.Header) (*big.Int, error) {
+	var prices []*big.Int
+	number := head.Number.Int64()
+	for i := 0; i < o.checkBlocks && number-int64(i) >= 0; i++ {
+		header := head
+		if i > 0 {
+			var err error
+			if header, err = o.backend.HeaderByNumber(ctx, rpc.BlockNumber(number-int64(i))); err != nil {
+				return nil, err
+			}
+			if header == nil {
+				break
+			}
+		}
+		for _, fee := range ReadBtcFees(o.backend.ChainDb(), header.Hash()) {
+			if fee.GasUsed > 0 {
+				prices = append(prices, fee.GasPrice())
+			}
+		}
+	}
+	if len(prices) == 0 {
+		return nil, nil
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Cmp(prices[j]) < 0 })
+	return prices[(len(prices)-1)*o.percentile/100], nil
+}
+
+// estimatePrice converts the fee rate estimated by the BTC node into a per gas
+// price, or returns nil if the node has no estimate.
+func (o *FeeOracle) estimatePrice() *big.Int {
+	if o.client == nil {
+		return nil
+	}
+	res, err := o.client.EstimateSmartFee(smartFeeTarget, nil)
+	if err != nil {
+		log.Debug("Failed to estimate btc fee rate", "err", err)
+		return nil
+	}
+	if res.FeeRate == nil || *res.FeeRate <= 0 {
+		log.Debug("No btc fee rate estimate", "errors", res.Errors)
+		return nil
+	}
+	// estimatesmartfee reports BTC per kvB
+	satPerVByte := *res.FeeRate * btcutil.SatoshiPerBitcoin / 1000
+	price, _ := new(big.Float).SetFloat64(satPerVByte * defaultVBytesPerGas * WeiPerSat).Int(nil)
+	return price
+}
+
+// feeHistoryRange resolves the headers of the blocks blocks up to lastBlock,
+// capped at maxHistory and at the genesis block.
+func (o *FeeOracle) feeHistoryRange(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, maxHistory int) ([]*types.Header, error) {
+	if blocks > maxHistory {
+		log.Warn("Sanitizing fee history length", "requested", blocks, "truncated", maxHistory)
+		blocks = maxHistory
+	}
+	if lastBlock == rpc.PendingBlockNumber {
+		lastBlock = rpc.LatestBlockNumber
+	}
+	last, err := o.backend.HeaderByNumber(ctx, lastBlock)
+	if err != nil {
+		return nil, err
+	}
+	if last == nil {
+		return nil, fmt.Errorf("%w: %d", errMissingBlock, lastBlock)
+	}
+	if number := last.Number.Uint64(); uint64(blocks) > number+1 {
+		blocks = int(number + 1)
+	}
+	headers := make([]*types.Header, blocks)
+	headers[blocks-1] = last
+	for i := blocks - 2; i >= 0; i-- {
+		number := last.Number.Int64() - int64(blocks-1-i)
+		if headers[i], err = o.backend.HeaderByNumber(ctx, rpc.BlockNumber(number)); err != nil {
+			return nil, err
+		}
+		if headers[i] == nil {
+			return nil, fmt.Errorf("%w: %d", errMissingBlock, number)
+		}
+	}
+	return headers, nil
+}
+
+func checkPercentiles(percentiles []float64) error {
+	for i, p := range percentiles {
+		if p < 0 || p > 100 {
+			return fmt.Errorf("%w: %f", errInvalidPercentile, p)
+		}
+		if i > 0 && p < percentiles[i-1] {
+			return fmt.Errorf("%w: #%d:%f > #%d:%f", errInvalidPercentile, i-1, percentiles[i-1], i, p)
+		}
+	}
+	return nil
+}
+
+// weightedPercentiles returns, for each percentile, the index of the sample at
+// which the cumulative weight of the samples sorted by value reaches it.
+func weightedPercentiles(weights []uint64, percentiles []float64) []int {
+	var total uint64
+	for _, w := range weights {
+		total += w
+	}
+	indices := make([]int, len(percentiles))
+	var i int
+	sum := weights[0]
+	for j, p := range percentiles {
+		threshold := uint64(float64(total) * p / 100)
+		for sum < threshold && i < len(weights)-1 {
+			i++
+			sum += weights[i]
+		}
+		indices[j] = i
+	}
+	return indices
+}
+
+// FeeHistory implements eth_feeHistory. Rewards are the per gas prices paid by
+// the BTC transactions carrying the invocations of each block, weighted by the
+// gas used like the effective tips of Ethereum. Base fees are always zero.
+func (o *FeeOracle) FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, error) {
+	if blocks < 1 {
+		return common.Big0, nil, nil, nil, nil
+	}
+	if err := checkPercentiles(rewardPercentiles); err != nil {
+		return common.Big0, nil, nil, nil, err
+	}
+	maxHistory := o.maxHeaderHistory
+	if len(rewardPercentiles) != 0 {
+		maxHistory = o.maxBlockHistory
+	}
+	headers, err := o.feeHistoryRange(ctx, blocks, lastBlock, maxHistory)
+	if err != nil {
+		return common.Big0, nil, nil, nil, err
+	}
+	var (
+		reward       [][]*big.Int
+		baseFee      = make([]*big.Int, len(headers)+1)
+		gasUsedRatio = make([]float64, len(headers))
+	)
+	if len(rewardPercentiles) != 0 {
+		reward = make([][]*big.Int, len(headers))
+	}
+	for i, header := range headers {
+		baseFee[i] = new(big.Int)
+		if header.GasLimit > 0 {
+			gasUsedRatio[i] = float64(header.GasUsed) / float64(header.GasLimit)
+		}
+		if reward == nil {
+			continue
+		}
+		reward[i] = make([]*big.Int, len(rewardPercentiles))
+		fees := ReadBtcFees(o.backend.ChainDb(), header.Hash())
+		if len(fees) == 0 {
+			for j := range reward[i] {
+				reward[i][j] = new(big.Int)
+			}
+			continue
+		}
+		sort.Slice(fees, func(a, b int) bool { return fees[a].GasPrice().Cmp(fees[b].GasPrice()) < 0 })
+		weights := make([]uint64, len(fees))
+		for j, fee := range fees {
+			weights[j] = fee.GasUsed
+		}
+		for j, index := range weightedPercentiles(weights, rewardPercentiles) {
+			reward[i][j] = fees[index].GasPrice()
+		}
+	}
+	baseFee[len(headers)] = new(big.Int)
+	return new(big.Int).Set(headers[0].Number), reward, baseFee, gasUsedRatio, nil
+}
+
+// BtcFeeHistory is the fee market of the BTC transactions carrying the
+// invocations of a range of blocks.
+type BtcFeeHistory struct {
+	OldestBlock *hexutil.Big     `json:"oldestBlock"`
+	SatPerVByte [][]float64      `json:"satPerVByte,omitempty"` // fee rate percentiles of each block, weighted by vsize
+	TxCount     []hexutil.Uint64 `json:"txCount"`               // number of btc transactions with a known fee per block
+}
+
+// BtcFeeHistory returns the fee rate percentiles paid by the BTC transactions
+// carrying the invocations of each block. Blocks without invocations report
+// zero rates.
+func (o *FeeOracle) BtcFeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, percentiles []float64) (*BtcFeeHistory, error) {
+	if blocks < 1 {
+		return &BtcFeeHistory{OldestBlock: (*hexutil.Big)(new(big.Int))}, nil
+	}
+	if err := checkPercentiles(percentiles); err != nil {
+		return nil, err
+	}
+	headers, err := o.feeHistoryRange(ctx, blocks, lastBlock, o.maxBlockHistory)
+	if err != nil {
+		return nil, err
+	}
+	history := &BtcFeeHistory{
+		OldestBlock: (*hexutil.Big)(new(big.Int).Set(headers[0].Number)),
+		TxCount:     make([]hexutil.Uint64, len(headers)),
+	}
+	if len(percentiles) != 0 {
+		history.SatPerVByte = make([][]float64, len(headers))
+	}
+	for i, header := range headers {
+		fees := ReadBtcFees(o.backend.ChainDb(), header.Hash())
+		history.TxCount[i] = hexutil.Uint64(len(fees))
+		if history.SatPerVByte == nil {
+			continue
+		}
+		history.SatPerVByte[i] = make([]float64, len(percentiles))
+		if len(fees) == 0 {
+			continue
+		}
+		sort.Slice(fees, func(a, b int) bool { return fees[a].FeeRate() < fees[b].FeeRate() })
+		weights := make([]uint64, len(fees))
+		for j, fee := range fees {
+			weights[j] = fee.VSize
+		}
+		for j, index := range weightedPercentiles(weights, percentiles) {
+			history.SatPerVByte[i][j] = fees[index].FeeRate()
+		}
+	}
+	return history, nil
+}
@@ -0,0 +1,125 @@
+package bevm
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type testFeeBackend struct {
+	chain *core.BlockChain
+	db    ethdb.Database
+}
+
+func (b *testFeeBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.chain.CurrentHeader(), nil
+	}
+	return b.chain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *testFeeBackend) ChainDb() ethdb.Database {
+	return b.db
+}
+
+var testOracleConfig = gasprice.Config{
+	Blocks:           20,
+	Percentile:       60,
+	MaxHeaderHistory: 1024,
+	MaxBlockHistory:  1024,
+	Default:          big.NewInt(1),
+}
+
+// syncFeeTestChain syncs a chain over client and returns an oracle on top of it.
+func syncFeeTestChain(t *testing.T, blocks []*wire.MsgBlock, client *FixtureClient) (*core.BlockChain, ethdb.Database, *FeeOracle) {
+	chain, db := newVerifyTestChain(t, blocks[0])
+	miner := &Miner{
+		eth: NewMockBackend(chain, db),
+		bt:  NewBlockTranslatorWithClient(client),
+	}
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to sync fixture blocks: %v", err)
+	}
+	return chain, db, NewFeeOracle(&testFeeBackend{chain, db}, client, testOracleConfig)
+}
+
+func TestFeeOracleRecordedFees(t *testing.T) {
+	funding, spend := newDeployTxs(t, []byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x00}) // sstore(0, 1)
+	genesis := newBtcBlock(nil, 0)
+	block1 := newBtcBlock(genesis, 1, spend)
+	block2 := newBtcBlock(block1, 2)
+	client := NewFixtureClient(0, []*wire.MsgBlock{genesis, block1, block2}, []*wire.MsgTx{funding})
+	chain, db, oracle := syncFeeTestChain(t, []*wire.MsgBlock{genesis, block1, block2}, client)
+	defer chain.Stop()
+
+	hash := chain.GetHeaderByNumber(1).Hash()
+	fees := ReadBtcFees(db, hash)
+	if len(fees) != 1 {
+		t.Fatalf("fee count mismatch: have %d, want 1", len(fees))
+	}
+	receipts := chain.GetReceiptsByHash(hash)
+	if fee := fees[0]; fee.Fee != 1e4 || fee.GasUsed != receipts[0].GasUsed || fee.VSize == 0 {
+		t.Fatalf("fee mismatch: %+v", fee)
+	}
+	price, err := oracle.SuggestTipCap(context.Background())
+	if err != nil {
+		t.Fatalf("failed to suggest price: %v", err)
+	}
+	if price.Cmp(fees[0].GasPrice()) != 0 {
+		t.Fatalf("price mismatch: have %v, want %v", price, fees[0].GasPrice())
+	}
+
+	oldest, reward, baseFee, ratio, err := oracle.FeeHistory(context.Background(), 2, rpc.LatestBlockNumber, []float64{50})
+	if err != nil {
+		t.Fatalf("failed to get fee history: %v", err)
+	}
+	if oldest.Uint64() != 1 || len(reward) != 2 || len(baseFee) != 3 || len(ratio) != 2 {
+		t.Fatalf("fee history shape mismatch: %v, %d, %d, %d", oldest, len(reward), len(baseFee), len(ratio))
+	}
+	if reward[0][0].Cmp(price) != 0 || reward[1][0].Sign() != 0 {
+		t.Fatalf("reward mismatch: %v", reward)
+	}
+	if _, _, _, _, err := oracle.FeeHistory(context.Background(), 2, rpc.LatestBlockNumber, []float64{50, 10}); err == nil {
+		t.Fatal("accepted descending percentiles")
+	}
+
+	history, err := oracle.BtcFeeHistory(context.Background(), 3, rpc.LatestBlockNumber, []float64{10, 90})
+	if err != nil {
+		t.Fatalf("failed to get btc fee history: %v", err)
+	}
+	if history.OldestBlock.ToInt().Uint64() != 0 || len(history.TxCount) != 3 || history.TxCount[1] != 1 {
+		t.Fatalf("btc fee history mismatch: %+v", history)
+	}
+	if rate := fees[0].FeeRate(); history.SatPerVByte[1][0] != rate || history.SatPerVByte[1][1] != rate || history.SatPerVByte[2][0] != 0 {
+		t.Fatalf("fee rate mismatch: have %v, want %v", history.SatPerVByte, rate)
+	}
+}
+
+func TestFeeOracleFallback(t *testing.T) {
+	blocks := newBtcChain(3)
+	client := NewFixtureClient(0, blocks, nil)
+	client.FeeRate = 0.0001 // 10 sat/vB
+	chain, db, oracle := syncFeeTestChain(t, blocks, client)
+	defer chain.Stop()
+
+	price, err := oracle.SuggestTipCap(context.Background())
+	if err != nil {
+		t.Fatalf("failed to suggest price: %v", err)
+	}
+	if want := big.NewInt(5e8); price.Cmp(want) != 0 {
+		t.Fatalf("estimated price mismatch: have %v, want %v", price, want)
+	}
+
+	client.FeeRate = 0
+	oracle = NewFeeOracle(&testFeeBackend{chain, db}, client, testOracleConfig)
+	if price, err = oracle.SuggestTipCap(context.Background()); err != nil || price.Cmp(testOracleConfig.Default) != 0 {
+		t.Fatalf("default price mismatch: have %v, %v", price, err)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// The metrics are exported by the metrics/prometheus handler of the node with
+// the slashes replaced by underscores, e.g. bevm_sync_lag.
+var (
+	syncFetchTimer   = metrics.NewRegisteredTimer("bevm/sync/fetch", nil)
+	syncPrevOutTimer = metrics.NewRegisteredTimer("bevm/sync/prevouts", nil)
+	syncWaitTimer    = metrics.NewRegisteredTimer("bevm/sync/wait", nil)
+	syncQueueGauge   = metrics.NewRegisteredGauge("bevm/sync/queue", nil)
+
+	syncTipGauge     = metrics.NewRegisteredGauge("bevm/sync/tip", nil)      // best height of the BTC node
+	syncHeightGauge  = metrics.NewRegisteredGauge("bevm/sync/height", nil)   // height of the last translated BTC block
+	syncLagGauge     = metrics.NewRegisteredGauge("bevm/sync/lag", nil)      // number of BTC blocks not translated yet
+	syncReorgCounter = metrics.NewRegisteredCounter("bevm/sync/reorgs", nil) // BTC reorgs below the head detected by the miner
+
+	syncTranslateTimer = metrics.NewRegisteredTimer("bevm/sync/translate", nil) // per block
+	syncExecuteTimer   = metrics.NewRegisteredTimer("bevm/sync/execute", nil)   // per block
+
+	translateWitnessCounter = metrics.NewRegisteredCounter("bevm/translate/witnesses", nil) // EVM invocations found in BTC transactions
+
+	btcGetBlockTimer   = metrics.NewRegisteredTimer("bevm/btc/rpc/getblock", nil)
+	btcGetTxTimer      = metrics.NewRegisteredTimer("bevm/btc/rpc/getrawtransaction", nil)
+	btcRPCErrorCounter = metrics.NewRegisteredCounter("bevm/btc/rpc/errors", nil) // failed requests to an endpoint, retried ones included
+)
+
+// Reasons EVM invocations found in BTC transactions are rejected for.
+var (
+	errRelayDisabled    = errors.New("relayed transactions disabled")
+	errRelayInvalid     = errors.New("invalid relayed transaction")
+	errRelayUnprotected = errors.New("relayed transaction is not replay protected")
+	errRelaySignature   = errors.New("invalid relayed transaction signature")
+
+	errUnknownInvocation = errors.New("unknown evm invocation type")
+)
+
+// rejectReason returns the name of the reason an invocation was rejected for,
+// used in the bevm/translate/rejected/<reason> metrics.
+func rejectReason(err error) string {
+	switch {
+	case errors.Is(err, errRelayDisabled):
+		return "disabled"
+	case errors.Is(err, errRelayInvalid):
+		return "invalid"
+	case errors.Is(err, errRelayUnprotected):
+		return "unprotected"
+	case errors.Is(err, errRelaySignature):
+		return "signature"
+	case errors.Is(err, errUnknownInvocation):
+		return "unknown"
+	}
+	return "other"
+}
+
+// markRejected counts an invocation rejected with err.
+func markRejected(err error) {
+	metrics.GetOrRegisterCounter("bevm/translate/rejected/"+rejectReason(err), nil).Inc(1)
+}
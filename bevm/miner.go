@@ -1,9 +1,18 @@
 package bevm
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/consensus/layer2"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/trie"
 	"sync/atomic"
@@ -12,48 +21,134 @@ import (
 
 type Backend interface {
 	BlockChain() *core.BlockChain
+	ChainDb() ethdb.Database
 }
 
 type Miner struct {
-	eth    Backend
-	bt     *BlockTranslator
-	closed int32
+	eth          Backend
+	bt           *BlockTranslator
+	net          *chaincfg.Params // BTC network the chain is derived from, nil if unrecorded
+	verifyInsert bool             // re-validate executed blocks through InsertChain
+	prefetch     int              // number of BTC blocks fetched ahead of execution, 0 for none
+	checkpoints  *checkpointer    // nil if checkpoints are disabled
+	peers        *peerSet         // bevm/1 peers to import blocks from, nil if peer sync is disabled
+	sync         *syncTracker
+	closed       int32
+
+	btcBlockFeed   event.Feed
+	invocationFeed event.Feed
+	reorgFeed      event.Feed
+	scope          event.SubscriptionScope
 }
 
-func NewMiner(eth Backend, config *BtcRpcConfig) *Miner {
-	return &Miner{
-		eth:    eth,
-		bt:     NewBlockTranslator(config),
-		closed: 0,
+// NewMiner creates a miner translating the blocks of the BTC node given by
+// config. It refuses to build on a node serving another BTC network than the
+// one the chain is derived from.
+func NewMiner(eth Backend, config *BtcRpcConfig) (*Miner, error) {
+	bt, err := NewBlockTranslator(config)
+	if err != nil {
+		return nil, err
+	}
+	bt.SetChainID(eth.BlockChain().Config().ChainID)
+	bt.SetEngine(eth.BlockChain().Engine())
+	net, err := CheckChainNetwork(eth.BlockChain(), bt, config.Network)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints, err := newCheckpointer(bt.Client, net, eth.ChainDb(), config)
+	if err != nil {
+		return nil, err
 	}
+	return &Miner{
+		eth:          eth,
+		bt:           bt,
+		net:          net,
+		checkpoints:  checkpoints,
+		sync:         newSyncTracker(eth.BlockChain().CurrentHeader().Number.Uint64()),
+		verifyInsert: config.VerifyInsert,
+		prefetch:     config.Prefetch,
+		closed:       0,
+	}, nil
 }
 
 func (self *Miner) ExecuteBlock(block *types.Block) (*types.Block, types.Receipts, []*types.Log, uint64, error) {
-	prevHeader := self.eth.BlockChain().GetHeaderByNumber(block.NumberU64() - 1)
-	statedb, err := self.eth.BlockChain().StateAt(prevHeader.Root)
+	statedb, err := self.parentState(block)
+	if err != nil {
+		return nil, nil, nil, 0, err
+	}
+	return executeBlock(self.eth.BlockChain(), statedb, block)
+}
+
+func (self *Miner) parentState(block *types.Block) (*state.StateDB, error) {
+	chain := self.eth.BlockChain()
+	prevHeader := chain.GetHeaderByNumber(block.NumberU64() - 1)
+	if prevHeader == nil {
+		return nil, fmt.Errorf("missing parent header %d", block.NumberU64()-1)
+	}
+	return chain.StateAt(prevHeader.Root)
+}
+
+// executeBlock processes block on top of statedb and returns it sealed with the
+// resulting gas usage, bloom, receipt root and state root.
+func executeBlock(chain *core.BlockChain, statedb *state.StateDB, block *types.Block) (*types.Block, types.Receipts, []*types.Log, uint64, error) {
+	receipts, logs, usedGas, err := chain.Processor().Process(block, statedb, *chain.GetVMConfig())
 	if err != nil {
 		return nil, nil, nil, 0, err
 	}
-	receipts, logs, usedGas, err := self.eth.BlockChain().Processor().Process(block, statedb, *self.eth.BlockChain().GetVMConfig())
 	header := block.Header()
 	header.GasUsed = usedGas
 	header.Bloom = types.CreateBloom(receipts)
 	header.ReceiptHash = types.DeriveSha(receipts, trie.NewStackTrie(nil))
 	header.Root = statedb.IntermediateRoot(true)
+	if engine, ok := chain.Engine().(*layer2.Layer2Instant); ok && engine.IsCrossLayer(header.Number) {
+		// Carry the MMR of the cross layer messages, like FinalizeAndAssemble.
+		if size, root := engine.ExtractMMR(receipts); size != 0 {
+			header.Nonce, header.MixDigest = types.EncodeNonce(size), root
+		} else if parent := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1); parent != nil {
+			header.Nonce, header.MixDigest = parent.Nonce, parent.MixDigest
+		}
+	}
 	block = block.WithSeal(header)
 
+	// The receipts and logs were derived before sealing, point them at the
+	// final block hash.
+	hash := block.Hash()
+	for _, receipt := range receipts {
+		receipt.BlockHash = hash
+		for _, l := range receipt.Logs {
+			l.BlockHash = hash
+		}
+	}
 	return block, receipts, logs, usedGas, nil
 }
 
+// SubmitBlock executes the translated block and appends it to the chain. The
+// executed state is committed directly unless verifyInsert is set, in which
+// case the block is validated and executed again by InsertChain.
 func (self *Miner) SubmitBlock(block *types.Block) (*types.Block, error) {
-	sealed, _, _, _, err := self.ExecuteBlock(block)
+	statedb, err := self.parentState(block)
 	if err != nil {
 		return nil, err
 	}
-	_, err = self.eth.BlockChain().InsertChain([]*types.Block{sealed})
+	start := time.Now()
+	sealed, receipts, logs, _, err := executeBlock(self.eth.BlockChain(), statedb, block)
+	if err != nil {
+		return nil, err
+	}
+	if self.verifyInsert {
+		_, err = self.eth.BlockChain().InsertChain([]*types.Block{sealed})
+	} else {
+		_, err = self.eth.BlockChain().WriteExecutedBlock(sealed, receipts, logs, statedb, time.Since(start))
+	}
 	if err != nil {
 		return nil, err
 	}
+	if engine, ok := self.eth.BlockChain().Engine().(*layer2.Layer2Instant); ok && engine.IsCrossLayer(sealed.Number()) {
+		parent := self.eth.BlockChain().GetHeader(sealed.ParentHash(), sealed.NumberU64()-1)
+		if err := writeMessageTree(self.eth.ChainDb(), engine, parent, sealed.Header(), receipts); err != nil {
+			log.Error("Failed to index cross layer messages", "number", sealed.Number(), "err", err)
+		}
+	}
 	return sealed, nil
 }
 
@@ -63,6 +158,7 @@ func (self *Miner) Start() error {
 		err := self.loop()
 		if err != nil {
 			log.Info("Mint block error", "err", err)
+			self.sync.fail(err)
 		}
 
 		time.Sleep(time.Second * 10)
@@ -73,6 +169,7 @@ func (self *Miner) Start() error {
 
 func (self *Miner) Stop() error {
 	atomic.StoreInt32(&self.closed, 1)
+	self.scope.Close()
 	return nil
 }
 
@@ -84,43 +181,162 @@ func (self *Miner) loop() error {
 	}
 	header := self.eth.BlockChain().CurrentHeader()
 	currHeight := header.Number.Int64()
+	self.sync.setTip(uint64(bheight), uint64(currHeight))
 	log.Info("sync info:", "curr ledger height", currHeight, "btc height", bheight)
+	if self.peers != nil && currHeight < bheight {
+		// Catch up from peers first, translating the rest from BTC.
+		if header, err = self.syncFromPeers(header, bheight); err != nil {
+			log.Warn("Failed to sync blocks from peers", "err", err)
+		}
+		currHeight = header.Number.Int64()
+	}
 	if currHeight+1 > bheight {
+		if currHeight == 0 || currHeight != bheight {
+			return nil
+		}
+		// The BTC tip may have been replaced at the height of the head.
+		hash, err := client.GetBlockHash(currHeight)
+		if err != nil {
+			return fmt.Errorf("get btc block hash error: %v", err)
+		}
+		if header.UncleHash != BtcHashToEvmHash(*hash) {
+			syncReorgCounter.Inc(1)
+			log.Warn("BTC reorg at the head", "number", currHeight, "btc hash", hash, "uncle hash", header.UncleHash)
+			if err := self.rewind(header, bheight); err != nil {
+				return fmt.Errorf("rewind reorged blocks error: %v", err)
+			}
+		}
 		return nil
 	}
 
-	for currHeight+1 <= bheight {
-		log.Info("sync info:", "curr ledger height", currHeight, "btc height", bheight)
-		hash, err := client.GetBlockHash(currHeight + 1)
-		if err != nil {
-			return fmt.Errorf("get btc block hash error: %v", err)
+	fetch := func(height int64) (*prefetchedBlock, bool) {
+		block, prevOuts, err := self.bt.FetchBlock(height)
+		return &prefetchedBlock{height: height, block: block, prevOuts: prevOuts, err: err}, true
+	}
+	if self.prefetch > 0 && bheight-currHeight > 1 {
+		prefetcher := newBlockPrefetcher(self.bt, currHeight+1, bheight, self.prefetch)
+		defer prefetcher.close()
+		fetch = func(int64) (*prefetchedBlock, bool) { return prefetcher.next() }
+	}
+	progress := newSyncProgress(currHeight, bheight)
+	defer func() { progress.report(currHeight, true) }()
+
+	for currHeight+1 <= bheight && atomic.LoadInt32(&self.closed) == 0 {
+		log.Debug("sync info:", "curr ledger height", currHeight, "btc height", bheight)
+		fetched, ok := fetch(currHeight + 1)
+		if !ok {
+			return nil
 		}
-		log.Info("btc block hash", "hash", hash)
-		block, err := client.GetBlock(hash)
-		if err != nil {
-			return fmt.Errorf("get btc block error: %v", err)
+		if fetched.err != nil {
+			return fetched.err
 		}
+		block := fetched.block
 		prevHash := BtcHashToEvmHash(block.Header.PrevBlock)
 		if header.UncleHash != prevHash {
-			return fmt.Errorf("block reorged, btc hash: %s, uncle hash: %s", prevHash, header.UncleHash)
+			syncReorgCounter.Inc(1)
+			log.Warn("BTC reorg below the head", "number", currHeight, "btc prev hash", block.Header.PrevBlock, "uncle hash", header.UncleHash)
+			// The fetched blocks build on the new branch, translate it on the
+			// next round from the fork point.
+			if err := self.rewind(header, bheight); err != nil {
+				return fmt.Errorf("rewind reorged blocks error: %v", err)
+			}
+			return nil
 		}
-		log.Info("get btc block success", "hash", hash)
-		_, err = self.bt.PreparePrevOutPoint(block)
-		if err != nil {
-			return fmt.Errorf("get btc block prev output point error: %v", err)
+		log.Debug("get btc block success", "hash", block.BlockHash())
+		if err := self.writeBtcHeader(&block.Header); err != nil {
+			return fmt.Errorf("write btc block header error: %v", err)
 		}
-		log.Info("prepare btc block prev outpoint success")
 
-		eblock := self.bt.ParseBTCBlock(block, currHeight+1, header.Hash())
-		log.Info("parse btc block success")
+		eblock, invocations := self.bt.translateBlock(block, fetched.prevOuts, currHeight+1, header.Hash())
+		start := time.Now()
 		eblock, err = self.SubmitBlock(eblock)
 		if err != nil {
 			return fmt.Errorf("submit block error: %v", err)
 		}
-		log.Info("submit evm block success", "height", eblock.Header().Number.Int64())
+		syncExecuteTimer.UpdateSince(start)
+		self.postBlock(block.BlockHash(), eblock, invocations)
+		self.writeBtcFees(block, fetched.prevOuts, eblock)
+		if self.checkpoints != nil {
+			self.checkpoints.verify(self.eth.BlockChain(), self.eth.ChainDb(), block, fetched.prevOuts, uint64(currHeight+1))
+		}
+		log.Debug("submit evm block success", "height", eblock.Header().Number.Int64())
 		currHeight += 1
 		header = eblock.Header()
+		progress.report(currHeight, false)
+		self.sync.setHead(uint64(currHeight))
+	}
+	// Only commit once caught up, checkpoints of stale blocks help no one.
+	if self.checkpoints != nil && currHeight == bheight && self.checkpoints.due(header) {
+		if _, err := self.checkpoints.commit(header); err != nil {
+			log.Warn("Failed to commit btc checkpoint", "number", currHeight, "err", err)
+		}
 	}
 
 	return nil
 }
+
+// rewind rewinds the chain to the last block whose BTC block is still in the
+// best chain of the BTC node, and posts the ReorgEvent.
+func (self *Miner) rewind(head *types.Header, btcHeight int64) error {
+	chain := self.eth.BlockChain()
+	fork := head.Number.Uint64()
+	for ; fork > 0; fork-- {
+		header := chain.GetHeaderByNumber(fork)
+		if header == nil {
+			return fmt.Errorf("missing header %d", fork)
+		}
+		hash, err := self.bt.Client.GetBlockHash(int64(fork))
+		if err != nil {
+			return fmt.Errorf("get btc block hash error: %v", err)
+		}
+		if header.UncleHash == BtcHashToEvmHash(*hash) {
+			break
+		}
+	}
+	if fork == 0 {
+		// The genesis is never translated, check it as well.
+		hash, err := self.bt.Client.GetBlockHash(0)
+		if err != nil {
+			return fmt.Errorf("get btc block hash error: %v", err)
+		}
+		if chain.Genesis().UncleHash() != BtcHashToEvmHash(*hash) {
+			return fmt.Errorf("btc genesis %s does not match the chain", hash)
+		}
+	}
+	if err := chain.SetHead(fork); err != nil {
+		return err
+	}
+	// The state of the fork point may be gone, in which case the chain was
+	// rewound further.
+	current := chain.CurrentHeader()
+	if self.checkpoints != nil {
+		self.checkpoints.rewind(self.eth.ChainDb(), current.Number.Uint64())
+	}
+	log.Warn("Rewound reorged blocks", "fork", current.Number, "old head", head.Number, "btc height", btcHeight)
+	self.sync.setHead(current.Number.Uint64())
+	self.reorgFeed.Send(ReorgEvent{Fork: current.Number.Uint64(), OldHead: head.Number.Uint64(), NewHead: uint64(btcHeight)})
+	return nil
+}
+
+// writeBtcHeader persists the raw BTC header so that the relay precompiles can
+// serve it to contracts once the translated block is executed.
+func (self *Miner) writeBtcHeader(header *wire.BlockHeader) error {
+	var buf bytes.Buffer
+	if err := header.Serialize(&buf); err != nil {
+		return err
+	}
+	rawdb.WriteBtcHeader(self.eth.ChainDb(), BtcHashToEvmHash(header.BlockHash()), buf.Bytes())
+	return nil
+}
+
+// writeBtcFees records the fees paid by the BTC transactions carrying the
+// invocations of the executed block, for the fee oracle.
+func (self *Miner) writeBtcFees(bblock *wire.MsgBlock, prevOuts txscript.PrevOutputFetcher, block *types.Block) {
+	if len(block.Transactions()) == 0 {
+		return
+	}
+	receipts := self.eth.BlockChain().GetReceiptsByHash(block.Hash())
+	if fees := collectBtcFees(bblock, prevOuts, block.Transactions(), receipts); len(fees) > 0 {
+		WriteBtcFees(self.eth.ChainDb(), block.Hash(), fees)
+	}
+}
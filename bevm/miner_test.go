@@ -1,11 +1,13 @@
 package bevm
 
 import (
+	"github.com/btcsuite/btcd/wire"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/ethdb/memorydb"
 	"testing"
 )
@@ -27,21 +29,27 @@ func createMiner(t *testing.T) *Miner {
 		t.Fatalf("can't create new chain %v", err)
 	}
 
-	backend := NewMockBackend(bc)
-	return NewMiner(backend, &BtcRpcConfig{
+	backend := NewMockBackend(bc, chainDB)
+	miner, err := NewMiner(backend, &BtcRpcConfig{
 		Host: "127.0.0.1:12345",
 		User: "",
 		Pass: "",
 	})
+	if err != nil {
+		t.Fatalf("can't create miner: %v", err)
+	}
+	return miner
 }
 
 type mockBackend struct {
 	bc *core.BlockChain
+	db ethdb.Database
 }
 
-func NewMockBackend(bc *core.BlockChain) *mockBackend {
+func NewMockBackend(bc *core.BlockChain, db ethdb.Database) *mockBackend {
 	return &mockBackend{
 		bc: bc,
+		db: db,
 	}
 }
 
@@ -49,7 +57,50 @@ func (m *mockBackend) BlockChain() *core.BlockChain {
 	return m.bc
 }
 
+func (m *mockBackend) ChainDb() ethdb.Database {
+	return m.db
+}
+
 func TestBevmTx(t *testing.T) {
 	//	miner := createMiner(t)
 
 }
+
+func TestSubmitBlockDirectWrite(t *testing.T) {
+	funding, spend := newDeployTxs(t, []byte{0x60, 0x00, 0x60, 0x00, 0xa0, 0x00}) // log0(0, 0)
+	genesis := newBtcBlock(nil, 0)
+	block1 := newBtcBlock(genesis, 1, spend)
+	client := NewFixtureClient(0, []*wire.MsgBlock{genesis, block1}, []*wire.MsgTx{funding})
+
+	var heads []common.Hash
+	for _, verifyInsert := range []bool{false, true} {
+		chain, db := newVerifyTestChain(t, genesis)
+		defer chain.Stop()
+		miner := &Miner{
+			eth:          NewMockBackend(chain, db),
+			bt:           NewBlockTranslatorWithClient(client),
+			verifyInsert: verifyInsert,
+		}
+		if err := miner.loop(); err != nil {
+			t.Fatalf("verifyInsert %v: failed to sync fixture blocks: %v", verifyInsert, err)
+		}
+		head := chain.CurrentBlock()
+		if head.NumberU64() != 1 {
+			t.Fatalf("verifyInsert %v: head mismatch: have %d, want 1", verifyInsert, head.NumberU64())
+		}
+		receipts := chain.GetReceiptsByHash(head.Hash())
+		if len(receipts) != 1 || len(receipts[0].Logs) != 1 {
+			t.Fatalf("verifyInsert %v: receipt mismatch: %v", verifyInsert, receipts)
+		}
+		if receipts[0].Logs[0].BlockHash != head.Hash() {
+			t.Errorf("verifyInsert %v: log block hash mismatch: have %x, want %x", verifyInsert, receipts[0].Logs[0].BlockHash, head.Hash())
+		}
+		if lookup := rawdb.ReadTxLookupEntry(db, head.Transactions()[0].Hash()); lookup == nil || *lookup != 1 {
+			t.Errorf("verifyInsert %v: missing tx lookup entry", verifyInsert)
+		}
+		heads = append(heads, head.Hash())
+	}
+	if heads[0] != heads[1] {
+		t.Fatalf("head hash differs between direct write and verified insert: %x != %x", heads[0], heads[1])
+	}
+}
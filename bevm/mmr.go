@@ -0,0 +1,255 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/layer2"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	l2 "github.com/ethereum/go-ethereum/layer2"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// MessageProof proves that a cross layer message is included in the MMR of the
+// messages sent up to a block, in the form accepted by the verifyLeafHashInclusion
+// function of MerkleMountainRange.sol.
+type MessageProof struct {
+	MessageIndex hexutil.Uint64 `json:"messageIndex"`
+	Leaf         common.Hash    `json:"leaf"`     // crossLayerMessageHash of the message
+	Siblings     []common.Hash  `json:"siblings"` // audit path from the leaf up to the root
+	Peaks        []common.Hash  `json:"peaks"`    // roots of the perfect subtrees, left to right
+	MmrSize      hexutil.Uint64 `json:"mmrSize"`
+	MmrRoot      common.Hash    `json:"mmrRoot"`
+	BlockNumber  hexutil.Uint64 `json:"blockNumber"`
+	BlockHash    common.Hash    `json:"blockHash"`
+}
+
+// MessageHash returns the MMR leaf of a cross layer message, computed like the
+// crossLayerMessageHash function of CrossLayerCodec.sol.
+func MessageHash(target, sender common.Address, index uint64, message []byte) common.Hash {
+	return crypto.Keccak256Hash(target[:], sender[:], common.BigToHash(new(big.Int).SetUint64(index)).Bytes(), message)
+}
+
+func mmrHashChildren(left, right common.Hash) common.Hash {
+	return crypto.Keccak256Hash(left[:], right[:])
+}
+
+// mmrNodeCount returns the number of nodes of an MMR with size leaves, which is
+// also the position of the next leaf.
+func mmrNodeCount(size uint64) uint64 {
+	return 2*size - uint64(bits.OnesCount64(size))
+}
+
+// mmrPeakPositions returns the positions of the peaks of an MMR with size
+// leaves stored at offset, left to right.
+func mmrPeakPositions(size, offset uint64) []uint64 {
+	var positions []uint64
+	for height := 63; height >= 0; height-- {
+		if size&(1<<uint(height)) != 0 {
+			offset += 1<<uint(height+1) - 1
+			positions = append(positions, offset-1)
+		}
+	}
+	return positions
+}
+
+// mmrBagPeaks folds the peaks of an MMR from right to left into its root.
+func mmrBagPeaks(peaks []common.Hash) common.Hash {
+	if len(peaks) == 0 {
+		return common.Hash{}
+	}
+	root := peaks[len(peaks)-1]
+	for i := len(peaks) - 2; i >= 0; i-- {
+		root = mmrHashChildren(peaks[i], root)
+	}
+	return root
+}
+
+// messageTree is the MMR of the messages sent through the L2 cross layer
+// witness, the counterpart of the CompactMerkleTree of MerkleMountainRange.sol.
+// Nodes are stored in the order the contract computes them: every leaf followed
+// by the parents it completes. Appending at an earlier size overwrites the
+// nodes above it, which rewinds the tree on reorgs.
+type messageTree struct {
+	db ethdb.KeyValueStore
+}
+
+func (t *messageTree) nodes(positions []uint64) ([]common.Hash, error) {
+	nodes := make([]common.Hash, len(positions))
+	for i, pos := range positions {
+		if nodes[i] = rawdb.ReadMmrNode(t.db, pos); nodes[i] == (common.Hash{}) {
+			return nil, fmt.Errorf("missing mmr node %d", pos)
+		}
+	}
+	return nodes, nil
+}
+
+// peaks returns the peaks of the tree with size leaves.
+func (t *messageTree) peaks(size uint64) ([]common.Hash, error) {
+	return t.nodes(mmrPeakPositions(size, 0))
+}
+
+// append adds leaf to the tree with size leaves and returns the new root.
+func (t *messageTree) append(size uint64, leaf common.Hash) (common.Hash, error) {
+	peaks, err := t.peaks(size)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	pos := mmrNodeCount(size)
+	rawdb.WriteMmrNode(t.db, pos, leaf)
+	for s := size; s%2 == 1; s >>= 1 {
+		pos++
+		leaf = mmrHashChildren(peaks[len(peaks)-1], leaf)
+		peaks = peaks[:len(peaks)-1]
+		rawdb.WriteMmrNode(t.db, pos, leaf)
+	}
+	return mmrBagPeaks(append(peaks, leaf)), nil
+}
+
+// proof returns the leaf of the given index and its audit path in the tree with
+// size leaves.
+func (t *messageTree) proof(index, size uint64) (common.Hash, []common.Hash, error) {
+	if index >= size {
+		return common.Hash{}, nil, fmt.Errorf("message %d not in mmr of size %d", index, size)
+	}
+	leaf := rawdb.ReadMmrNode(t.db, mmrNodeCount(index))
+	if leaf == (common.Hash{}) {
+		return common.Hash{}, nil, fmt.Errorf("missing mmr leaf %d", index)
+	}
+	var (
+		path   []common.Hash
+		offset uint64
+	)
+	// Descend from the root, collecting the sibling subtree of every level.
+	for n := size; n > 1; {
+		k := uint64(1) << uint(bits.Len64(n-1)-1) // leaves of the full left subtree
+		if index < k {
+			peaks, err := t.nodes(mmrPeakPositions(n-k, offset+2*k-1))
+			if err != nil {
+				return common.Hash{}, nil, err
+			}
+			path = append(path, mmrBagPeaks(peaks))
+			n = k
+		} else {
+			offset += 2*k - 1
+			left, err := t.nodes([]uint64{offset - 1})
+			if err != nil {
+				return common.Hash{}, nil, err
+			}
+			path = append(path, left[0])
+			index, n = index-k, n-k
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return leaf, path, nil
+}
+
+// VerifyMessageProof checks that leaf is the message of the given index in the
+// MMR with size leaves and the given root, like verifyLeafHashInclusion of
+// MerkleMountainRange.sol.
+func VerifyMessageProof(leaf common.Hash, index uint64, siblings []common.Hash, root common.Hash, size uint64) error {
+	if index >= size {
+		return errors.New("leaf index out of bounds")
+	}
+	var pos int
+	for last := size - 1; last > 0; last >>= 1 {
+		if pos >= len(siblings) {
+			return errors.New("proof too short")
+		}
+		if index%2 == 1 {
+			leaf = mmrHashChildren(siblings[pos], leaf)
+			pos++
+		} else if index < last {
+			leaf = mmrHashChildren(leaf, siblings[pos])
+			pos++
+		}
+		index >>= 1
+	}
+	if pos < len(siblings) {
+		return errors.New("proof too long")
+	}
+	if leaf != root {
+		return errors.New("mmr root differ")
+	}
+	return nil
+}
+
+// writeMessageTree appends the messages sent by the receipts of block to the
+// message tree, starting from the MMR of parent.
+func writeMessageTree(db ethdb.KeyValueStore, engine *layer2.Layer2Instant, parent, header *types.Header, receipts types.Receipts) error {
+	var (
+		tree = &messageTree{db: db}
+		size = parent.Nonce.Uint64()
+		root = parent.MixDigest
+	)
+	for _, receipt := range receipts {
+		for _, l := range receipt.Logs {
+			if l.Address != engine.L2CrossLayerWitness || len(l.Topics) == 0 || l.Topics[0] != l2.MessageSentEventID {
+				continue
+			}
+			event, err := l2.ParseMessageSentEvent(l)
+			if err != nil {
+				return err
+			}
+			if event.MessageIndex != size {
+				return fmt.Errorf("message index %d, mmr size %d", event.MessageIndex, size)
+			}
+			leaf := MessageHash(common.Address(event.Target), common.Address(event.Sender), event.MessageIndex, event.Message)
+			if root, err = tree.append(size, leaf); err != nil {
+				return err
+			}
+			size++
+			if root != event.MmrRoot {
+				return fmt.Errorf("mmr root %x of message %d, contract computed %x", root, event.MessageIndex, common.Hash(event.MmrRoot))
+			}
+		}
+	}
+	if size != header.Nonce.Uint64() || root != header.MixDigest {
+		return fmt.Errorf("mmr %d [%x] differs from block header %d [%x]", size, root, header.Nonce.Uint64(), header.MixDigest)
+	}
+	if size != parent.Nonce.Uint64() {
+		log.Debug("Indexed cross layer messages", "number", header.Number, "mmrsize", size, "root", root)
+	}
+	return nil
+}
+
+// messageProof proves the message of the given index against the MMR of the
+// given block.
+func messageProof(db ethdb.KeyValueStore, header *types.Header, index uint64) (*MessageProof, error) {
+	var (
+		tree = &messageTree{db: db}
+		size = header.Nonce.Uint64()
+	)
+	leaf, siblings, err := tree.proof(index, size)
+	if err != nil {
+		return nil, err
+	}
+	peaks, err := tree.peaks(size)
+	if err != nil {
+		return nil, err
+	}
+	if root := mmrBagPeaks(peaks); root != header.MixDigest {
+		return nil, fmt.Errorf("message tree not indexed at block %d", header.Number)
+	}
+	return &MessageProof{
+		MessageIndex: hexutil.Uint64(index),
+		Leaf:         leaf,
+		Siblings:     siblings,
+		Peaks:        peaks,
+		MmrSize:      hexutil.Uint64(size),
+		MmrRoot:      header.MixDigest,
+		BlockNumber:  hexutil.Uint64(header.Number.Uint64()),
+		BlockHash:    header.Hash(),
+	}, nil
+}
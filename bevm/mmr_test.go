@@ -0,0 +1,191 @@
+package bevm
+
+import (
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/layer2"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// crossLayerWitnessABI is the part of the L2CrossLayerWitness interface used by
+// the tests; testdata/l2crosslayerwitness.bin is its runtime code, as compiled
+// by the rollup-contracts module.
+const crossLayerWitnessABI = `[
+{"inputs":[{"name":"_target","type":"address"},{"name":"_message","type":"bytes"}],"name":"sendMessage","outputs":[],"stateMutability":"nonpayable","type":"function"},
+{"inputs":[{"name":"_target","type":"address"},{"name":"_sender","type":"address"},{"name":"_message","type":"bytes"},{"name":"_messageIndex","type":"uint64"},{"name":"_proof","type":"bytes32[]"},{"name":"_mmrSize","type":"uint64"}],"name":"replayMessage","outputs":[{"name":"","type":"bool"}],"stateMutability":"nonpayable","type":"function"}
+]`
+
+func TestMessageTree(t *testing.T) {
+	code, err := ioutil.ReadFile("testdata/l2crosslayerwitness.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	witnessABI, err := abi.JSON(strings.NewReader(crossLayerWitnessABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		witness    = common.HexToAddress("0x2210000000000000000000000000000000000000")
+		sender     = common.HexToAddress("0x5e4de4")
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		cfg        = &runtime.Config{State: statedb, Origin: sender, GasLimit: 10000000}
+	)
+	statedb.SetCode(witness, common.FromHex(strings.TrimSpace(string(code))))
+
+	// Send messages through the contract and index them from its events.
+	const count = 13
+	var targets []common.Address
+	var messages [][]byte
+	for i := 0; i < count; i++ {
+		targets = append(targets, common.BigToAddress(big.NewInt(int64(0x1000+i))))
+		messages = append(messages, []byte(strings.Repeat("m", i)))
+		input, _ := witnessABI.Pack("sendMessage", targets[i], messages[i])
+		if _, _, err := runtime.Call(witness, input, cfg); err != nil {
+			t.Fatalf("failed to send message %d: %v", i, err)
+		}
+	}
+	logs := statedb.Logs()
+	if len(logs) != count {
+		t.Fatalf("event count mismatch: have %d, want %d", len(logs), count)
+	}
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		engine = layer2.New(&params.Layer2InstantConfig{L2CrossLayerWitness: witness})
+		roots  []common.Hash
+	)
+	for i, l := range logs {
+		roots = append(roots, common.BytesToHash(l.Data[:32]))
+		// Index every message in its own block, the last one along with two more.
+		if i == count-3 || i == count-2 {
+			continue
+		}
+		first := i
+		if i == count-1 {
+			first = count - 3
+		}
+		parent := &types.Header{Number: big.NewInt(int64(first)), Nonce: types.EncodeNonce(uint64(first))}
+		if first > 0 {
+			parent.MixDigest = roots[first-1]
+		}
+		header := &types.Header{Number: big.NewInt(int64(first + 1)), Nonce: types.EncodeNonce(uint64(i + 1)), MixDigest: roots[i]}
+		receipts := types.Receipts{{Logs: logs[first : i+1]}}
+		if err := writeMessageTree(db, engine, parent, header, receipts); err != nil {
+			t.Fatalf("failed to index message %d: %v", i, err)
+		}
+	}
+	tree := &messageTree{db: db}
+	for size := uint64(1); size <= count; size++ {
+		header := &types.Header{Number: big.NewInt(int64(size)), Nonce: types.EncodeNonce(size), MixDigest: roots[size-1]}
+		for index := uint64(0); index < size; index++ {
+			proof, err := messageProof(db, header, index)
+			if err != nil {
+				t.Fatalf("size %d index %d: failed to prove: %v", size, index, err)
+			}
+			if want := MessageHash(targets[index], sender, index, messages[index]); proof.Leaf != want {
+				t.Fatalf("size %d index %d: leaf mismatch: have %x, want %x", size, index, proof.Leaf, want)
+			}
+			if err := VerifyMessageProof(proof.Leaf, index, proof.Siblings, proof.MmrRoot, size); err != nil {
+				t.Fatalf("size %d index %d: invalid proof: %v", size, index, err)
+			}
+			if root := mmrBagPeaks(proof.Peaks); root != roots[size-1] {
+				t.Fatalf("size %d: peaks root mismatch: have %x, want %x", size, root, roots[size-1])
+			}
+			if len(proof.Siblings) > 0 {
+				forged := append([]common.Hash{}, proof.Siblings...)
+				forged[0][0] ^= 1
+				if VerifyMessageProof(proof.Leaf, index, forged, proof.MmrRoot, size) == nil {
+					t.Fatalf("size %d index %d: forged proof accepted", size, index)
+				}
+			}
+		}
+	}
+	if _, _, err := tree.proof(count, count); err == nil {
+		t.Fatal("proved message beyond the tree")
+	}
+
+	// The contract accepts the proofs too: register the roots like a failed
+	// relay does and replay every message against the final tree.
+	mmrRootSlot := func(size uint64) common.Hash {
+		return crypto.Keccak256Hash(common.BigToHash(new(big.Int).SetUint64(size)).Bytes(), common.BigToHash(big.NewInt(5)).Bytes())
+	}
+	header := &types.Header{Number: big.NewInt(count), Nonce: types.EncodeNonce(count), MixDigest: roots[count-1]}
+	statedb.SetState(witness, mmrRootSlot(count), roots[count-1])
+	for index := uint64(0); index < count; index++ {
+		proof, _ := messageProof(db, header, index)
+		siblings := make([][32]byte, len(proof.Siblings))
+		for i, sibling := range proof.Siblings {
+			siblings[i] = sibling
+		}
+		input, _ := witnessABI.Pack("replayMessage", targets[index], sender, messages[index], index, siblings, uint64(count))
+		if len(siblings) > 0 {
+			// A forged proof is rejected by the contract.
+			forged := append([][32]byte{}, siblings...)
+			forged[len(forged)-1][31] ^= 1
+			bad, _ := witnessABI.Pack("replayMessage", targets[index], sender, messages[index], index, forged, uint64(count))
+			if _, _, err := runtime.Call(witness, bad, cfg); err == nil {
+				t.Fatalf("message %d: contract accepted forged proof", index)
+			}
+		}
+		if _, _, err := runtime.Call(witness, input, cfg); err != nil {
+			t.Fatalf("message %d: contract rejected proof: %v", index, err)
+		}
+	}
+}
+
+func TestCrossLayerFork(t *testing.T) {
+	genesis := newBtcBlock(nil, 0)
+	block1 := newBtcBlock(genesis, 1)
+	block2 := newBtcBlock(block1, 2)
+	client := NewFixtureClient(0, []*wire.MsgBlock{genesis, block1, block2}, nil)
+	crossLayer := &params.Layer2InstantConfig{
+		L2CrossLayerWitness: common.HexToAddress("0x2210000000000000000000000000000000000000"),
+		FeeCollector:        common.HexToAddress("0xfee"),
+	}
+	sync := func(config *params.ChainConfig) *core.BlockChain {
+		chain, db := newVerifyTestChainWithConfig(t, genesis, config, CreateConsensusEngine(config))
+		bt := NewBlockTranslatorWithClient(client)
+		bt.SetEngine(chain.Engine())
+		// Insert through InsertChain, so that the headers are verified.
+		miner := &Miner{eth: NewMockBackend(chain, db), bt: bt, verifyInsert: true}
+		if err := miner.loop(); err != nil {
+			t.Fatalf("failed to sync fixture blocks: %v", err)
+		}
+		return chain
+	}
+	plain := sync(newVerifyTestConfig())
+	defer plain.Stop()
+
+	// Configuring the engine without the fork keeps the blocks of the chain.
+	config := newVerifyTestConfig()
+	config.Layer2Instant = crossLayer
+	unforked := sync(config)
+	defer unforked.Stop()
+	for number := uint64(1); number <= 2; number++ {
+		if have, want := unforked.GetHeaderByNumber(number).Hash(), plain.GetHeaderByNumber(number).Hash(); have != want {
+			t.Fatalf("block %d hash changed without fork: have %x, want %x", number, have, want)
+		}
+	}
+	// The fee collector is the coinbase from the fork on.
+	config = newVerifyTestConfig()
+	config.Layer2Instant, config.CrossLayerBlock = crossLayer, big.NewInt(2)
+	forked := sync(config)
+	defer forked.Stop()
+	if coinbase := forked.GetHeaderByNumber(1).Coinbase; coinbase != (common.Address{}) {
+		t.Fatalf("coinbase before the fork: %x", coinbase)
+	}
+	if coinbase := forked.GetHeaderByNumber(2).Coinbase; coinbase != crossLayer.FeeCollector {
+		t.Fatalf("coinbase mismatch: have %x, want %x", coinbase, crossLayer.FeeCollector)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/ethereum/go-ethereum/bevm/protocol"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// btcNetwork resolves the BTC network of a chain from its config and the
+// network requested by the user, which must agree. Chains created before the
+// network was recorded at genesis resolve to nil unless one is requested.
+func btcNetwork(config *params.ChainConfig, requested string) (*chaincfg.Params, error) {
+	name := config.BtcNetwork
+	if requested != "" {
+		if name != "" && name != requested {
+			return nil, fmt.Errorf("chain is derived from btc network %s, not %s", name, requested)
+		}
+		name = requested
+	}
+	if name == "" {
+		return nil, nil
+	}
+	return protocol.NetParamsByName(name)
+}
+
+// CheckNetwork verifies that the BTC node serves net, if known, and that its
+// genesis block is the one the bevm genesis was derived from.
+func (self *BlockTranslator) CheckNetwork(net *chaincfg.Params, genesis *types.Header) error {
+	info, err := self.Client.GetBlockChainInfo()
+	if err != nil {
+		return fmt.Errorf("get btc chain info error: %v", err)
+	}
+	hash, err := self.Client.GetBlockHash(0)
+	if err != nil {
+		return fmt.Errorf("get btc genesis hash error: %v", err)
+	}
+	if net != nil {
+		if !protocol.IsChain(net, info.Chain) {
+			return fmt.Errorf("btc node serves chain %s, expected %s", info.Chain, protocol.ChainName(net))
+		}
+		if !net.GenesisHash.IsEqual(hash) {
+			return fmt.Errorf("btc node genesis %s does not match network %s genesis %s", hash, net.Name, net.GenesisHash)
+		}
+	}
+	if genesis.UncleHash == types.EmptyUncleHash {
+		log.Warn("Bevm genesis is not derived from a btc block, skip genesis check")
+		return nil
+	}
+	if derived := BtcHashToEvmHash(*hash); derived != genesis.UncleHash {
+		return fmt.Errorf("bevm genesis is derived from btc block %x, node genesis is %s", genesis.UncleHash, hash)
+	}
+	return nil
+}
+
+// CheckChainNetwork resolves the BTC network of chain and makes sure the node
+// behind bt serves it. requested is the network asked for by the user, if any.
+func CheckChainNetwork(chain *core.BlockChain, bt *BlockTranslator, requested string) (*chaincfg.Params, error) {
+	net, err := btcNetwork(chain.Config(), requested)
+	if err != nil {
+		return nil, err
+	}
+	if net == nil {
+		log.Warn("Chain config does not record its btc network, only the genesis is checked")
+	}
+	if err := bt.CheckNetwork(net, chain.Genesis().Header()); err != nil {
+		return nil, err
+	}
+	return net, nil
+}
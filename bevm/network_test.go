@@ -0,0 +1,50 @@
+package bevm
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestCheckChainNetwork(t *testing.T) {
+	genesis := chaincfg.RegressionNetParams.GenesisBlock
+	chain, _ := newVerifyTestChain(t, genesis)
+	defer chain.Stop()
+	client := NewFixtureClient(0, []*wire.MsgBlock{genesis}, nil)
+	client.Chain = "regtest"
+	bt := NewBlockTranslatorWithClient(client)
+
+	// Without a recorded network only the genesis is checked.
+	if net, err := CheckChainNetwork(chain, bt, ""); err != nil || net != nil {
+		t.Fatalf("unrecorded network: have %v, %v", net, err)
+	}
+	if net, err := CheckChainNetwork(chain, bt, "regtest"); err != nil || net != &chaincfg.RegressionNetParams {
+		t.Fatalf("regtest network: have %v, %v", net, err)
+	}
+	if _, err := CheckChainNetwork(chain, bt, "signet"); err == nil {
+		t.Fatal("accepted a regtest node for signet")
+	}
+	client.Chain = "main"
+	if _, err := CheckChainNetwork(chain, bt, "regtest"); err == nil {
+		t.Fatal("accepted a mainnet node for regtest")
+	}
+
+	// A node on another chain with the same name is rejected by its genesis.
+	other := NewFixtureClient(0, []*wire.MsgBlock{newBtcBlock(nil, 0)}, nil)
+	other.Chain = "regtest"
+	if _, err := CheckChainNetwork(chain, NewBlockTranslatorWithClient(other), ""); err == nil {
+		t.Fatal("accepted a node with another genesis")
+	}
+}
+
+func TestBtcNetworkConflict(t *testing.T) {
+	config := &params.ChainConfig{BtcNetwork: "regtest"}
+	if net, err := btcNetwork(config, ""); err != nil || net != &chaincfg.RegressionNetParams {
+		t.Fatalf("recorded network: have %v, %v", net, err)
+	}
+	if _, err := btcNetwork(config, "mainnet"); err == nil {
+		t.Fatal("requested network overrides the recorded one")
+	}
+}
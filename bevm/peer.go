@@ -0,0 +1,557 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ProtocolName is the devp2p capability name of the bevm block exchange
+// protocol.
+const ProtocolName = "bevm"
+
+// ProtocolVersion is the version of the bevm block exchange protocol.
+const ProtocolVersion = 1
+
+// protocolLength is the number of message codes of bevm/1.
+const protocolLength = 7
+
+const (
+	peerStatusMsg      = 0x00
+	getBlockHeadersMsg = 0x01
+	blockHeadersMsg    = 0x02
+	getBlockBodiesMsg  = 0x03
+	blockBodiesMsg     = 0x04
+	getReceiptsMsg     = 0x05
+	receiptsMsg        = 0x06
+)
+
+const (
+	peerMaxMessageSize    = 10 * 1024 * 1024 // maximum cap on the size of a protocol message
+	peerSoftResponseLimit = 2 * 1024 * 1024  // target size of the served bodies and receipts
+	peerHandshakeTimeout  = 5 * time.Second
+	peerRequestTimeout    = 20 * time.Second
+
+	maxPeerHeaders  = 192 // maximum number of headers served per request
+	maxPeerBodies   = 128 // maximum number of block bodies served per request
+	maxPeerReceipts = 128 // maximum number of block receipts served per request
+)
+
+var (
+	errPeerClosed      = errors.New("peer closed")
+	errPeerTimeout     = errors.New("peer request timed out")
+	errUnexpectedReply = errors.New("unexpected peer reply")
+)
+
+// peerStatus is the handshake of bevm/1, announcing the chain and head of a
+// node.
+type peerStatus struct {
+	Version   uint32
+	NetworkID uint64
+	Genesis   common.Hash
+	Head      common.Hash
+	Number    uint64
+}
+
+// getBlockHeadersPacket requests amount canonical headers from number origin.
+type getBlockHeadersPacket struct {
+	ID     uint64
+	Origin uint64
+	Amount uint64
+}
+
+type blockHeadersPacket struct {
+	ID      uint64
+	Headers []*types.Header
+}
+
+type getBlockBodiesPacket struct {
+	ID     uint64
+	Hashes []common.Hash
+}
+
+// peerBlockBody is the body of a bevm block: its transactions and the raw BTC
+// header it was translated from, which the relay precompiles serve.
+type peerBlockBody struct {
+	Transactions []*types.Transaction
+	BtcHeader    []byte
+}
+
+type blockBodiesPacket struct {
+	ID     uint64
+	Bodies []*peerBlockBody
+}
+
+type getReceiptsPacket struct {
+	ID     uint64
+	Hashes []common.Hash
+}
+
+type receiptsPacket struct {
+	ID       uint64
+	Receipts [][]*types.Receipt
+}
+
+// bevmPeer is a remote node speaking bevm/1.
+type bevmPeer struct {
+	*p2p.Peer
+	id string
+	rw p2p.MsgReadWriter
+
+	lock    sync.Mutex
+	head    common.Hash
+	number  uint64
+	nextID  uint64
+	pending map[uint64]chan interface{} // replies awaited by request id
+	closed  chan struct{}
+}
+
+func newBevmPeer(p *p2p.Peer, rw p2p.MsgReadWriter) *bevmPeer {
+	return &bevmPeer{
+		Peer:    p,
+		id:      p.ID().String(),
+		rw:      rw,
+		pending: make(map[uint64]chan interface{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+// Head returns the head announced by the peer.
+func (p *bevmPeer) Head() (common.Hash, uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.head, p.number
+}
+
+func (p *bevmPeer) setHead(hash common.Hash, number uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if number > p.number {
+		p.head, p.number = hash, number
+	}
+}
+
+// handshake exchanges the status of both nodes and checks that they follow the
+// same chain.
+func (p *bevmPeer) handshake(status *peerStatus) error {
+	errc := make(chan error, 2)
+	var remote peerStatus
+	go func() {
+		errc <- p2p.Send(p.rw, peerStatusMsg, status)
+	}()
+	go func() {
+		errc <- p.readStatus(status, &remote)
+	}()
+	timeout := time.NewTimer(peerHandshakeTimeout)
+	defer timeout.Stop()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errc:
+			if err != nil {
+				return err
+			}
+		case <-timeout.C:
+			return p2p.DiscReadTimeout
+		}
+	}
+	p.head, p.number = remote.Head, remote.Number
+	return nil
+}
+
+func (p *bevmPeer) readStatus(local, remote *peerStatus) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	defer msg.Discard()
+	if msg.Code != peerStatusMsg {
+		return fmt.Errorf("first message is %#x, not status", msg.Code)
+	}
+	if msg.Size > peerMaxMessageSize {
+		return fmt.Errorf("message too large: %d > %d", msg.Size, peerMaxMessageSize)
+	}
+	if err := msg.Decode(remote); err != nil {
+		return fmt.Errorf("invalid status: %v", err)
+	}
+	if remote.Version != local.Version {
+		return fmt.Errorf("protocol version mismatch: %d, local %d", remote.Version, local.Version)
+	}
+	if remote.NetworkID != local.NetworkID {
+		return fmt.Errorf("network id mismatch: %d, local %d", remote.NetworkID, local.NetworkID)
+	}
+	if remote.Genesis != local.Genesis {
+		return fmt.Errorf("genesis mismatch: %x, local %x", remote.Genesis, local.Genesis)
+	}
+	return nil
+}
+
+// request sends a request and waits for the reply carrying the same id.
+func (p *bevmPeer) request(code uint64, packet func(id uint64) interface{}) (interface{}, error) {
+	reply := make(chan interface{}, 1)
+	p.lock.Lock()
+	p.nextID++
+	id := p.nextID
+	p.pending[id] = reply
+	p.lock.Unlock()
+
+	defer func() {
+		p.lock.Lock()
+		delete(p.pending, id)
+		p.lock.Unlock()
+	}()
+	if err := p2p.Send(p.rw, code, packet(id)); err != nil {
+		return nil, err
+	}
+	timeout := time.NewTimer(peerRequestTimeout)
+	defer timeout.Stop()
+	select {
+	case res := <-reply:
+		return res, nil
+	case <-timeout.C:
+		return nil, errPeerTimeout
+	case <-p.closed:
+		return nil, errPeerClosed
+	}
+}
+
+// deliver hands a reply to the request waiting for it.
+func (p *bevmPeer) deliver(id uint64, res interface{}) error {
+	p.lock.Lock()
+	reply, ok := p.pending[id]
+	p.lock.Unlock()
+	if !ok {
+		return errUnexpectedReply
+	}
+	select {
+	case reply <- res:
+		return nil
+	default:
+		return errUnexpectedReply
+	}
+}
+
+// RequestHeaders fetches up to amount canonical headers starting at origin.
+func (p *bevmPeer) RequestHeaders(origin, amount uint64) ([]*types.Header, error) {
+	res, err := p.request(getBlockHeadersMsg, func(id uint64) interface{} {
+		return &getBlockHeadersPacket{ID: id, Origin: origin, Amount: amount}
+	})
+	if err != nil {
+		return nil, err
+	}
+	headers, ok := res.([]*types.Header)
+	if !ok {
+		return nil, errUnexpectedReply
+	}
+	if uint64(len(headers)) > amount {
+		return nil, fmt.Errorf("%d headers, requested %d", len(headers), amount)
+	}
+	for i, header := range headers {
+		if header.Number == nil || header.Number.Uint64() != origin+uint64(i) {
+			return nil, fmt.Errorf("header %d has number %v, requested %d", i, header.Number, origin+uint64(i))
+		}
+	}
+	return headers, nil
+}
+
+// RequestBodies fetches the bodies of the blocks with the given hashes. The
+// peer may serve only a prefix of them.
+func (p *bevmPeer) RequestBodies(hashes []common.Hash) ([]*peerBlockBody, error) {
+	res, err := p.request(getBlockBodiesMsg, func(id uint64) interface{} {
+		return &getBlockBodiesPacket{ID: id, Hashes: hashes}
+	})
+	if err != nil {
+		return nil, err
+	}
+	bodies, ok := res.([]*peerBlockBody)
+	if !ok {
+		return nil, errUnexpectedReply
+	}
+	if len(bodies) > len(hashes) {
+		return nil, fmt.Errorf("%d bodies, requested %d", len(bodies), len(hashes))
+	}
+	return bodies, nil
+}
+
+// RequestReceipts fetches the receipts of the blocks with the given hashes.
+// The peer may serve only a prefix of them.
+func (p *bevmPeer) RequestReceipts(hashes []common.Hash) ([][]*types.Receipt, error) {
+	res, err := p.request(getReceiptsMsg, func(id uint64) interface{} {
+		return &getReceiptsPacket{ID: id, Hashes: hashes}
+	})
+	if err != nil {
+		return nil, err
+	}
+	receipts, ok := res.([][]*types.Receipt)
+	if !ok {
+		return nil, errUnexpectedReply
+	}
+	if len(receipts) > len(hashes) {
+		return nil, fmt.Errorf("%d receipt lists, requested %d", len(receipts), len(hashes))
+	}
+	return receipts, nil
+}
+
+// peerInfo is the bevm/1 metadata reported by admin_peers.
+type peerInfo struct {
+	Version uint   `json:"version"`
+	Head    string `json:"head"`
+	Number  uint64 `json:"number"`
+}
+
+// peerSet is the set of connected bevm/1 peers.
+type peerSet struct {
+	lock  sync.RWMutex
+	peers map[string]*bevmPeer
+}
+
+func newPeerSet() *peerSet {
+	return &peerSet{peers: make(map[string]*bevmPeer)}
+}
+
+func (ps *peerSet) register(p *bevmPeer) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	if _, ok := ps.peers[p.id]; ok {
+		return p2p.DiscAlreadyConnected
+	}
+	ps.peers[p.id] = p
+	return nil
+}
+
+func (ps *peerSet) unregister(id string) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	delete(ps.peers, id)
+}
+
+func (ps *peerSet) peer(id string) *bevmPeer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+	return ps.peers[id]
+}
+
+// Len returns the number of connected peers.
+func (ps *peerSet) Len() int {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+	return len(ps.peers)
+}
+
+// best returns the peer announcing the highest head, nil if there are none.
+func (ps *peerSet) best() *bevmPeer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+	var (
+		best   *bevmPeer
+		number uint64
+	)
+	for _, p := range ps.peers {
+		if _, n := p.Head(); best == nil || n > number {
+			best, number = p, n
+		}
+	}
+	return best
+}
+
+// peerHandler serves the local chain to bevm/1 peers and tracks them for the
+// peer sync of the miner.
+type peerHandler struct {
+	chain     *core.BlockChain
+	networkID uint64
+	peers     *peerSet
+}
+
+func newPeerHandler(chain *core.BlockChain, networkID uint64) *peerHandler {
+	return &peerHandler{
+		chain:     chain,
+		networkID: networkID,
+		peers:     newPeerSet(),
+	}
+}
+
+// Protocols returns the devp2p protocols to register on the node.
+func (h *peerHandler) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    ProtocolName,
+		Version: ProtocolVersion,
+		Length:  protocolLength,
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			return h.runPeer(newBevmPeer(p, rw))
+		},
+		PeerInfo: func(id enode.ID) interface{} {
+			if p := h.peers.peer(id.String()); p != nil {
+				hash, number := p.Head()
+				return &peerInfo{Version: ProtocolVersion, Head: hash.Hex(), Number: number}
+			}
+			return nil
+		},
+	}}
+}
+
+// runPeer performs the handshake with a newly connected peer and serves its
+// messages until the connection drops.
+func (h *peerHandler) runPeer(p *bevmPeer) error {
+	head := h.chain.CurrentHeader()
+	status := &peerStatus{
+		Version:   ProtocolVersion,
+		NetworkID: h.networkID,
+		Genesis:   h.chain.Genesis().Hash(),
+		Head:      head.Hash(),
+		Number:    head.Number.Uint64(),
+	}
+	if err := p.handshake(status); err != nil {
+		p.Log().Debug("Bevm handshake failed", "err", err)
+		return err
+	}
+	if err := h.peers.register(p); err != nil {
+		return err
+	}
+	defer h.peers.unregister(p.id)
+	defer close(p.closed)
+
+	_, number := p.Head()
+	p.Log().Debug("Bevm peer connected", "number", number)
+	for {
+		if err := h.handleMsg(p); err != nil {
+			p.Log().Debug("Bevm message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMsg serves a request of the peer or delivers a reply to one of ours.
+func (h *peerHandler) handleMsg(p *bevmPeer) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > peerMaxMessageSize {
+		return fmt.Errorf("message too large: %d > %d", msg.Size, peerMaxMessageSize)
+	}
+	defer msg.Discard()
+
+	switch msg.Code {
+	case getBlockHeadersMsg:
+		var req getBlockHeadersPacket
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("invalid %v: %v", msg, err)
+		}
+		return p2p.Send(p.rw, blockHeadersMsg, &blockHeadersPacket{ID: req.ID, Headers: h.serveHeaders(req.Origin, req.Amount)})
+
+	case getBlockBodiesMsg:
+		var req getBlockBodiesPacket
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("invalid %v: %v", msg, err)
+		}
+		return p2p.Send(p.rw, blockBodiesMsg, &blockBodiesPacket{ID: req.ID, Bodies: h.serveBodies(req.Hashes)})
+
+	case getReceiptsMsg:
+		var req getReceiptsPacket
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("invalid %v: %v", msg, err)
+		}
+		return p2p.Send(p.rw, receiptsMsg, &receiptsPacket{ID: req.ID, Receipts: h.serveReceipts(req.Hashes)})
+
+	case blockHeadersMsg:
+		var res blockHeadersPacket
+		if err := msg.Decode(&res); err != nil {
+			return fmt.Errorf("invalid %v: %v", msg, err)
+		}
+		if n := len(res.Headers); n > 0 {
+			p.setHead(res.Headers[n-1].Hash(), res.Headers[n-1].Number.Uint64())
+		}
+		return p.deliver(res.ID, res.Headers)
+
+	case blockBodiesMsg:
+		var res blockBodiesPacket
+		if err := msg.Decode(&res); err != nil {
+			return fmt.Errorf("invalid %v: %v", msg, err)
+		}
+		return p.deliver(res.ID, res.Bodies)
+
+	case receiptsMsg:
+		var res receiptsPacket
+		if err := msg.Decode(&res); err != nil {
+			return fmt.Errorf("invalid %v: %v", msg, err)
+		}
+		return p.deliver(res.ID, res.Receipts)
+
+	default:
+		return fmt.Errorf("invalid message code %#x", msg.Code)
+	}
+}
+
+func (h *peerHandler) serveHeaders(origin, amount uint64) []*types.Header {
+	if amount > maxPeerHeaders {
+		amount = maxPeerHeaders
+	}
+	var headers []*types.Header
+	for i := uint64(0); i < amount; i++ {
+		header := h.chain.GetHeaderByNumber(origin + i)
+		if header == nil {
+			break
+		}
+		headers = append(headers, header)
+	}
+	return headers
+}
+
+func (h *peerHandler) serveBodies(hashes []common.Hash) []*peerBlockBody {
+	var (
+		bodies []*peerBlockBody
+		bytes  int
+	)
+	for _, hash := range hashes {
+		if len(bodies) >= maxPeerBodies || bytes >= peerSoftResponseLimit {
+			break
+		}
+		block := h.chain.GetBlockByHash(hash)
+		if block == nil {
+			break
+		}
+		body := &peerBlockBody{Transactions: block.Transactions(), BtcHeader: h.chain.GetBtcHeader(block.UncleHash())}
+		enc, err := rlp.EncodeToBytes(body)
+		if err != nil {
+			log.Error("Failed to encode block body", "hash", hash, "err", err)
+			break
+		}
+		bodies = append(bodies, body)
+		bytes += len(enc)
+	}
+	return bodies
+}
+
+func (h *peerHandler) serveReceipts(hashes []common.Hash) [][]*types.Receipt {
+	var (
+		receipts [][]*types.Receipt
+		bytes    int
+	)
+	for _, hash := range hashes {
+		if len(receipts) >= maxPeerReceipts || bytes >= peerSoftResponseLimit {
+			break
+		}
+		if h.chain.GetHeaderByHash(hash) == nil {
+			break
+		}
+		results := h.chain.GetReceiptsByHash(hash)
+		enc, err := rlp.EncodeToBytes(results)
+		if err != nil {
+			log.Error("Failed to encode block receipts", "hash", hash, "err", err)
+			break
+		}
+		receipts = append(receipts, results)
+		bytes += len(enc)
+	}
+	return receipts
+}
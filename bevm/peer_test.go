@@ -0,0 +1,201 @@
+package bevm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// connectPeers runs bevm/1 between two handlers over a message pipe and waits
+// for both handshakes.
+func connectPeers(t *testing.T, local, remote *peerHandler) *p2p.MsgPipeRW {
+	app, net := p2p.MsgPipe()
+	go local.runPeer(newBevmPeer(p2p.NewPeerPipe(enode.ID{2}, "remote", nil, app), app))
+	go remote.runPeer(newBevmPeer(p2p.NewPeerPipe(enode.ID{1}, "local", nil, net), net))
+	waitPeers(t, local, 1)
+	waitPeers(t, remote, 1)
+	return app
+}
+
+func waitPeers(t *testing.T, h *peerHandler, n int) {
+	for i := 0; h.peers.Len() != n; i++ {
+		if i == 100 {
+			t.Fatalf("peer count mismatch: have %d, want %d", h.peers.Len(), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPeerSync(t *testing.T) {
+	funding1, spend1 := newDeployTxs(t, []byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x00}) // sstore(0, 1)
+	funding3, spend3 := newDeployTxs(t, common.FromHex("0x600160005260206000602060006101005afa503d60005560014060015500"))
+	genesis := newBtcBlock(nil, 0)
+	block1 := newBtcBlock(genesis, 1, spend1)
+	block2 := newBtcBlock(block1, 2)
+	block3 := newBtcBlock(block2, 3, spend3)
+	block4 := newBtcBlock(block3, 4)
+	client := NewFixtureClient(0, []*wire.MsgBlock{genesis, block1, block2, block3}, []*wire.MsgTx{funding1, funding3})
+
+	// The serving node translates the BTC chain itself.
+	source, sourceDb := newVerifyTestChain(t, genesis)
+	defer source.Stop()
+	miner := &Miner{eth: NewMockBackend(source, sourceDb), bt: NewBlockTranslatorWithClient(client)}
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to sync fixture blocks: %v", err)
+	}
+	server := newPeerHandler(source, 1)
+
+	// The new node imports the blocks from it, checking each against BTC.
+	chain, db := newVerifyTestChain(t, genesis)
+	defer chain.Stop()
+	handler := newPeerHandler(chain, 1)
+	pipe := connectPeers(t, handler, server)
+	defer pipe.Close()
+
+	miner = &Miner{eth: NewMockBackend(chain, db), bt: NewBlockTranslatorWithClient(client), peers: handler.peers}
+	blocks := make(chan BtcBlockEvent, eventChanSize)
+	invocations := make(chan InvocationEvent, eventChanSize)
+	defer miner.SubscribeBtcBlockEvent(blocks).Unsubscribe()
+	defer miner.SubscribeInvocationEvent(invocations).Unsubscribe()
+	head, err := miner.syncFromPeers(chain.CurrentHeader(), 3)
+	if err != nil {
+		t.Fatalf("failed to sync from peer: %v", err)
+	}
+	// The imported blocks and their invocations are posted like translated ones.
+	for i, want := range []*wire.MsgBlock{block1, block2, block3} {
+		if ev := <-blocks; ev.BtcHash != want.BlockHash() || ev.Block.Hash() != source.GetHeaderByNumber(uint64(i+1)).Hash() {
+			t.Fatalf("block event %d mismatch: have %v at %d", i+1, ev.BtcHash, ev.Block.NumberU64())
+		}
+	}
+	for _, want := range []*wire.MsgTx{spend1, spend3} {
+		if ev := <-invocations; ev.RefHash != [32]byte(want.TxHash()) || ev.Tx == nil || ev.BlockHash != chain.GetHeaderByNumber(ev.BlockNumber).Hash() {
+			t.Fatalf("invocation event mismatch: %+v", ev)
+		}
+	}
+	if head.Hash() != source.CurrentHeader().Hash() || chain.CurrentBlock().Hash() != head.Hash() {
+		t.Fatalf("head mismatch: have %d [%x], want %d [%x]", head.Number, head.Hash(), source.CurrentHeader().Number, source.CurrentHeader().Hash())
+	}
+	for number := uint64(1); number <= 3; number++ {
+		block := chain.GetBlockByNumber(number)
+		if !chain.HasBlockAndState(block.Hash(), number) {
+			t.Fatalf("block %d: missing state", number)
+		}
+		if chain.GetBtcHeader(block.UncleHash()) == nil {
+			t.Fatalf("block %d: missing btc header", number)
+		}
+		if have, want := ReadBtcFees(db, block.Hash()), ReadBtcFees(sourceDb, block.Hash()); !reflect.DeepEqual(have, want) {
+			t.Fatalf("block %d: btc fees mismatch: have %v, want %v", number, have, want)
+		}
+	}
+	if fees := ReadBtcFees(db, chain.GetHeaderByNumber(1).Hash()); len(fees) != 1 {
+		t.Fatalf("btc fees of the imported invocation not recorded: %v", fees)
+	}
+
+	// Receipts are served for the imported blocks too.
+	peer := handler.peers.best()
+	hashes := []common.Hash{chain.GetHeaderByNumber(1).Hash(), chain.GetHeaderByNumber(3).Hash()}
+	receipts, err := peer.RequestReceipts(hashes)
+	if err != nil || len(receipts) != len(hashes) {
+		t.Fatalf("failed to fetch receipts: %d, %v", len(receipts), err)
+	}
+	for i, hash := range hashes {
+		if root := types.DeriveSha(types.Receipts(receipts[i]), trie.NewStackTrie(nil)); root != chain.GetHeaderByHash(hash).ReceiptHash {
+			t.Fatalf("receipts %d: root mismatch: have %x", i, root)
+		}
+	}
+
+	// The next BTC block is translated once the peers have nothing more.
+	client.addBlock(4, block4)
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to translate after peer sync: %v", err)
+	}
+	if number := chain.CurrentHeader().Number.Uint64(); number != 4 {
+		t.Fatalf("head mismatch: have %d, want 4", number)
+	}
+}
+
+func TestPeerSyncRejectsFork(t *testing.T) {
+	funding, spend := newDeployTxs(t, []byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x00}) // sstore(0, 1)
+	genesis := newBtcBlock(nil, 0)
+	block1 := newBtcBlock(genesis, 1, spend)
+	fork1 := newBtcBlock(genesis, 1)
+	fork1.Header.Nonce = 100
+	censored1 := newBtcBlock(genesis, 1) // same header as block1, without its invocation
+
+	tests := []struct {
+		served   *wire.MsgBlock // BTC block the peer translated
+		accepted bool
+	}{
+		{fork1, false},     // anchored to a BTC block unknown to the local node
+		{censored1, false}, // body differing from the BTC block
+		{block1, true},
+	}
+	for i, tt := range tests {
+		source, sourceDb := newVerifyTestChain(t, genesis)
+		miner := &Miner{eth: NewMockBackend(source, sourceDb), bt: NewBlockTranslatorWithClient(NewFixtureClient(0, []*wire.MsgBlock{genesis, tt.served}, []*wire.MsgTx{funding}))}
+		if err := miner.loop(); err != nil {
+			t.Fatalf("test %d: failed to sync served blocks: %v", i, err)
+		}
+		chain, db := newVerifyTestChain(t, genesis)
+		handler := newPeerHandler(chain, 1)
+		pipe := connectPeers(t, handler, newPeerHandler(source, 1))
+
+		client := NewFixtureClient(0, []*wire.MsgBlock{genesis, block1}, []*wire.MsgTx{funding})
+		miner = &Miner{eth: NewMockBackend(chain, db), bt: NewBlockTranslatorWithClient(client), peers: handler.peers}
+		head, err := miner.syncFromPeers(chain.CurrentHeader(), 1)
+		if tt.accepted {
+			if err != nil || head.Number.Uint64() != 1 {
+				t.Fatalf("test %d: failed to import: head %d, %v", i, head.Number, err)
+			}
+		} else {
+			if !errors.Is(err, errPeerBlock) {
+				t.Fatalf("test %d: error mismatch: have %v, want %v", i, err, errPeerBlock)
+			}
+			if head.Number.Uint64() != 0 || chain.CurrentHeader().Number.Uint64() != 0 {
+				t.Fatalf("test %d: imported invalid block: head %d", i, chain.CurrentHeader().Number)
+			}
+			// The peer serving it is dropped.
+			waitPeers(t, handler, 0)
+		}
+		pipe.Close()
+		chain.Stop()
+		source.Stop()
+	}
+}
+
+func TestPeerHandshake(t *testing.T) {
+	genesis := newBtcBlock(nil, 0)
+	chain, _ := newVerifyTestChain(t, genesis)
+	defer chain.Stop()
+	other, _ := newVerifyTestChain(t, newBtcBlock(genesis, 1))
+	defer other.Stop()
+
+	tests := []struct {
+		local, remote *peerHandler
+	}{
+		{newPeerHandler(chain, 1), newPeerHandler(chain, 2)}, // network id mismatch
+		{newPeerHandler(chain, 1), newPeerHandler(other, 1)}, // genesis mismatch
+	}
+	for i, tt := range tests {
+		app, net := p2p.MsgPipe()
+		errc := make(chan error, 2)
+		go func() { errc <- tt.local.runPeer(newBevmPeer(p2p.NewPeerPipe(enode.ID{2}, "remote", nil, app), app)) }()
+		go func() { errc <- tt.remote.runPeer(newBevmPeer(p2p.NewPeerPipe(enode.ID{1}, "local", nil, net), net)) }()
+		if err := <-errc; err == nil {
+			t.Fatalf("test %d: handshake succeeded", i)
+		}
+		app.Close()
+		<-errc
+		if tt.local.peers.Len() != 0 || tt.remote.peers.Len() != 0 {
+			t.Fatalf("test %d: peer registered", i)
+		}
+	}
+}
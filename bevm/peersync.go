@@ -0,0 +1,230 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/layer2"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// peerSyncBatch is the number of blocks fetched from a peer and imported at
+// once.
+const peerSyncBatch = 64
+
+// peerBlock is a block downloaded from a peer with the raw BTC header it claims
+// to be translated from, and the BTC block fetched locally to verify it.
+type peerBlock struct {
+	block     *types.Block
+	btcHeader []byte
+
+	bblock      *wire.MsgBlock
+	prevOuts    txscript.PrevOutputFetcher
+	invocations []*InvocationEvent
+}
+
+// syncFromPeers imports the blocks above head served by the best bevm/1 peer,
+// up to the BTC height. Every block must be anchored to the BTC block of the
+// local BTC node at its height, and is translated again from BTC and compared
+// before the import executes it again. The imported blocks are posted, and their
+// BTC fees and checkpoints recorded, as if they were translated locally. It returns the new head, which is also valid on error.
+func (self *Miner) syncFromPeers(head *types.Header, btcHeight int64) (*types.Header, error) {
+	peer := self.peers.best()
+	if peer == nil {
+		return head, nil
+	}
+	_, number := peer.Head()
+	if number > uint64(btcHeight) {
+		number = uint64(btcHeight)
+	}
+	if number <= head.Number.Uint64() {
+		return head, nil
+	}
+	log.Info("Syncing blocks from peer", "peer", peer.id, "number", head.Number, "target", number)
+	progress := newSyncProgress(head.Number.Int64(), int64(number))
+	defer func() { progress.report(head.Number.Int64(), true) }()
+
+	for head.Number.Uint64() < number && atomic.LoadInt32(&self.closed) == 0 {
+		amount := number - head.Number.Uint64()
+		if amount > peerSyncBatch {
+			amount = peerSyncBatch
+		}
+		blocks, err := fetchPeerBlocks(peer, head.Number.Uint64()+1, amount)
+		if err != nil {
+			peer.Disconnect(p2p.DiscUselessPeer)
+			return head, fmt.Errorf("fetch blocks from peer %s error: %v", peer.id, err)
+		}
+		if len(blocks) == 0 {
+			return head, nil
+		}
+		parent := head
+		for _, b := range blocks {
+			if err := self.verifyPeerBlock(parent, b); err != nil {
+				if errors.Is(err, errPeerBlock) {
+					peer.Disconnect(p2p.DiscUselessPeer)
+				}
+				return head, err
+			}
+			parent = b.block.Header()
+		}
+		if head, err = self.importPeerBlocks(head, blocks); err != nil {
+			peer.Disconnect(p2p.DiscUselessPeer)
+			return head, fmt.Errorf("import blocks from peer %s error: %v", peer.id, err)
+		}
+		progress.report(head.Number.Int64(), false)
+	}
+	return head, nil
+}
+
+// fetchPeerBlocks downloads up to amount consecutive blocks from number origin.
+func fetchPeerBlocks(peer *bevmPeer, origin, amount uint64) ([]*peerBlock, error) {
+	headers, err := peer.RequestHeaders(origin, amount)
+	if err != nil {
+		return nil, err
+	}
+	blocks := make([]*peerBlock, 0, len(headers))
+	for len(blocks) < len(headers) {
+		hashes := make([]common.Hash, 0, len(headers)-len(blocks))
+		for _, header := range headers[len(blocks):] {
+			hashes = append(hashes, header.Hash())
+		}
+		bodies, err := peer.RequestBodies(hashes)
+		if err != nil {
+			return nil, err
+		}
+		if len(bodies) == 0 {
+			return nil, fmt.Errorf("missing body of block %d", headers[len(blocks)].Number)
+		}
+		for _, body := range bodies {
+			header := headers[len(blocks)]
+			blocks = append(blocks, &peerBlock{
+				block:     types.NewBlockWithHeader(header).WithBody(body.Transactions, nil),
+				btcHeader: body.BtcHeader,
+			})
+		}
+	}
+	return blocks, nil
+}
+
+// errPeerBlock marks the blocks refused because of the peer rather than of the
+// local BTC node.
+var errPeerBlock = errors.New("invalid peer block")
+
+// verifyPeerBlock checks that b extends parent and is anchored to the BTC block
+// of the same height of the local BTC node, translating it again if it is due
+// for a spot check.
+func (self *Miner) verifyPeerBlock(parent *types.Header, b *peerBlock) error {
+	var (
+		block  = b.block
+		height = block.NumberU64()
+	)
+	if block.ParentHash() != parent.Hash() {
+		return fmt.Errorf("%w: block %d does not extend %x", errPeerBlock, height, parent.Hash())
+	}
+	var bheader wire.BlockHeader
+	if err := bheader.Deserialize(bytes.NewReader(b.btcHeader)); err != nil {
+		return fmt.Errorf("%w: block %d has invalid btc header: %v", errPeerBlock, height, err)
+	}
+	if hash := BtcHashToEvmHash(bheader.BlockHash()); hash != block.UncleHash() {
+		return fmt.Errorf("%w: block %d is derived from btc block %x, header is %x", errPeerBlock, height, block.UncleHash(), hash)
+	}
+	if prev := BtcHashToEvmHash(bheader.PrevBlock); prev != parent.UncleHash {
+		return fmt.Errorf("%w: btc block %d does not extend %x", errPeerBlock, height, parent.UncleHash)
+	}
+	if block.Time() != uint64(bheader.Timestamp.Unix()) || block.Difficulty().Cmp(blockchain.CalcWork(bheader.Bits)) != 0 {
+		return fmt.Errorf("%w: block %d differs from its btc header", errPeerBlock, height)
+	}
+	anchor, err := self.bt.Client.GetBlockHash(int64(height))
+	if err != nil {
+		return fmt.Errorf("get btc block hash %d error: %v", height, err)
+	}
+	if BtcHashToEvmHash(*anchor) != block.UncleHash() {
+		return fmt.Errorf("%w: block %d is derived from btc block %x, btc node has %s", errPeerBlock, height, block.UncleHash(), anchor)
+	}
+	// The transactions, their senders included, are only trusted once derived
+	// from the BTC block again.
+	bblock, prevOuts, err := self.bt.FetchBlock(int64(height))
+	if err != nil {
+		return fmt.Errorf("fetch btc block %d error: %v", height, err)
+	}
+	translated, invocations := self.bt.translateBlock(bblock, prevOuts, int64(height), parent.Hash())
+	if err := sameTranslation(translated.Header(), block.Header()); err != nil {
+		return fmt.Errorf("%w: block %d differs from btc: %v", errPeerBlock, height, err)
+	}
+	b.bblock, b.prevOuts, b.invocations = bblock, prevOuts, invocations
+	log.Debug("Verified peer block", "number", height, "hash", block.Hash())
+	return nil
+}
+
+// sameTranslation compares the fields set by TranslateBlock, which determine
+// the rest of the header once executed.
+func sameTranslation(translated, header *types.Header) error {
+	switch {
+	case translated.ParentHash != header.ParentHash:
+		return fmt.Errorf("parent hash %x, translated %x", header.ParentHash, translated.ParentHash)
+	case translated.UncleHash != header.UncleHash:
+		return fmt.Errorf("btc hash %x, translated %x", header.UncleHash, translated.UncleHash)
+	case translated.TxHash != header.TxHash:
+		return fmt.Errorf("tx hash %x, translated %x", header.TxHash, translated.TxHash)
+	case translated.Number.Cmp(header.Number) != 0:
+		return fmt.Errorf("number %d, translated %d", header.Number, translated.Number)
+	case translated.Time != header.Time:
+		return fmt.Errorf("time %d, translated %d", header.Time, translated.Time)
+	case translated.Difficulty.Cmp(header.Difficulty) != 0:
+		return fmt.Errorf("difficulty %d, translated %d", header.Difficulty, translated.Difficulty)
+	case translated.GasLimit != header.GasLimit:
+		return fmt.Errorf("gas limit %d, translated %d", header.GasLimit, translated.GasLimit)
+	case translated.Coinbase != header.Coinbase:
+		return fmt.Errorf("coinbase %x, translated %x", header.Coinbase, translated.Coinbase)
+	case !bytes.Equal(translated.Extra, header.Extra):
+		return fmt.Errorf("extra %x, translated %x", header.Extra, translated.Extra)
+	}
+	return nil
+}
+
+// importPeerBlocks stores the BTC headers of verified blocks and inserts them,
+// which executes them again and checks their state against the headers. The
+// inserted blocks are then indexed like the translated ones. It returns the new
+// head, which is also valid on error.
+func (self *Miner) importPeerBlocks(head *types.Header, blocks []*peerBlock) (*types.Header, error) {
+	chain := self.eth.BlockChain()
+	chainBlocks := make([]*types.Block, len(blocks))
+	for i, b := range blocks {
+		rawdb.WriteBtcHeader(self.eth.ChainDb(), b.block.UncleHash(), b.btcHeader)
+		chainBlocks[i] = b.block
+	}
+	// Blocks inserted before a failure still need to be indexed.
+	n, err := chain.InsertChain(chainBlocks)
+	if err != nil {
+		chainBlocks = chainBlocks[:n]
+	}
+	engine, _ := chain.Engine().(*layer2.Layer2Instant)
+	for i, block := range chainBlocks {
+		if engine != nil && engine.IsCrossLayer(block.Number()) {
+			receipts := chain.GetReceiptsByHash(block.Hash())
+			if err := writeMessageTree(self.eth.ChainDb(), engine, head, block.Header(), receipts); err != nil {
+				log.Error("Failed to index cross layer messages", "number", block.Number(), "err", err)
+			}
+		}
+		b := blocks[i]
+		self.postBlock(b.bblock.BlockHash(), block, b.invocations)
+		self.writeBtcFees(b.bblock, b.prevOuts, block)
+		if self.checkpoints != nil {
+			self.checkpoints.verify(chain, self.eth.ChainDb(), b.bblock, b.prevOuts, block.NumberU64())
+		}
+		head = block.Header()
+		self.sync.setHead(head.Number.Uint64())
+	}
+	return head, err
+}
@@ -0,0 +1,148 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// maxPrefetchWorkers caps the number of concurrent BTC RPC fetchers.
+const maxPrefetchWorkers = 8
+
+// prefetchedBlock is a BTC block fetched together with the outputs spent by
+// its EVM invocations.
+type prefetchedBlock struct {
+	height   int64
+	block    *wire.MsgBlock
+	prevOuts txscript.PrevOutputFetcher
+	err      error
+}
+
+type prefetchTask struct {
+	height int64
+	result chan *prefetchedBlock
+}
+
+// blockPrefetcher downloads a range of BTC blocks and their prevouts ahead of
+// execution in worker goroutines and hands them out strictly in height order.
+// At most window blocks are buffered, the fetchers stall until the consumer
+// catches up.
+type blockPrefetcher struct {
+	bt      *BlockTranslator
+	tasks   chan *prefetchTask
+	pending chan *prefetchTask // ordered results, bounded by the window
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// newBlockPrefetcher starts fetching the BTC blocks from..to (inclusive), at
+// most window heights ahead of the consumer.
+func newBlockPrefetcher(bt *BlockTranslator, from, to int64, window int) *blockPrefetcher {
+	workers := window
+	if workers > maxPrefetchWorkers {
+		workers = maxPrefetchWorkers
+	}
+	p := &blockPrefetcher{
+		bt:      bt,
+		tasks:   make(chan *prefetchTask),
+		pending: make(chan *prefetchTask, window),
+		quit:    make(chan struct{}),
+	}
+	p.wg.Add(workers + 1)
+	for i := 0; i < workers; i++ {
+		go p.fetcher()
+	}
+	go p.schedule(from, to)
+	return p
+}
+
+// schedule hands out the heights to the fetchers, blocking while the window of
+// pending results is full.
+func (p *blockPrefetcher) schedule(from, to int64) {
+	defer p.wg.Done()
+	defer close(p.tasks)
+
+	for height := from; height <= to; height++ {
+		task := &prefetchTask{height: height, result: make(chan *prefetchedBlock, 1)}
+		select {
+		case p.pending <- task:
+			syncQueueGauge.Update(int64(len(p.pending)))
+		case <-p.quit:
+			return
+		}
+		select {
+		case p.tasks <- task:
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+func (p *blockPrefetcher) fetcher() {
+	defer p.wg.Done()
+
+	for task := range p.tasks {
+		block, prevOuts, err := p.bt.FetchBlock(task.height)
+		task.result <- &prefetchedBlock{height: task.height, block: block, prevOuts: prevOuts, err: err}
+	}
+}
+
+// next returns the block following the previously returned one, waiting for it
+// to be fetched if needed.
+func (p *blockPrefetcher) next() (*prefetchedBlock, bool) {
+	start := time.Now()
+	select {
+	case task := <-p.pending:
+		select {
+		case res := <-task.result:
+			syncWaitTimer.UpdateSince(start)
+			return res, true
+		case <-p.quit:
+		}
+	case <-p.quit:
+	}
+	return nil, false
+}
+
+// close aborts any outstanding fetches and waits for the workers to exit.
+func (p *blockPrefetcher) close() {
+	close(p.quit)
+	p.wg.Wait()
+	syncQueueGauge.Update(0)
+}
+
+// syncProgress periodically reports the catch up progress and its ETA.
+type syncProgress struct {
+	start     time.Time
+	logged    time.Time
+	from, to  int64
+	processed int64
+}
+
+func newSyncProgress(from, to int64) *syncProgress {
+	return &syncProgress{start: time.Now(), logged: time.Now(), from: from, to: to}
+}
+
+func (p *syncProgress) report(height int64, force bool) {
+	p.processed = height - p.from
+	if !force && time.Since(p.logged) < 8*time.Second {
+		return
+	}
+	var (
+		elapsed = time.Since(p.start)
+		rate    = float64(p.processed) / elapsed.Seconds()
+		context = []interface{}{"number", height, "target", p.to, "blocks", p.processed, "elapsed", common.PrettyDuration(elapsed)}
+	)
+	if remaining := p.to - height; remaining > 0 && rate > 0 {
+		eta := time.Duration(float64(remaining) / rate * float64(time.Second))
+		context = append(context, "remaining", remaining, "eta", common.PrettyDuration(eta))
+	}
+	log.Info("Syncing BTC blocks", context...)
+	p.logged = time.Now()
+}
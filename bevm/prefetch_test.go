@@ -0,0 +1,87 @@
+package bevm
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func newBtcChain(n int) []*wire.MsgBlock {
+	blocks := []*wire.MsgBlock{newBtcBlock(nil, 0)}
+	for i := 1; i < n; i++ {
+		blocks = append(blocks, newBtcBlock(blocks[i-1], int64(i)))
+	}
+	return blocks
+}
+
+func TestBlockPrefetcherOrder(t *testing.T) {
+	blocks := newBtcChain(40)
+	bt := NewBlockTranslatorWithClient(NewFixtureClient(0, blocks, nil))
+
+	p := newBlockPrefetcher(bt, 1, 39, 4)
+	defer p.close()
+	for height := int64(1); height <= 39; height++ {
+		res, ok := p.next()
+		if !ok {
+			t.Fatalf("prefetcher closed at height %d", height)
+		}
+		if res.err != nil {
+			t.Fatalf("fetch %d failed: %v", height, res.err)
+		}
+		if res.height != height || res.block.BlockHash() != blocks[height].BlockHash() {
+			t.Fatalf("block mismatch: have %d, want %d", res.height, height)
+		}
+		if len(p.pending) > 4 {
+			t.Fatalf("window exceeded: %d pending", len(p.pending))
+		}
+	}
+}
+
+func TestBlockPrefetcherError(t *testing.T) {
+	blocks := newBtcChain(10)
+	bt := NewBlockTranslatorWithClient(NewFixtureClient(0, blocks, nil))
+
+	// Heights beyond the fixture fail, the error must surface in order.
+	p := newBlockPrefetcher(bt, 8, 12, 3)
+	for height := int64(8); height <= 9; height++ {
+		if res, _ := p.next(); res.err != nil {
+			t.Fatalf("fetch %d failed: %v", height, res.err)
+		}
+	}
+	if res, _ := p.next(); res.err == nil {
+		t.Fatalf("expected error for missing height 10")
+	}
+	p.close()
+}
+
+func TestPipelinedSync(t *testing.T) {
+	funding, spend := newDeployTxs(t, []byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x00}) // sstore(0, 1)
+	blocks := newBtcChain(5)
+	blocks = append(blocks, newBtcBlock(blocks[4], 5, spend))
+	for i := 6; i < 30; i++ {
+		blocks = append(blocks, newBtcBlock(blocks[i-1], int64(i)))
+	}
+	client := NewFixtureClient(0, blocks, []*wire.MsgTx{funding})
+
+	var hashes []string
+	for _, prefetch := range []int{0, 6} {
+		chain, db := newVerifyTestChain(t, blocks[0])
+		defer chain.Stop()
+		miner := &Miner{
+			eth:      NewMockBackend(chain, db),
+			bt:       NewBlockTranslatorWithClient(client),
+			prefetch: prefetch,
+		}
+		if err := miner.loop(); err != nil {
+			t.Fatalf("prefetch %d: failed to sync: %v", prefetch, err)
+		}
+		head := chain.CurrentBlock()
+		if head.NumberU64() != 29 {
+			t.Fatalf("prefetch %d: head mismatch: have %d, want 29", prefetch, head.NumberU64())
+		}
+		hashes = append(hashes, head.Hash().Hex())
+	}
+	if hashes[0] != hashes[1] {
+		t.Fatalf("pipelined sync diverged from sequential sync: %s != %s", hashes[1], hashes[0])
+	}
+}
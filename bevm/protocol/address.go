@@ -219,20 +219,29 @@ func newAddressWitnessScriptHash(hrp string,
 	return addr, nil
 }
 
-func mustRegisterEVM(params chaincfg.Params) {
+func registerEVM(params chaincfg.Params) error {
 	params.Net += 1 // just make it pass the following register func
 	params.Bech32HRPSegwit += "e"
-	if err := chaincfg.Register(&params); err != nil {
+	return registerNet(&params)
+}
+
+func mustRegisterEVM(params chaincfg.Params) {
+	if err := registerEVM(params); err != nil {
 		panic("failed to register network: " + err.Error())
 	}
 }
 
 func init() {
+	// Signet is not registered by btcutil itself
+	if err := chaincfg.Register(&chaincfg.SigNetParams); err != nil {
+		panic("failed to register network: " + err.Error())
+	}
 	// Register all default networks to make btcutil.DecodeAddress work
 	mustRegisterEVM(chaincfg.MainNetParams)
 	mustRegisterEVM(chaincfg.TestNet3Params)
 	mustRegisterEVM(chaincfg.RegressionNetParams)
 	mustRegisterEVM(chaincfg.SimNetParams)
+	mustRegisterEVM(chaincfg.SigNetParams)
 }
 
 // DecodeAddress decodes the string encoding of an address and returns
@@ -272,7 +281,12 @@ var NewAddressWitnessScriptHash = btcutil.NewAddressWitnessScriptHash
 var NewAddressPubKey = btcutil.NewAddressPubKey
 
 func NewAddressEVMFromPubKey(pub *btcec.PublicKey, net *chaincfg.Params, deploy ...bool) *AddressWitnessEVMScriptHash {
-	script := NewEVMScriptFromPubKey(pub, deploy...)
+	return NewAddressEVMFromScript(NewEVMScriptFromPubKey(pub, deploy...), net)
+}
+
+// NewAddressEVMFromScript returns the EVM P2WSH address of an EVM script, as
+// built by NewEVMScript.
+func NewAddressEVMFromScript(script []byte, net *chaincfg.Params) *AddressWitnessEVMScriptHash {
 	hash := sha256.Sum256(script)
 
 	addr, err := NewAddressEVM(hash[:], net)
@@ -281,17 +295,7 @@ func NewAddressEVMFromPubKey(pub *btcec.PublicKey, net *chaincfg.Params, deploy
 }
 
 func NewEVMScriptFromPubKey(pub *btcec.PublicKey, deploy ...bool) []byte {
-	pBytes := pub.SerializeCompressed()
-	builder := txscript.NewScriptBuilder()
-	num := 10
-	if len(deploy) > 0 && deploy[0] {
-		num = 49
-	}
-
-	for i := 0; i < num; i++ {
-		builder.AddOp(txscript.OP_2DROP)
-	}
-	script, err := builder.AddData(pBytes).AddOp(txscript.OP_CHECKSIG).Script()
+	script, err := NewEVMScript(NewPubKeyPolicy(pub), deploy...)
 	utils.Ensure(err)
 
 	return script
@@ -0,0 +1,163 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// A checkpoint commits a bevm block back to Bitcoin in an OP_RETURN output:
+//
+//	OP_RETURN <"bvcp" | height (8 bytes, big endian) | block hash | state root>
+//
+// It is authenticated like an OP_RETURN invocation: the first input of the
+// transaction must be a P2WPKH or P2TR key path spend of the committer, signed
+// over all outputs.
+
+const CheckpointTag = "bvcp"
+
+const checkpointSize = len(CheckpointTag) + 8 + 2*common.HashLength
+
+var errNotCheckpoint = errors.New("not a checkpoint output")
+
+// Checkpoint is the commitment to a bevm block and its state.
+type Checkpoint struct {
+	Height    uint64      `json:"height"`
+	BlockHash common.Hash `json:"blockHash"`
+	StateRoot common.Hash `json:"stateRoot"`
+}
+
+// NewCheckpointScript builds the OP_RETURN output script carrying cp.
+func NewCheckpointScript(cp *Checkpoint) ([]byte, error) {
+	payload := make([]byte, 0, checkpointSize)
+	payload = append(payload, CheckpointTag...)
+	payload = append(payload, make([]byte, 8)...)
+	binary.BigEndian.PutUint64(payload[len(CheckpointTag):], cp.Height)
+	payload = append(payload, cp.BlockHash.Bytes()...)
+	payload = append(payload, cp.StateRoot.Bytes()...)
+	return txscript.NullDataScript(payload)
+}
+
+// DecodeCheckpoint decodes the checkpoint carried by an OP_RETURN script.
+func DecodeCheckpoint(pkScript []byte) (*Checkpoint, error) {
+	if len(pkScript) == 0 || pkScript[0] != txscript.OP_RETURN {
+		return nil, errNotCheckpoint
+	}
+	pushes, err := txscript.PushedData(pkScript[1:])
+	if err != nil || len(pushes) != 1 {
+		return nil, errNotCheckpoint
+	}
+	payload := pushes[0]
+	if len(payload) != checkpointSize || string(payload[:len(CheckpointTag)]) != CheckpointTag {
+		return nil, errNotCheckpoint
+	}
+	payload = payload[len(CheckpointTag):]
+	return &Checkpoint{
+		Height:    binary.BigEndian.Uint64(payload),
+		BlockHash: common.BytesToHash(payload[8 : 8+common.HashLength]),
+		StateRoot: common.BytesToHash(payload[8+common.HashLength:]),
+	}, nil
+}
+
+// HasCheckpoint reports whether any output of tx carries a checkpoint.
+func HasCheckpoint(tx *wire.MsgTx) bool {
+	if blockchain.IsCoinBaseTx(tx) {
+		return false
+	}
+	for _, txout := range tx.TxOut {
+		if _, err := DecodeCheckpoint(txout.PkScript); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractCheckpoints returns the checkpoints carried by tx if its first input
+// is a signed spend of an output locked to committer. fetcher must know the
+// output spent by the first input.
+func ExtractCheckpoints(tx *wire.MsgTx, fetcher txscript.PrevOutputFetcher, committer []byte) []*Checkpoint {
+	if !HasCheckpoint(tx) {
+		return nil
+	}
+	prevOut := fetcher.FetchPrevOutput(tx.TxIn[0].PreviousOutPoint)
+	if prevOut == nil || !bytes.Equal(prevOut.PkScript, committer) {
+		return nil
+	}
+	if _, err := opReturnSender(tx.TxIn[0], prevOut); err != nil {
+		return nil
+	}
+	var cps []*Checkpoint
+	for _, txout := range tx.TxOut {
+		if cp, err := DecodeCheckpoint(txout.PkScript); err == nil {
+			cps = append(cps, cp)
+		}
+	}
+	return cps
+}
+
+// CheckpointCommitter returns the P2WPKH address checkpoints of key are
+// committed from.
+func CheckpointCommitter(key *btcec.PrivateKey, net *chaincfg.Params) (*btcutil.AddressWitnessPubKeyHash, error) {
+	return btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(key.PubKey().SerializeCompressed()), net)
+}
+
+// NewCheckpointTx builds a transaction committing cp, spending the given
+// outputs of the committer key and returning the change to it. The fee is
+// computed from feeRate in sat/vB.
+func NewCheckpointTx(cp *Checkpoint, key *btcec.PrivateKey, net *chaincfg.Params, points []wire.OutPoint, prevOuts []*wire.TxOut, feeRate int64) (*wire.MsgTx, error) {
+	if len(points) == 0 || len(points) != len(prevOuts) {
+		return nil, errors.New("no checkpoint funding outputs")
+	}
+	addr, err := CheckpointCommitter(key, net)
+	if err != nil {
+		return nil, err
+	}
+	committer, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	script, err := NewCheckpointScript(cp)
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	var amount int64
+	for i, point := range points {
+		if !bytes.Equal(prevOuts[i].PkScript, committer) {
+			return nil, fmt.Errorf("funding output %v is not locked to the committer", point)
+		}
+		tx.AddTxIn(wire.NewTxIn(&points[i], nil, nil))
+		fetcher.AddPrevOut(point, prevOuts[i])
+		amount += prevOuts[i].Value
+	}
+	tx.AddTxOut(wire.NewTxOut(0, script))
+	change := wire.NewTxOut(0, committer)
+	tx.AddTxOut(change)
+
+	// The witness adds the segwit marker and flag, and 108 weight units at most
+	// per P2WPKH input.
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx)) + 2 + int64(len(points))*108
+	fee := (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor * feeRate
+	if change.Value = amount - fee; change.Value < 546 {
+		return nil, fmt.Errorf("insufficient checkpoint funds: have %d, need %d", amount, fee+546)
+	}
+	sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+	for i := range tx.TxIn {
+		witness, err := txscript.WitnessSignature(tx, sigHashes, i, prevOuts[i].Value, committer, txscript.SigHashAll, key, true)
+		if err != nil {
+			return nil, err
+		}
+		tx.TxIn[i].Witness = witness
+	}
+	return tx, nil
+}
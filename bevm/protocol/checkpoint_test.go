@@ -0,0 +1,70 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpointScript(t *testing.T) {
+	cp := &Checkpoint{Height: 840000, BlockHash: common.HexToHash("0x01"), StateRoot: common.HexToHash("0x02")}
+	script, err := NewCheckpointScript(cp)
+	assert.Nil(t, err)
+	assert.Equal(t, txscript.NullDataTy, txscript.GetScriptClass(script))
+
+	decoded, err := DecodeCheckpoint(script)
+	assert.Nil(t, err)
+	assert.Equal(t, cp, decoded)
+
+	_, err = decodeOpReturn(script)
+	assert.NotNil(t, err, "checkpoint decoded as invocation")
+	_, err = DecodeCheckpoint(script[:len(script)-1])
+	assert.NotNil(t, err)
+}
+
+func TestCheckpointTx(t *testing.T) {
+	key, err := btcec.NewPrivateKey()
+	assert.Nil(t, err)
+	addr, err := CheckpointCommitter(key, &chaincfg.RegressionNetParams)
+	assert.Nil(t, err)
+	committer, err := txscript.PayToAddrScript(addr)
+	assert.Nil(t, err)
+
+	points := []wire.OutPoint{*wire.NewOutPoint(&chainhash.Hash{0x0c}, 1), *wire.NewOutPoint(&chainhash.Hash{0x0d}, 0)}
+	prevOuts := []*wire.TxOut{wire.NewTxOut(3000, committer), wire.NewTxOut(5000, committer)}
+	cp := &Checkpoint{Height: 7, BlockHash: common.HexToHash("0x07"), StateRoot: common.HexToHash("0x08")}
+	tx, err := NewCheckpointTx(cp, key, &chaincfg.RegressionNetParams, points, prevOuts, 2)
+	assert.Nil(t, err)
+
+	view := blockchain.NewUtxoViewpoint()
+	for i, point := range points {
+		view.Entries()[point] = blockchain.NewUtxoEntry(prevOuts[i], 0, false)
+	}
+	err = blockchain.ValidateTransactionScripts(btcutil.NewTx(tx), view,
+		txscript.StandardVerifyFlags, txscript.NewSigCache(10), txscript.NewHashCache(10))
+	assert.Nil(t, err)
+
+	// The fee covers the final virtual size at the requested rate.
+	vsize := (blockchain.GetTransactionWeight(btcutil.NewTx(tx)) + 3) / 4
+	fee := 8000 - tx.TxOut[1].Value
+	assert.True(t, fee >= 2*vsize && fee <= 2*vsize+4, "fee %d for vsize %d", fee, vsize)
+
+	assert.Equal(t, []*Checkpoint{cp}, ExtractCheckpoints(tx, view, committer))
+	other, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(make([]byte, 20)).Script()
+	assert.Nil(t, err)
+	assert.Nil(t, ExtractCheckpoints(tx, view, other))
+	assert.Equal(t, 0, len(ExtractEVMWitness(tx, view)))
+
+	_, err = NewCheckpointTx(cp, key, &chaincfg.RegressionNetParams, points[:1], []*wire.TxOut{wire.NewTxOut(3000, other)}, 2)
+	assert.NotNil(t, err, "spent foreign output")
+	_, err = NewCheckpointTx(cp, key, &chaincfg.RegressionNetParams, points[:1], prevOuts[:1], 100)
+	assert.NotNil(t, err, "spent insufficient funds")
+}
@@ -0,0 +1,28 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/bevm/protocol/utils"
+)
+
+var (
+	privateKey, _ = btcec.PrivKeyFromBytes(mustDecodeHex("2129ba4799e5d010cf8ae6c79168f1e3746bbd578fb8d2e4725ec2ba969d47a1"))
+	evmAddress    = NewAddressEVMFromPubKey(privateKey.PubKey(), &chaincfg.TestNet3Params)
+)
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	utils.Ensure(err)
+	return b
+}
+
+func getTxHex(tx *wire.MsgTx) string {
+	var buf bytes.Buffer
+	utils.Ensure(tx.Serialize(&buf))
+	return hex.EncodeToString(buf.Bytes())
+}
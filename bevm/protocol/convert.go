@@ -0,0 +1,145 @@
+package protocol
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Address classes reported by ConvertAddress, besides the txscript classes of
+// plain BTC addresses.
+const (
+	EVMScriptHashClass = "evm_witness_v0_scripthash"
+	EVMAccountClass    = "evm_account"
+	PubKeyClass        = "pubkey"
+)
+
+// KnownNets lists the BTC networks addresses are resolved against, custom
+// networks are added by RegisterNet.
+var KnownNets = []*chaincfg.Params{
+	&chaincfg.MainNetParams,
+	&chaincfg.TestNet3Params,
+	&chaincfg.RegressionNetParams,
+	&chaincfg.SimNetParams,
+	&chaincfg.SigNetParams,
+}
+
+// NetParamsByName returns the known BTC network with the given name.
+func NetParamsByName(name string) (*chaincfg.Params, error) {
+	for _, net := range KnownNets {
+		if net.Name == name {
+			return net, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown btc network: %s", name)
+}
+
+// AddressInfo describes an address in all the forms bevm knows it by.
+type AddressInfo struct {
+	Input   string `json:"input"`
+	Network string `json:"network,omitempty"`
+	Class   string `json:"class"`
+
+	EVMScriptAddress string          `json:"evmScriptAddress,omitempty"` // bech32 EVM P2WSH address
+	P2WSHAddress     string          `json:"p2wshAddress,omitempty"`     // plain P2WSH address funding the EVM script
+	WitnessProgram   hexutil.Bytes   `json:"witnessProgram,omitempty"`   // sha256 of the EVM script
+	EVMAddress       *common.Address `json:"evmAddress,omitempty"`       // EVM account of the script
+	UnifiedAddress   *common.Address `json:"unifiedAddress,omitempty"`   // EVM account of unified invocations by the key
+}
+
+// ConvertAddress resolves an EVM P2WSH address, a plain P2WSH address, a 0x EVM
+// address or a hex encoded public key into all of its bevm forms. Public keys
+// are resolved on the given network, which defaults to mainnet.
+//
+// Other BTC addresses are validated and classified, but have no EVM form.
+func ConvertAddress(input string, net *chaincfg.Params) (*AddressInfo, error) {
+	if net == nil {
+		net = &chaincfg.MainNetParams
+	}
+	input = strings.TrimSpace(input)
+	info := &AddressInfo{Input: input}
+
+	if common.IsHexAddress(input) && strings.HasPrefix(input, "0x") {
+		// The witness program can't be recovered from its ripemd160 hash.
+		addr := common.HexToAddress(input)
+		info.Class = EVMAccountClass
+		info.EVMAddress = &addr
+		return info, nil
+	}
+	if pub, err := parsePubKey(input); err == nil {
+		info.Class = PubKeyClass
+		info.Network = net.Name
+		info.fill(NewAddressEVMFromPubKey(pub, net))
+		unified := UnifiedEVMAddress(pub)
+		info.UnifiedAddress = &unified
+		return info, nil
+	}
+	decoded, err := DecodeAddress(input, net)
+	if err != nil {
+		return nil, err
+	}
+	if info.Network, err = addressNet(decoded, net); err != nil {
+		return nil, err
+	}
+	switch addr := decoded.(type) {
+	case *AddressWitnessEVMScriptHash:
+		info.Class = EVMScriptHashClass
+		info.fill(addr)
+	case *btcutil.AddressWitnessScriptHash:
+		params, _ := NetParamsByName(info.Network)
+		evm, err := NewAddressEVM(addr.WitnessProgram(), params)
+		if err != nil {
+			return nil, err
+		}
+		info.Class = txscript.WitnessV0ScriptHashTy.String()
+		info.fill(evm)
+	default:
+		script, err := txscript.PayToAddrScript(decoded)
+		if err != nil {
+			return nil, err
+		}
+		info.Class = txscript.GetScriptClass(script).String()
+	}
+	return info, nil
+}
+
+func (info *AddressInfo) fill(addr *AddressWitnessEVMScriptHash) {
+	evm := addr.EvmAddress()
+	info.EVMScriptAddress = addr.EncodeAddress()
+	info.P2WSHAddress = addr.Compat().EncodeAddress()
+	info.WitnessProgram = addr.WitnessProgram()
+	info.EVMAddress = &evm
+}
+
+func parsePubKey(input string) (*btcec.PublicKey, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(input, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != btcec.PubKeyBytesLenCompressed && len(raw) != 65 {
+		return nil, errors.New("invalid public key length")
+	}
+	return btcec.ParsePubKey(raw)
+}
+
+// addressNet returns the network of addr, preferring the given one as networks
+// like testnet3 and signet share their address prefixes.
+func addressNet(addr btcutil.Address, preferred *chaincfg.Params) (string, error) {
+	if addr.IsForNet(preferred) {
+		return preferred.Name, nil
+	}
+	for _, net := range KnownNets {
+		if addr.IsForNet(net) {
+			return net.Name, nil
+		}
+	}
+	return "", fmt.Errorf("address %s is for an unknown network", addr.EncodeAddress())
+}
@@ -0,0 +1,48 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertAddress(t *testing.T) {
+	pubHex := common.Bytes2Hex(privateKey.PubKey().SerializeCompressed())
+	fromPub, err := ConvertAddress(pubHex, &chaincfg.TestNet3Params)
+	assert.Nil(t, err)
+	assert.Equal(t, PubKeyClass, fromPub.Class)
+	assert.Equal(t, "testnet3", fromPub.Network)
+	assert.Equal(t, evmAddress.EncodeAddress(), fromPub.EVMScriptAddress)
+	assert.Equal(t, evmAddress.Compat().EncodeAddress(), fromPub.P2WSHAddress)
+	assert.Equal(t, evmAddress.EvmAddress(), *fromPub.EVMAddress)
+	assert.Equal(t, UnifiedEVMAddress(privateKey.PubKey()), *fromPub.UnifiedAddress)
+
+	// The bech32 EVM form and the plain P2WSH form resolve to the same account,
+	// regardless of the network passed in.
+	for _, input := range []string{fromPub.EVMScriptAddress, fromPub.P2WSHAddress} {
+		info, err := ConvertAddress(input, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "testnet3", info.Network)
+		assert.Equal(t, fromPub.EVMScriptAddress, info.EVMScriptAddress)
+		assert.Equal(t, fromPub.P2WSHAddress, info.P2WSHAddress)
+		assert.Equal(t, *fromPub.EVMAddress, *info.EVMAddress)
+	}
+
+	info, err := ConvertAddress(fromPub.EVMAddress.Hex(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, EVMAccountClass, info.Class)
+	assert.Equal(t, "", info.EVMScriptAddress)
+
+	// Other BTC addresses are classified without an EVM form.
+	info, err = ConvertAddress("bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "mainnet", info.Network)
+	assert.Equal(t, txscript.WitnessV1TaprootTy.String(), info.Class)
+	assert.Nil(t, info.EVMAddress)
+
+	_, err = ConvertAddress("tbe1qinvalid", nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,194 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// EnvelopeTag marks an inscription envelope carrying an EVM payload.
+const EnvelopeTag = "bevm"
+
+// EnvelopeChunkSize is the size of the payload pushes of an envelope, the
+// largest element tapscript allows on the stack.
+const EnvelopeChunkSize = txscript.MaxScriptElementSize
+
+var errNotEnvelope = errors.New("not an evm envelope")
+
+// Envelope is a taproot output committing to a single leaf that carries an EVM
+// payload in an inscription envelope:
+//
+//	<reveal key> OP_CHECKSIG OP_FALSE OP_IF "bevm" <chunk>... OP_ENDIF
+//
+// Funding PkScript is the commit step, spending it through the leaf with a
+// signature of the reveal key is the reveal step that invokes the EVM.
+type Envelope struct {
+	Script       []byte // tapscript leaf holding the payload
+	ControlBlock []byte // control block proving the leaf against the output key
+	PkScript     []byte // P2TR script of the commit output
+	OutputKey    *btcec.PublicKey
+}
+
+// NewEnvelope commits evmData to a taproot output with the given internal key,
+// revealable by a signature of revealKey.
+func NewEnvelope(internalKey, revealKey *btcec.PublicKey, evmData EVMInvokeData) (*Envelope, error) {
+	script := NewEnvelopeScript(revealKey, evmData)
+	tree := txscript.AssembleTaprootScriptTree(txscript.NewBaseTapLeaf(script))
+	rootHash := tree.RootNode.TapHash()
+	ctrlBlock := tree.LeafMerkleProofs[0].ToControlBlock(internalKey)
+	ctrlBytes, err := ctrlBlock.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	outputKey := txscript.ComputeTaprootOutputKey(internalKey, rootHash[:])
+	pkScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_1).AddData(schnorr.SerializePubKey(outputKey)).Script()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		Script:       script,
+		ControlBlock: ctrlBytes,
+		PkScript:     pkScript,
+		OutputKey:    outputKey,
+	}, nil
+}
+
+// Address returns the taproot address of the commit output.
+func (self *Envelope) Address(net *chaincfg.Params) (*btcutil.AddressTaproot, error) {
+	return btcutil.NewAddressTaproot(schnorr.SerializePubKey(self.OutputKey), net)
+}
+
+// RevealWitness returns the witness spending the commit output with the given
+// signature of the reveal key.
+func (self *Envelope) RevealWitness(sig []byte) wire.TxWitness {
+	return wire.TxWitness{sig, self.Script, self.ControlBlock}
+}
+
+// SignReveal signs input idx of the reveal transaction, which spends a commit
+// output of amt satoshis, and returns the input witness.
+func (self *Envelope) SignReveal(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes, idx int, amt int64,
+	revealKey *btcec.PrivateKey, hashType txscript.SigHashType) (wire.TxWitness, error) {
+	sig, err := txscript.RawTxInTapscriptSignature(tx, sigHashes, idx, amt, self.PkScript,
+		txscript.NewBaseTapLeaf(self.Script), hashType, revealKey)
+	if err != nil {
+		return nil, err
+	}
+	return self.RevealWitness(sig), nil
+}
+
+// NewEnvelopeScript builds the tapscript leaf carrying evmData. The pushes are
+// never executed, so the leaf is not bound by the script size limit and holds
+// payloads of any size up to the block weight.
+func NewEnvelopeScript(revealKey *btcec.PublicKey, evmData EVMInvokeData) []byte {
+	script := appendPush(nil, schnorr.SerializePubKey(revealKey))
+	script = append(script, txscript.OP_CHECKSIG, txscript.OP_FALSE, txscript.OP_IF)
+	script = appendPush(script, []byte(EnvelopeTag))
+	payload := bytes.Join(evmData.ToWitness(), nil)
+	for len(payload) > 0 {
+		n := len(payload)
+		if n > EnvelopeChunkSize {
+			n = EnvelopeChunkSize
+		}
+		script = appendPush(script, payload[:n])
+		payload = payload[n:]
+	}
+	return append(script, txscript.OP_ENDIF)
+}
+
+// EnvelopeSender returns the EVM account of invocations revealed by key.
+func EnvelopeSender(revealKey *btcec.PublicKey) common.Address {
+	hash := sha256.Sum256(schnorr.SerializePubKey(revealKey))
+	return Ripemd160(hash[:])
+}
+
+// appendPush appends a canonical push of data to script.
+func appendPush(script []byte, data []byte) []byte {
+	switch n := len(data); {
+	case n <= txscript.OP_DATA_75:
+		script = append(script, byte(n))
+	case n <= 0xff:
+		script = append(script, txscript.OP_PUSHDATA1, byte(n))
+	default:
+		script = append(script, txscript.OP_PUSHDATA2, 0, 0)
+		binary.LittleEndian.PutUint16(script[len(script)-2:], uint16(n))
+	}
+	return append(script, data...)
+}
+
+// taprootLeafScript returns the leaf script of a taproot script path spend, or
+// nil if the witness is not one. Consensus does not run leaf scripts of unknown
+// versions, so only tapscript leaves are returned.
+func taprootLeafScript(witness wire.TxWitness) []byte {
+	// strip the annex if present
+	if len(witness) >= 2 {
+		if last := witness[len(witness)-1]; len(last) > 0 && last[0] == txscript.TaprootAnnexTag {
+			witness = witness[:len(witness)-1]
+		}
+	}
+	if len(witness) < 2 {
+		return nil
+	}
+	cb, err := txscript.ParseControlBlock(witness[len(witness)-1])
+	if err != nil || cb.LeafVersion != txscript.BaseLeafVersion {
+		return nil
+	}
+	return witness[len(witness)-2]
+}
+
+// decodeEnvelope parses an envelope leaf script and returns the x-only reveal
+// key and the EVM invocation it carries.
+func decodeEnvelope(script []byte) ([]byte, EVMInvokeData, error) {
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	expect := func(op byte) bool {
+		return tokenizer.Next() && tokenizer.Opcode() == op
+	}
+	if !expect(txscript.OP_DATA_32) {
+		return nil, nil, errNotEnvelope
+	}
+	revealKey := tokenizer.Data()
+	if !expect(txscript.OP_CHECKSIG) || !expect(txscript.OP_FALSE) || !expect(txscript.OP_IF) {
+		return nil, nil, errNotEnvelope
+	}
+	if !tokenizer.Next() || !bytes.Equal(tokenizer.Data(), []byte(EnvelopeTag)) {
+		return nil, nil, errNotEnvelope
+	}
+	var payload []byte
+	for tokenizer.Next() {
+		op := tokenizer.Opcode()
+		switch {
+		case op == txscript.OP_ENDIF:
+			if tokenizer.Next() || tokenizer.Err() != nil {
+				return nil, nil, fmt.Errorf("unexpected data after envelope")
+			}
+			data, err := DecodeEVMWitness(payload)
+			if err != nil {
+				return nil, nil, err
+			}
+			return revealKey, data, nil
+		case op <= txscript.OP_PUSHDATA4:
+			payload = append(payload, tokenizer.Data()...)
+		case op >= txscript.OP_1 && op <= txscript.OP_16:
+			// minimal pushes encode a single byte 1-16 as a small integer
+			payload = append(payload, op-(txscript.OP_1-1))
+		case op == txscript.OP_1NEGATE:
+			payload = append(payload, 0x81)
+		default:
+			return nil, nil, fmt.Errorf("unexpected opcode %d in envelope", op)
+		}
+	}
+	if err := tokenizer.Err(); err != nil {
+		return nil, nil, err
+	}
+	return nil, nil, errors.New("unterminated envelope")
+}
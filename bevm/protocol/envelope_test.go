@@ -0,0 +1,133 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// newRevealTx builds a reveal transaction spending the commit output of env
+// and signs it with revealKey.
+func newRevealTx(t *testing.T, env *Envelope, revealKey *btcec.PrivateKey) (*wire.MsgTx, *blockchain.UtxoViewpoint) {
+	commitHash := chainhash.Hash{0xc0}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&commitHash, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(546, []byte{txscript.OP_TRUE}))
+
+	view := blockchain.NewUtxoViewpoint()
+	view.Entries()[tx.TxIn[0].PreviousOutPoint] = blockchain.NewUtxoEntry(&wire.TxOut{Value: 1e6, PkScript: env.PkScript}, 0, false)
+	sigHashes := txscript.NewTxSigHashes(tx, view)
+	witness, err := env.SignReveal(tx, sigHashes, 0, 1e6, revealKey, txscript.SigHashDefault)
+	if err != nil {
+		t.Fatalf("failed to sign reveal: %v", err)
+	}
+	tx.TxIn[0].Witness = witness
+	return tx, view
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	internalKey, _ := btcec.NewPrivateKey()
+	revealKey, _ := btcec.NewPrivateKey()
+
+	data := make([]byte, 200*1024)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	call := &EVMCall{To: common.HexToAddress("0x1234"), Data: data}
+	env, err := NewEnvelope(internalKey.PubKey(), revealKey.PubKey(), call)
+	if err != nil {
+		t.Fatalf("failed to build envelope: %v", err)
+	}
+	if _, err := env.Address(&chaincfg.RegressionNetParams); err != nil {
+		t.Fatalf("failed to derive commit address: %v", err)
+	}
+	tx, view := newRevealTx(t, env, revealKey)
+
+	err = blockchain.ValidateTransactionScripts(btcutil.NewTx(tx), view,
+		txscript.StandardVerifyFlags, txscript.NewSigCache(10), txscript.NewHashCache(10))
+	if err != nil {
+		t.Fatalf("reveal transaction rejected by script engine: %v", err)
+	}
+	if points := PreparePrevOutPoints(tx); len(points) != 1 || points[0] != tx.TxIn[0].PreviousOutPoint {
+		t.Fatalf("prev out points mismatch: %v", points)
+	}
+	result := ExtractEVMWitness(tx, view)
+	if len(result) != 1 {
+		t.Fatalf("extracted %d invocations, want 1", len(result))
+	}
+	got, ok := result[0].(*EVMCall)
+	if !ok {
+		t.Fatalf("extracted %T, want call", result[0])
+	}
+	if got.From != EnvelopeSender(revealKey.PubKey()) {
+		t.Errorf("sender mismatch: have %x, want %x", got.From, EnvelopeSender(revealKey.PubKey()))
+	}
+	if got.To != call.To || !bytes.Equal(got.Data, data) {
+		t.Errorf("call mismatch: have to %x and %d bytes", got.To, len(got.Data))
+	}
+}
+
+func TestEnvelopeRequiresTaprootPrevOut(t *testing.T) {
+	internalKey, _ := btcec.NewPrivateKey()
+	revealKey, _ := btcec.NewPrivateKey()
+	env, err := NewEnvelope(internalKey.PubKey(), revealKey.PubKey(), &EVMDeploy{Data: []byte{0x00}})
+	if err != nil {
+		t.Fatalf("failed to build envelope: %v", err)
+	}
+	tx, view := newRevealTx(t, env, revealKey)
+
+	// A future witness version output does not check the reveal signature.
+	unknown := append([]byte{txscript.OP_2, txscript.OP_DATA_32}, env.PkScript[2:]...)
+	view.Entries()[tx.TxIn[0].PreviousOutPoint] = blockchain.NewUtxoEntry(&wire.TxOut{Value: 1e6, PkScript: unknown}, 0, false)
+	if result := ExtractEVMWitness(tx, view); len(result) != 0 {
+		t.Fatalf("extracted envelope spending a non taproot output: %v", result)
+	}
+}
+
+func TestEnvelopeRequiresTapscriptLeaf(t *testing.T) {
+	internalKey, _ := btcec.NewPrivateKey()
+	revealKey, _ := btcec.NewPrivateKey()
+	env, err := NewEnvelope(internalKey.PubKey(), revealKey.PubKey(), &EVMDeploy{Data: []byte{0x00}})
+	if err != nil {
+		t.Fatalf("failed to build envelope: %v", err)
+	}
+	tx, view := newRevealTx(t, env, revealKey)
+
+	// Leaves of unknown versions are spent without running their script, so
+	// the reveal key signature is never checked.
+	witness := tx.TxIn[0].Witness
+	cb := witness[len(witness)-1]
+	cb[0] = 0xc2 | cb[0]&0x01
+	if result := ExtractEVMWitness(tx, view); len(result) != 0 {
+		t.Fatalf("extracted envelope of an unknown leaf version: %v", result)
+	}
+}
+
+func TestDecodeEnvelopeSmallIntPush(t *testing.T) {
+	key, _ := btcec.NewPrivateKey()
+	// the one byte init code is pushed minimally, as OP_1
+	script, err := txscript.NewScriptBuilder().AddData(schnorr.SerializePubKey(key.PubKey())).
+		AddOps([]byte{txscript.OP_CHECKSIG, txscript.OP_FALSE, txscript.OP_IF}).
+		AddData([]byte(EnvelopeTag)).AddData([]byte("evmd")).AddData([]byte{0x01}).
+		AddOp(txscript.OP_ENDIF).Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, data, err := decodeEnvelope(script)
+	if err != nil {
+		t.Fatalf("failed to decode envelope: %v", err)
+	}
+	if deploy, ok := data.(*EVMDeploy); !ok || !bytes.Equal(deploy.Data, []byte{0x01}) {
+		t.Fatalf("payload mismatch: %v", data)
+	}
+}
@@ -1,41 +1,70 @@
 package protocol
 
 import (
+	"crypto/sha256"
+
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/ethereum/go-ethereum/log"
 )
 
-func PreparePrevOutPoints(tx *wire.MsgTx) (results []wire.OutPoint) {
-	for id, txin := range tx.TxIn {
-		// currently, we only support p2wsh address
-		if len(txin.SignatureScript) != 0 || len(txin.Witness) < 2 {
-			continue
-		}
+// evmInput is an EVM invocation decoded from a transaction input, before the
+// spent output is known.
+type evmInput struct {
+	data      EVMInvokeData
+	revealKey []byte // x-only reveal key of an envelope, nil for drop scripts
+}
 
-		script := txin.Witness[len(txin.Witness)-1]
-		drops := numDrop(script)
-		if drops == 0 {
-			continue
-		}
-		if drops > len(txin.Witness)-1 {
-			log.Warn("drops too large", "tx", tx.TxHash(), "in", id, "drops", drops)
-			continue
+// decodeEVMInput decodes the EVM invocation carried by txin, either in the
+// items dropped by a P2WSH script or in a taproot inscription envelope.
+func decodeEVMInput(tx *wire.MsgTx, id int, txin *wire.TxIn) *evmInput {
+	if len(txin.SignatureScript) != 0 || len(txin.Witness) < 2 {
+		return nil
+	}
+	if leaf := taprootLeafScript(txin.Witness); leaf != nil {
+		revealKey, data, err := decodeEnvelope(leaf)
+		if err == nil {
+			return &evmInput{data: data, revealKey: revealKey}
 		}
-		var witness []byte
-		for i := 0; i < drops; i++ {
-			witness = append(witness, txin.Witness[len(txin.Witness)-2-i]...)
+		if err != errNotEnvelope {
+			log.Info("decode evm envelope error", "tx", tx.TxHash(), "in", id, "err", err)
 		}
+	}
 
-		if len(witness) == 0 {
-			continue
-		}
-		_, err := DecodeEVMWitness(witness)
-		if err != nil {
-			log.Info("decode evm witness error: ", "tx", tx.TxHash(), "in", id, "err", err)
-			continue
+	script := txin.Witness[len(txin.Witness)-1]
+	drops := numDrop(script)
+	if drops == 0 {
+		return nil
+	}
+	if drops > len(txin.Witness)-1 {
+		log.Warn("drops too large", "tx", tx.TxHash(), "in", id, "drops", drops)
+		return nil
+	}
+	var witness []byte
+	for i := 0; i < drops; i++ {
+		witness = append(witness, txin.Witness[len(txin.Witness)-2-i]...)
+	}
+
+	if len(witness) == 0 {
+		return nil
+	}
+	data, err := DecodeEVMWitness(witness)
+	if err != nil {
+		log.Info("decode evm witness error", "tx", tx.TxHash(), "in", id, "err", err)
+		return nil
+	}
+	return &evmInput{data: data}
+}
+
+func PreparePrevOutPoints(tx *wire.MsgTx) (results []wire.OutPoint) {
+	for id, txin := range tx.TxIn {
+		if decodeEVMInput(tx, id, txin) != nil {
+			results = append(results, txin.PreviousOutPoint)
 		}
-		results = append(results, txin.PreviousOutPoint)
+	}
+	// the sender of OP_RETURN invocations is derived from the first input
+	if hasOpReturnInvoke(tx) && (len(results) == 0 || results[0] != tx.TxIn[0].PreviousOutPoint) {
+		results = append(results, tx.TxIn[0].PreviousOutPoint)
 	}
 
 	return results
@@ -43,41 +72,77 @@ func PreparePrevOutPoints(tx *wire.MsgTx) (results []wire.OutPoint) {
 
 func ExtractEVMWitness(tx *wire.MsgTx, fetcher txscript.PrevOutputFetcher) (result []EVMInvokeData) {
 	for id, txin := range tx.TxIn {
-		// currently, we only support p2wsh address
-		if len(txin.SignatureScript) != 0 || len(txin.Witness) < 2 {
+		input := decodeEVMInput(tx, id, txin)
+		if input == nil {
 			continue
 		}
 
-		script := txin.Witness[len(txin.Witness)-1]
-		drops := numDrop(script)
-		if drops == 0 {
+		// delay the prev output query to here reduce the fetcher size
+		prevOut := fetcher.FetchPrevOutput(txin.PreviousOutPoint)
+		if prevOut == nil {
 			continue
 		}
-		if drops > len(txin.Witness)-1 {
-			log.Warn("drops too large", "tx", tx.TxHash(), "in", id, "drops", drops)
+		pkscript, err := txscript.ParsePkScript(prevOut.PkScript)
+		if err != nil {
 			continue
 		}
-		var witness []byte
-		for i := 0; i < drops; i++ {
-			witness = append(witness, txin.Witness[len(txin.Witness)-2-i]...)
+		data := input.data
+		_, unified := data.(*EVMUnified)
+		if input.revealKey != nil {
+			// only taproot outputs enforce the reveal key signature
+			if pkscript.Class() != txscript.WitnessV1TaprootTy || unified {
+				continue
+			}
+			hash := sha256.Sum256(input.revealKey)
+			data.SetFrom(Ripemd160(hash[:]))
+		} else {
+			if pkscript.Class() != txscript.WitnessV0ScriptHashTy {
+				continue
+			}
+			if unified {
+				// the script hash is checked by the btc script engine, so the
+				// key in the script is the one that signed the spend
+				from, err := unifiedSender(txin.Witness[len(txin.Witness)-1])
+				if err != nil {
+					log.Info("ignore unified evm invocation", "tx", tx.TxHash(), "in", id, "err", err)
+					continue
+				}
+				data = unwrapUnified(data)
+				data.SetFrom(from)
+			} else {
+				data.SetFrom(Ripemd160(pkscript.Script()[2:34]))
+			}
 		}
+		result = append(result, data)
+	}
+	if hasOpReturnInvoke(tx) {
+		result = append(result, extractOpReturns(tx, fetcher)...)
+	}
 
-		if len(witness) == 0 {
-			continue
-		}
-		data, err := DecodeEVMWitness(witness)
+	return result
+}
+
+// extractOpReturns returns the invocations carried by the OP_RETURN outputs of
+// tx, in output order, if its first input identifies the sender.
+func extractOpReturns(tx *wire.MsgTx, fetcher txscript.PrevOutputFetcher) (result []EVMInvokeData) {
+	prevOut := fetcher.FetchPrevOutput(tx.TxIn[0].PreviousOutPoint)
+	if prevOut == nil {
+		return nil
+	}
+	from, err := opReturnSender(tx.TxIn[0], prevOut)
+	if err != nil {
+		log.Info("ignore evm OP_RETURN", "tx", tx.TxHash(), "err", err)
+		return nil
+	}
+	for _, txout := range tx.TxOut {
+		data, err := decodeOpReturn(txout.PkScript)
 		if err != nil {
-			log.Info("decode evm witness error", "tx", tx.TxHash(), "in", id, "err", err)
 			continue
 		}
-
-		// delay the prev output query to here reduce the fetcher size
-		prevOut := fetcher.FetchPrevOutput(txin.PreviousOutPoint)
-		pkscript, err := txscript.ParsePkScript(prevOut.PkScript)
-		if err != nil || pkscript.Class() != txscript.WitnessV0ScriptHashTy {
+		if _, ok := data.(*EVMUnified); ok {
 			continue
 		}
-		data.SetFrom(Ripemd160(pkscript.Script()[2:34]))
+		data.SetFrom(from)
 		result = append(result, data)
 	}
 
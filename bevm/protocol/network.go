@@ -0,0 +1,151 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// chainNames maps the known networks to the chain name bitcoind reports in
+// getblockchaininfo. btcd reports the network name itself.
+var chainNames = map[string]string{
+	chaincfg.MainNetParams.Name:       "main",
+	chaincfg.TestNet3Params.Name:      "test",
+	chaincfg.RegressionNetParams.Name: "regtest",
+	chaincfg.SigNetParams.Name:        "signet",
+	chaincfg.SimNetParams.Name:        "simnet",
+}
+
+// NetworkConfig defines a custom BTC network on top of a known one, e.g. a
+// private signet:
+//
+//	{"name": "mysignet", "base": "signet", "signetChallenge": "0x5121...51ae"}
+type NetworkConfig struct {
+	Name            string        `json:"name"`
+	Base            string        `json:"base"`                      // known network the parameters are copied from
+	Chain           string        `json:"chain,omitempty"`           // chain reported by getblockchaininfo, defaults to that of base
+	SignetChallenge hexutil.Bytes `json:"signetChallenge,omitempty"` // block challenge of a custom signet
+	GenesisHash     string        `json:"genesisHash,omitempty"`     // overrides the genesis block hash of base
+	Bech32HRPSegwit string        `json:"bech32HRPSegwit,omitempty"` // overrides the segwit address prefix of base
+	Net             uint32        `json:"net,omitempty"`             // overrides the message start of base
+}
+
+// Params derives the parameters of the custom network.
+func (config *NetworkConfig) Params() (*chaincfg.Params, error) {
+	if config.Name == "" {
+		return nil, errors.New("missing network name")
+	}
+	base, err := NetParamsByName(config.Base)
+	if err != nil {
+		return nil, err
+	}
+	params := *base
+	if len(config.SignetChallenge) > 0 {
+		if base.Name != chaincfg.SigNetParams.Name {
+			return nil, fmt.Errorf("network %s: signet challenge requires a signet base", config.Name)
+		}
+		params = chaincfg.CustomSignetParams(config.SignetChallenge, nil)
+	}
+	params.Name = config.Name
+	if config.GenesisHash != "" {
+		hash, err := chainhash.NewHashFromStr(config.GenesisHash)
+		if err != nil {
+			return nil, fmt.Errorf("network %s: invalid genesis hash: %v", config.Name, err)
+		}
+		params.GenesisHash = hash
+	}
+	if config.Bech32HRPSegwit != "" {
+		params.Bech32HRPSegwit = config.Bech32HRPSegwit
+	}
+	if config.Net != 0 {
+		params.Net = wire.BitcoinNet(config.Net)
+	}
+	return &params, nil
+}
+
+// LoadNetworks registers the custom networks defined by the JSON array of
+// NetworkConfig in the given file.
+func LoadNetworks(path string) ([]*chaincfg.Params, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs []*NetworkConfig
+	if err := json.Unmarshal(raw, &configs); err != nil {
+		return nil, fmt.Errorf("invalid network config %s: %v", path, err)
+	}
+	var nets []*chaincfg.Params
+	for _, config := range configs {
+		params, err := config.Params()
+		if err != nil {
+			return nil, err
+		}
+		chain := config.Chain
+		if chain == "" {
+			chain = ChainName(&chaincfg.Params{Name: config.Base})
+		}
+		if err := RegisterNet(params, chain); err != nil {
+			return nil, err
+		}
+		nets = append(nets, params)
+	}
+	return nets, nil
+}
+
+// RegisterNet makes a custom network known to bevm, both for resolving
+// addresses and for checking the BTC node, which reports it as chain.
+func RegisterNet(params *chaincfg.Params, chain string) error {
+	if _, err := NetParamsByName(params.Name); err == nil {
+		return fmt.Errorf("btc network %s already registered", params.Name)
+	}
+	if err := registerNet(params); err != nil {
+		return err
+	}
+	if err := registerEVM(*params); err != nil {
+		return err
+	}
+	KnownNets = append(KnownNets, params)
+	chainNames[params.Name] = chain
+	return nil
+}
+
+// registerNet registers params with btcutil. Networks sharing the message start
+// of a registered network are fine as long as their address prefix is known.
+func registerNet(params *chaincfg.Params) error {
+	err := chaincfg.Register(params)
+	if err == chaincfg.ErrDuplicateNet && chaincfg.IsBech32SegwitPrefix(params.Bech32HRPSegwit+"1") {
+		return nil
+	}
+	return err
+}
+
+// ChainName returns the chain name bitcoind reports for net.
+func ChainName(net *chaincfg.Params) string {
+	if chain, ok := chainNames[net.Name]; ok {
+		return chain
+	}
+	return net.Name
+}
+
+// IsChain reports whether a BTC node reporting chain in getblockchaininfo may
+// be serving net. Both bitcoind and btcd naming is accepted.
+func IsChain(net *chaincfg.Params, chain string) bool {
+	return chain == ChainName(net) || chain == net.Name
+}
+
+// NetParamsByChain returns the first known network a BTC node reporting chain
+// and the given genesis hash may be serving.
+func NetParamsByChain(chain string, genesis *chainhash.Hash) (*chaincfg.Params, error) {
+	for _, net := range KnownNets {
+		if IsChain(net, chain) && net.GenesisHash.IsEqual(genesis) {
+			return net, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown btc chain %s with genesis %s", chain, genesis)
+}
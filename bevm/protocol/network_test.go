@@ -0,0 +1,61 @@
+package protocol
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignetAddress(t *testing.T) {
+	pubHex := common.Bytes2Hex(privateKey.PubKey().SerializeCompressed())
+	fromPub, err := ConvertAddress(pubHex, &chaincfg.SigNetParams)
+	assert.Nil(t, err)
+	assert.Equal(t, "signet", fromPub.Network)
+
+	// Signet shares the address prefixes of testnet3.
+	info, err := ConvertAddress(fromPub.EVMScriptAddress, &chaincfg.SigNetParams)
+	assert.Nil(t, err)
+	assert.Equal(t, "signet", info.Network)
+	info, err = ConvertAddress(fromPub.EVMScriptAddress, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "testnet3", info.Network)
+	assert.Equal(t, *fromPub.EVMAddress, *info.EVMAddress)
+}
+
+func TestLoadNetworks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "networks.json")
+	config := `[{"name": "customsignet", "base": "signet", "bech32HRPSegwit": "sbt",
+		"signetChallenge": "0x512102a3b8e5e2d3c1f5d7a6b9c8e1f2a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c551ae"}]`
+	assert.Nil(t, os.WriteFile(path, []byte(config), 0600))
+
+	nets, err := LoadNetworks(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(nets))
+	net := nets[0]
+	assert.NotEqual(t, chaincfg.SigNetParams.Net, net.Net)
+	assert.True(t, net.GenesisHash.IsEqual(chaincfg.SigNetParams.GenesisHash))
+
+	byName, err := NetParamsByName("customsignet")
+	assert.Nil(t, err)
+	assert.Equal(t, net, byName)
+	assert.Equal(t, "signet", ChainName(net))
+	assert.True(t, IsChain(net, "signet"))
+	assert.False(t, IsChain(net, "test"))
+
+	pubHex := common.Bytes2Hex(privateKey.PubKey().SerializeCompressed())
+	fromPub, err := ConvertAddress(pubHex, net)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(fromPub.EVMScriptAddress, "sbte1"))
+	info, err := ConvertAddress(fromPub.EVMScriptAddress, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "customsignet", info.Network)
+	assert.Equal(t, *fromPub.EVMAddress, *info.EVMAddress)
+
+	_, err = LoadNetworks(path)
+	assert.NotNil(t, err)
+}
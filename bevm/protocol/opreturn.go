@@ -0,0 +1,122 @@
+package protocol
+
+import (
+	"errors"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// An EVM invocation can also be carried by an OP_RETURN output, avoiding the
+// funding transaction of an EVM script address:
+//
+//	OP_RETURN <evm data chunk0> <evm data chunk1> ...
+//
+// The sender is derived from the first input of the transaction, which must be
+// a P2WPKH or P2TR key path spend whose signature commits to all outputs.
+
+var (
+	errNotOpReturn      = errors.New("not an evm OP_RETURN output")
+	errNoOpReturnSender = errors.New("first input is not a signed P2WPKH or P2TR key path spend")
+)
+
+// NewOpReturnScript builds the OP_RETURN output script carrying evmData.
+func NewOpReturnScript(evmData EVMInvokeData) ([]byte, error) {
+	builder := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN)
+	payload := BytesConcat(evmData.ToWitness()...)
+	for len(payload) > 0 {
+		n := len(payload)
+		if n > txscript.MaxScriptElementSize {
+			n = txscript.MaxScriptElementSize
+		}
+		builder.AddData(payload[:n])
+		payload = payload[n:]
+	}
+	return builder.Script()
+}
+
+// AddOpReturnEVMOutput appends a zero value OP_RETURN output carrying evmData to
+// tx. The first input of tx must be signed with SIGHASH_ALL by the sender.
+func AddOpReturnEVMOutput(tx *wire.MsgTx, evmData EVMInvokeData) error {
+	script, err := NewOpReturnScript(evmData)
+	if err != nil {
+		return err
+	}
+	tx.AddTxOut(wire.NewTxOut(0, script))
+	return nil
+}
+
+// OpReturnSender returns the EVM account of OP_RETURN invocations whose first
+// input spends an output with the given script.
+func OpReturnSender(prevPkScript []byte) (common.Address, error) {
+	class := txscript.GetScriptClass(prevPkScript)
+	if class != txscript.WitnessV0PubKeyHashTy && class != txscript.WitnessV1TaprootTy {
+		return common.Address{}, errNoOpReturnSender
+	}
+	return Ripemd160(prevPkScript[2:]), nil
+}
+
+// decodeOpReturn decodes the EVM invocation carried by an OP_RETURN script.
+func decodeOpReturn(pkScript []byte) (EVMInvokeData, error) {
+	if len(pkScript) == 0 || pkScript[0] != txscript.OP_RETURN {
+		return nil, errNotOpReturn
+	}
+	pushes, err := txscript.PushedData(pkScript[1:])
+	if err != nil || len(pushes) == 0 {
+		return nil, errNotOpReturn
+	}
+	return DecodeEVMWitness(BytesConcat(pushes...))
+}
+
+// hasOpReturnInvoke reports whether any output of tx carries an EVM invocation.
+func hasOpReturnInvoke(tx *wire.MsgTx) bool {
+	if blockchain.IsCoinBaseTx(tx) {
+		return false
+	}
+	for _, txout := range tx.TxOut {
+		if _, err := decodeOpReturn(txout.PkScript); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// opReturnSender derives the sender of the OP_RETURN invocations of tx from its
+// first input, spending prevOut.
+func opReturnSender(txin *wire.TxIn, prevOut *wire.TxOut) (common.Address, error) {
+	if len(txin.SignatureScript) != 0 {
+		return common.Address{}, errNoOpReturnSender
+	}
+	from, err := OpReturnSender(prevOut.PkScript)
+	if err != nil {
+		return common.Address{}, err
+	}
+	// The signature must commit to every output, otherwise anyone could attach
+	// an invocation to the signed input.
+	var sig []byte
+	switch witness := txin.Witness; txscript.GetScriptClass(prevOut.PkScript) {
+	case txscript.WitnessV0PubKeyHashTy:
+		if len(witness) != 2 || len(witness[0]) == 0 {
+			return common.Address{}, errNoOpReturnSender
+		}
+		sig = witness[0]
+	case txscript.WitnessV1TaprootTy:
+		if len(witness) == 2 && len(witness[1]) > 0 && witness[1][0] == txscript.TaprootAnnexTag {
+			witness = witness[:1]
+		}
+		if len(witness) != 1 || len(witness[0]) == 0 {
+			return common.Address{}, errNoOpReturnSender
+		}
+		sig = witness[0]
+		if len(sig) == 64 {
+			return from, nil // SIGHASH_DEFAULT
+		}
+	}
+	switch txscript.SigHashType(sig[len(sig)-1]) {
+	case txscript.SigHashAll, txscript.SigHashAll | txscript.SigHashAnyOneCanPay:
+		return from, nil
+	}
+	return common.Address{}, errors.New("sender signature does not commit to all outputs")
+}
@@ -0,0 +1,109 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// newOpReturnTx builds a transaction spending an output with prevScript and
+// carrying call in an OP_RETURN output. sign fills in the input witness.
+func newOpReturnTx(t *testing.T, prevScript []byte, call EVMInvokeData,
+	sign func(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes) wire.TxWitness) (*wire.MsgTx, *blockchain.UtxoViewpoint) {
+	prevHash := chainhash.Hash{0x0f}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevHash, 1), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(9e4, prevScript))
+	if err := AddOpReturnEVMOutput(tx, call); err != nil {
+		t.Fatalf("failed to add OP_RETURN output: %v", err)
+	}
+	view := blockchain.NewUtxoViewpoint()
+	view.Entries()[tx.TxIn[0].PreviousOutPoint] = blockchain.NewUtxoEntry(&wire.TxOut{Value: 1e5, PkScript: prevScript}, 0, false)
+	tx.TxIn[0].Witness = sign(tx, txscript.NewTxSigHashes(tx, view))
+
+	err := blockchain.ValidateTransactionScripts(btcutil.NewTx(tx), view,
+		txscript.StandardVerifyFlags, txscript.NewSigCache(10), txscript.NewHashCache(10))
+	if err != nil {
+		t.Fatalf("transaction rejected by script engine: %v", err)
+	}
+	return tx, view
+}
+
+func p2wpkhSigner(t *testing.T, key *btcec.PrivateKey, prevScript []byte, hashType txscript.SigHashType) func(*wire.MsgTx, *txscript.TxSigHashes) wire.TxWitness {
+	return func(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes) wire.TxWitness {
+		witness, err := txscript.WitnessSignature(tx, sigHashes, 0, 1e5, prevScript, hashType, key, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return witness
+	}
+}
+
+func TestOpReturnP2WPKHSender(t *testing.T) {
+	key, _ := btcec.NewPrivateKey()
+	prevScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(btcutil.Hash160(key.PubKey().SerializeCompressed())).Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := &EVMCall{To: common.HexToAddress("0xabcd"), Data: []byte{0xa9, 0x05, 0x9c, 0xbb}}
+	tx, view := newOpReturnTx(t, prevScript, call, p2wpkhSigner(t, key, prevScript, txscript.SigHashAll))
+
+	if points := PreparePrevOutPoints(tx); len(points) != 1 || points[0] != tx.TxIn[0].PreviousOutPoint {
+		t.Fatalf("prev out points mismatch: %v", points)
+	}
+	result := ExtractEVMWitness(tx, view)
+	if len(result) != 1 {
+		t.Fatalf("extracted %d invocations, want 1", len(result))
+	}
+	want, _ := OpReturnSender(prevScript)
+	got := result[0].(*EVMCall)
+	if got.From != want || got.To != call.To || !bytes.Equal(got.Data, call.Data) {
+		t.Fatalf("call mismatch: have %+v, want from %x", got, want)
+	}
+}
+
+func TestOpReturnRequiresSigHashAll(t *testing.T) {
+	key, _ := btcec.NewPrivateKey()
+	prevScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(btcutil.Hash160(key.PubKey().SerializeCompressed())).Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := &EVMCall{To: common.HexToAddress("0xabcd")}
+	tx, view := newOpReturnTx(t, prevScript, call, p2wpkhSigner(t, key, prevScript, txscript.SigHashNone))
+	if result := ExtractEVMWitness(tx, view); len(result) != 0 {
+		t.Fatalf("extracted invocation signed with SIGHASH_NONE: %v", result)
+	}
+}
+
+func TestOpReturnTaprootKeyPathSender(t *testing.T) {
+	key, _ := btcec.NewPrivateKey()
+	outputKey := txscript.ComputeTaprootKeyNoScript(key.PubKey())
+	prevScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_1).AddData(schnorr.SerializePubKey(outputKey)).Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deploy := &EVMDeploy{Data: []byte{0x60, 0x00, 0x60, 0x00, 0xf3}}
+	tx, view := newOpReturnTx(t, prevScript, deploy, func(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes) wire.TxWitness {
+		witness, err := txscript.TaprootWitnessSignature(tx, sigHashes, 0, 1e5, prevScript, txscript.SigHashDefault, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return witness
+	})
+	result := ExtractEVMWitness(tx, view)
+	if len(result) != 1 {
+		t.Fatalf("extracted %d invocations, want 1", len(result))
+	}
+	want, _ := OpReturnSender(prevScript)
+	if got := result[0].(*EVMDeploy); got.From != want || !bytes.Equal(got.Data, deploy.Data) {
+		t.Fatalf("deploy mismatch: have %+v, want from %x", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const (
+	// EVMCallDrops is the number of witness items an EVM script drops for calls.
+	EVMCallDrops = 20
+	// EVMDeployDrops is the number of witness items an EVM script drops for deploys.
+	EVMDeployDrops = 98
+)
+
+var errPolicyStartsWithDrop = errors.New("evm policy script must not start with a drop opcode")
+
+// NewEVMScript prefixes a policy script with the OP_2DROPs that discard the EVM
+// invoke data from the witness stack. The policy decides who may spend the
+// output, the EVM account is derived from the hash of the whole script.
+//
+// Note EVM scripts are v0 witness scripts, so multi-signature policies have to
+// use OP_CHECKMULTISIG rather than the tapscript only OP_CHECKSIGADD.
+func NewEVMScript(policy []byte, deploy ...bool) ([]byte, error) {
+	if len(policy) == 0 {
+		return nil, errors.New("empty evm policy script")
+	}
+	if policy[0] == txscript.OP_DROP || policy[0] == txscript.OP_2DROP {
+		return nil, errPolicyStartsWithDrop
+	}
+	drops := EVMCallDrops
+	if len(deploy) > 0 && deploy[0] {
+		drops = EVMDeployDrops
+	}
+	builder := txscript.NewScriptBuilder()
+	for i := 0; i < drops/2; i++ {
+		builder.AddOp(txscript.OP_2DROP)
+	}
+	prefix, err := builder.Script()
+	if err != nil {
+		return nil, err
+	}
+	script := BytesConcat(prefix, policy)
+	if len(script) > MAX_STANDARD_P2WSH_SCRIPT_SIZE {
+		return nil, fmt.Errorf("evm script too large: %d", len(script))
+	}
+
+	return script, nil
+}
+
+// SplitEVMScript splits an EVM script into the number of witness items it drops
+// and the policy script that follows the drops.
+func SplitEVMScript(script []byte) (int, []byte) {
+	drops := numDrop(script)
+	ops := 0
+	for _, b := range script {
+		if b != txscript.OP_DROP && b != txscript.OP_2DROP {
+			break
+		}
+		ops++
+	}
+
+	return drops, script[ops:]
+}
+
+// NewPubKeyPolicy returns the single key policy `<pubkey> OP_CHECKSIG`.
+func NewPubKeyPolicy(pub *btcec.PublicKey) []byte {
+	policy, _ := txscript.NewScriptBuilder().AddData(pub.SerializeCompressed()).AddOp(txscript.OP_CHECKSIG).Script()
+	return policy
+}
+
+// NewMultiSigPolicy returns the m-of-n policy
+// `OP_m <pubkey1> ... <pubkeyn> OP_n OP_CHECKMULTISIG`.
+func NewMultiSigPolicy(m int, pubs []*btcec.PublicKey) ([]byte, error) {
+	if m < 1 || m > len(pubs) || len(pubs) > txscript.MaxPubKeysPerMultiSig {
+		return nil, fmt.Errorf("invalid multisig policy: %d of %d", m, len(pubs))
+	}
+	// Standard P2WSH scripts are limited to 16 keys by the small integer opcodes.
+	if len(pubs) > 16 {
+		return nil, fmt.Errorf("too many multisig keys: %d", len(pubs))
+	}
+	builder := txscript.NewScriptBuilder().AddInt64(int64(m))
+	for _, pub := range pubs {
+		builder.AddData(pub.SerializeCompressed())
+	}
+	return builder.AddInt64(int64(len(pubs))).AddOp(txscript.OP_CHECKMULTISIG).Script()
+}
+
+// NewAbsoluteTimeLockPolicy guards a policy with an absolute lock time
+// (BIP-65): `<locktime> OP_CHECKLOCKTIMEVERIFY OP_DROP <policy>`.
+func NewAbsoluteTimeLockPolicy(lockTime int64, policy []byte) ([]byte, error) {
+	return newTimeLockPolicy(lockTime, txscript.OP_CHECKLOCKTIMEVERIFY, policy)
+}
+
+// NewRelativeTimeLockPolicy guards a policy with a relative lock time
+// (BIP-112): `<sequence> OP_CHECKSEQUENCEVERIFY OP_DROP <policy>`.
+func NewRelativeTimeLockPolicy(sequence int64, policy []byte) ([]byte, error) {
+	return newTimeLockPolicy(sequence, txscript.OP_CHECKSEQUENCEVERIFY, policy)
+}
+
+func newTimeLockPolicy(lock int64, op byte, policy []byte) ([]byte, error) {
+	if lock <= 0 {
+		return nil, fmt.Errorf("invalid lock: %d", lock)
+	}
+	prefix, err := txscript.NewScriptBuilder().AddInt64(lock).AddOp(op).AddOp(txscript.OP_DROP).Script()
+	if err != nil {
+		return nil, err
+	}
+
+	return BytesConcat(prefix, policy), nil
+}
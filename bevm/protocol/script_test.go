@@ -0,0 +1,129 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// spendEVMScript builds a transaction spending an output locked to the given
+// EVM script, signs it with sign and checks it against the script engine.
+func spendEVMScript(t *testing.T, script []byte, lockTime uint32, sequence uint32,
+	sign func(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes) (wire.TxWitness, error)) *wire.MsgTx {
+	addr := NewAddressEVMFromScript(script, &chaincfg.TestNet3Params)
+	payScript, err := PayToAddrScript(addr)
+	assert.Nil(t, err)
+
+	tx := wire.NewMsgTx(2)
+	tx.LockTime = lockTime
+	prevHash, _ := chainhash.NewHashFromStr("cf5eaad6c16fd78166fd3bea28727de0025b45a78953e2d9780e13225a910859")
+	tx.AddTxIn(&wire.TxIn{PreviousOutPoint: *wire.NewOutPoint(prevHash, 0), Sequence: sequence})
+	tx.AddTxOut(&wire.TxOut{Value: 8000, PkScript: payScript})
+
+	fetcher := blockchain.NewUtxoViewpoint()
+	fetcher.Entries()[tx.TxIn[0].PreviousOutPoint] = blockchain.NewUtxoEntry(&wire.TxOut{Value: 9208, PkScript: payScript}, 0, false)
+	witness, err := sign(tx, txscript.NewTxSigHashes(tx, fetcher))
+	assert.Nil(t, err)
+	tx.TxIn[0].Witness = witness
+
+	err = blockchain.ValidateTransactionScripts(btcutil.NewTx(tx), fetcher,
+		txscript.StandardVerifyFlags, txscript.NewSigCache(10), txscript.NewHashCache(10))
+	assert.Nil(t, err)
+
+	data := ExtractEVMWitness(tx, fetcher)
+	assert.Equal(t, 1, len(data))
+	assert.Equal(t, common.Address(Ripemd160(addr.WitnessProgram())), data[0].(*EVMCall).From)
+
+	return tx
+}
+
+func TestMultiSigEVMScript(t *testing.T) {
+	var (
+		keys []*btcec.PrivateKey
+		pubs []*btcec.PublicKey
+	)
+	for i := 0; i < 3; i++ {
+		key, err := btcec.NewPrivateKey()
+		assert.Nil(t, err)
+		keys = append(keys, key)
+		pubs = append(pubs, key.PubKey())
+	}
+	policy, err := NewMultiSigPolicy(2, pubs)
+	assert.Nil(t, err)
+	script, err := NewEVMScript(policy)
+	assert.Nil(t, err)
+
+	drops, split := SplitEVMScript(script)
+	assert.Equal(t, EVMCallDrops, drops)
+	assert.Equal(t, policy, split)
+
+	call := &EVMCall{To: common.HexToAddress("0x1234"), Data: []byte("hello multisig")}
+	spendEVMScript(t, script, 0, wire.MaxTxInSequenceNum, func(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes) (wire.TxWitness, error) {
+		return EVMWitnessMultiSignature(tx, sigHashes, 0, 9208, script, txscript.SigHashAll, []*btcec.PrivateKey{keys[0], keys[2]}, call)
+	})
+
+	_, err = NewMultiSigPolicy(4, pubs)
+	assert.NotNil(t, err)
+}
+
+func TestTimeLockEVMScript(t *testing.T) {
+	policy, err := NewAbsoluteTimeLockPolicy(500, NewPubKeyPolicy(privateKey.PubKey()))
+	assert.Nil(t, err)
+	script, err := NewEVMScript(policy)
+	assert.Nil(t, err)
+
+	call := &EVMCall{To: common.HexToAddress("0x1234"), Data: []byte("hello timelock")}
+	spendEVMScript(t, script, 600, 0, func(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes) (wire.TxWitness, error) {
+		return EVMWitnessSignature(tx, sigHashes, 0, 9208, script, txscript.SigHashAll, privateKey, call)
+	})
+
+	_, err = NewEVMScript(append([]byte{txscript.OP_DROP}, policy...))
+	assert.Equal(t, errPolicyStartsWithDrop, err)
+}
+
+func TestExternalEVMWitnessSignature(t *testing.T) {
+	script := NewEVMScriptFromPubKey(privateKey.PubKey())
+	call := &EVMCall{To: common.HexToAddress("0x1234"), Data: []byte("hello keystore")}
+	spendEVMScript(t, script, 0, wire.MaxTxInSequenceNum, func(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes) (wire.TxWitness, error) {
+		hash, err := EVMWitnessSigHash(tx, 0, 9208, script, txscript.SigHashAll)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := crypto.Sign(hash, privateKey.ToECDSA())
+		if err != nil {
+			return nil, err
+		}
+		der, err := EncodeWitnessSignature(sig, txscript.SigHashAll)
+		if err != nil {
+			return nil, err
+		}
+		return BuildEVMWitness([][]byte{der}, script, call)
+	})
+
+	_, err := EncodeWitnessSignature(make([]byte, 64), txscript.SigHashAll)
+	assert.NotNil(t, err)
+}
+
+func TestPubKeyEVMScriptCompat(t *testing.T) {
+	// The single key script must stay byte for byte identical, as existing
+	// EVM accounts are derived from its hash.
+	legacy := bytes.Repeat([]byte{txscript.OP_2DROP}, EVMCallDrops/2)
+	legacy = append(legacy, txscript.OP_DATA_33)
+	legacy = append(legacy, privateKey.PubKey().SerializeCompressed()...)
+	legacy = append(legacy, txscript.OP_CHECKSIG)
+
+	script, err := NewEVMScript(NewPubKeyPolicy(privateKey.PubKey()))
+	assert.Nil(t, err)
+	assert.Equal(t, legacy, script)
+	assert.Equal(t, legacy, NewEVMScriptFromPubKey(privateKey.PubKey()))
+}
@@ -1,19 +1,26 @@
 package protocol
 
 import (
+	"crypto/sha256"
 	"fmt"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
+// HashSigner signs a hash with a key held outside of this process, e.g. in a
+// keystore, and returns the signature in the [R || S || V] format.
+type HashSigner func(hash []byte) ([]byte, error)
+
+// pubKeyProbe is the hash signed to recover the public key of a HashSigner.
+var pubKeyProbe = crypto.Keccak256([]byte("bevm account public key"))
+
 func EVMWitnessSign(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes, idx int, amt int64,
 	privKey *btcec.PrivateKey, evmData EVMInvokeData) (wire.TxWitness, error) {
-	deploy := false
-	if _, ok := evmData.(*EVMDeploy); ok {
-		deploy = true
-	}
+	_, deploy := unwrapUnified(evmData).(*EVMDeploy)
 	subscript := NewEVMScriptFromPubKey(privKey.PubKey(), deploy)
 
 	return EVMWitnessSignature(tx, sigHashes, idx, amt, subscript, txscript.SigHashAll, privKey, evmData)
@@ -21,12 +28,104 @@ func EVMWitnessSign(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes, idx int, am
 
 func EVMWitnessSignature(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes, idx int, amt int64,
 	subscript []byte, hashType txscript.SigHashType, privKey *btcec.PrivateKey, evmData EVMInvokeData) (wire.TxWitness, error) {
-	var witness [][]byte
 	sig, err := txscript.RawTxInWitnessSignature(tx, sigHashes, idx, amt, subscript, hashType, privKey)
 	if err != nil {
 		return nil, err
 	}
-	witness = append(witness, sig)
+
+	return BuildEVMWitness([][]byte{sig}, subscript, evmData)
+}
+
+// EVMWitnessMultiSignature signs an input spending an EVM script guarded by an
+// OP_CHECKMULTISIG policy. The private keys must be given in the order their
+// public keys appear in the policy.
+func EVMWitnessMultiSignature(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes, idx int, amt int64,
+	subscript []byte, hashType txscript.SigHashType, privKeys []*btcec.PrivateKey, evmData EVMInvokeData) (wire.TxWitness, error) {
+	// OP_CHECKMULTISIG pops one extra item, which must be empty (NULLDUMMY).
+	policyWitness := [][]byte{nil}
+	for _, privKey := range privKeys {
+		sig, err := txscript.RawTxInWitnessSignature(tx, sigHashes, idx, amt, subscript, hashType, privKey)
+		if err != nil {
+			return nil, err
+		}
+		policyWitness = append(policyWitness, sig)
+	}
+
+	return BuildEVMWitness(policyWitness, subscript, evmData)
+}
+
+// EVMWitnessSigHash returns the BIP-143 hash signed by the idx input of tx,
+// which spends amt satoshi from the P2WSH output of subscript. It lets the
+// signature be made by keys held outside of this process, e.g. in a keystore.
+func EVMWitnessSigHash(tx *wire.MsgTx, idx int, amt int64, subscript []byte, hashType txscript.SigHashType) ([]byte, error) {
+	scriptHash := sha256.Sum256(subscript)
+	pkScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(scriptHash[:]).Script()
+	if err != nil {
+		return nil, err
+	}
+	sigHashes := txscript.NewTxSigHashes(tx, txscript.NewCannedPrevOutputFetcher(pkScript, amt))
+	return txscript.CalcWitnessSigHash(subscript, sigHashes, hashType, tx, idx, amt)
+}
+
+// SignerPubKey recovers the public key of sign, which keystores do not expose,
+// by signing a fixed hash with it.
+func SignerPubKey(sign HashSigner) (*btcec.PublicKey, error) {
+	sig, err := sign(pubKeyProbe)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := crypto.Ecrecover(pubKeyProbe, sig)
+	if err != nil {
+		return nil, err
+	}
+	return btcec.ParsePubKey(pub)
+}
+
+// EVMWitnessSignWith is EVMWitnessSign for a key held by sign: it signs the idx
+// input of tx, which spends amt satoshi from the EVM script of the key, with
+// SIGHASH_ALL and returns its witness carrying evmData. The first item of the
+// witness is the signature.
+func EVMWitnessSignWith(tx *wire.MsgTx, idx int, amt int64, sign HashSigner, evmData EVMInvokeData) (wire.TxWitness, error) {
+	pub, err := SignerPubKey(sign)
+	if err != nil {
+		return nil, err
+	}
+	_, deploy := unwrapUnified(evmData).(*EVMDeploy)
+	subscript := NewEVMScriptFromPubKey(pub, deploy)
+	hash, err := EVMWitnessSigHash(tx, idx, amt, subscript, txscript.SigHashAll)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := sign(hash)
+	if err != nil {
+		return nil, err
+	}
+	der, err := EncodeWitnessSignature(sig, txscript.SigHashAll)
+	if err != nil {
+		return nil, err
+	}
+	return BuildEVMWitness([][]byte{der}, subscript, evmData)
+}
+
+// EncodeWitnessSignature converts a signature in the [R || S || V] format of
+// the Ethereum keystores into the DER encoding followed by the hash type used
+// in BTC witnesses.
+func EncodeWitnessSignature(sig []byte, hashType txscript.SigHashType) ([]byte, error) {
+	if len(sig) != 65 {
+		return nil, fmt.Errorf("invalid signature length: %d", len(sig))
+	}
+	var r, s btcec.ModNScalar
+	if r.SetByteSlice(sig[:32]) || s.SetByteSlice(sig[32:64]) {
+		return nil, fmt.Errorf("signature overflows the curve order")
+	}
+	return append(ecdsa.NewSignature(&r, &s).Serialize(), byte(hashType)), nil
+}
+
+// BuildEVMWitness assembles the witness of an input spending an EVM script: the
+// items satisfying the policy, then the EVM invoke data padded to the number of
+// items dropped by the script, and finally the script itself.
+func BuildEVMWitness(policyWitness [][]byte, subscript []byte, evmData EVMInvokeData) (wire.TxWitness, error) {
+	witness := append([][]byte{}, policyWitness...)
 	drops := numDrop(subscript)
 	var evmWit [][]byte
 	if evmData != nil {
@@ -41,6 +140,9 @@ func EVMWitnessSignature(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes, idx in
 	for i := 0; i < len(evmWit); i++ {
 		witness = append(witness, evmWit[len(evmWit)-i-1])
 	}
+	if len(witness) > MAX_STANDARD_P2WSH_STACK_ITEMS {
+		return nil, fmt.Errorf("too many witness items: %d", len(witness))
+	}
 	witness = append(witness, subscript)
 
 	return witness, nil
@@ -25,6 +25,13 @@ type EVMDeploy struct {
 	Data []byte
 }
 
+// EVMRelay carries a signed Ethereum transaction. From is the BTC spender
+// relaying it, the EVM sender is the signer of the transaction.
+type EVMRelay struct {
+	From common.Address
+	Tx   []byte // canonical encoding of the signed transaction
+}
+
 func (self *EVMCall) SetFrom(from common.Address) {
 	self.From = from
 }
@@ -43,6 +50,15 @@ func (self *EVMDeploy) ToWitness() [][]byte {
 	return EncodeToWitness(encoded)
 }
 
+func (self *EVMRelay) SetFrom(from common.Address) {
+	self.From = from
+}
+
+func (self *EVMRelay) ToWitness() [][]byte {
+	encoded := BytesConcat([]byte("evme"), self.Tx)
+	return EncodeToWitness(encoded)
+}
+
 func EncodeToWitness(encoded []byte) [][]byte {
 	var witness [][]byte
 	itemSize := MAX_STANDARD_P2WSH_STACK_ITEM_SIZE
@@ -71,6 +87,13 @@ func DecodeEVMWitness(witness []byte) (EVMInvokeData, error) {
 		return call, nil
 	} else if bytes.HasPrefix(witness, []byte("evmd")) {
 		return &EVMDeploy{Data: witness[4:]}, nil
+	} else if bytes.HasPrefix(witness, []byte("evme")) {
+		if len(witness) == 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return &EVMRelay{Tx: witness[4:]}, nil
+	} else if bytes.HasPrefix(witness, []byte(UnifiedTag)) {
+		return decodeUnified(witness[4:])
 	}
 
 	return nil, errors.New("wrong evm prefix")
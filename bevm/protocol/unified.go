@@ -0,0 +1,77 @@
+package protocol
+
+import (
+	"errors"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// By default the EVM account of an invocation is derived from the BTC script,
+// so the same secp256k1 key owns unrelated accounts on bevm and on Ethereum.
+// An invocation can opt in to the unified address mode by prefixing its data
+// with UnifiedTag:
+//
+//	"evmu" || "evmc" <to> <data>
+//
+// Its sender is then the Ethereum address keccak(pubkey)[12:] of the key
+// guarding the spent EVM script, which must be a single key policy
+// `<pubkey> OP_CHECKSIG`. Unified invocations in other carriers are ignored.
+
+// UnifiedTag prefixes EVM data opting in to the unified address mode.
+const UnifiedTag = "evmu"
+
+var errNotUnifiedPolicy = errors.New("unified evm invocation requires a single key policy")
+
+// EVMUnified wraps an EVM call or deploy whose sender is the Ethereum address
+// of the key in the spent EVM script.
+type EVMUnified struct {
+	EVMInvokeData
+}
+
+func (self *EVMUnified) ToWitness() [][]byte {
+	encoded := BytesConcat([]byte(UnifiedTag), BytesConcat(self.EVMInvokeData.ToWitness()...))
+	return EncodeToWitness(encoded)
+}
+
+// UnifiedEVMAddress returns the EVM account of unified invocations signed by
+// pub, which is its Ethereum address.
+func UnifiedEVMAddress(pub *btcec.PublicKey) common.Address {
+	return crypto.PubkeyToAddress(*pub.ToECDSA())
+}
+
+func decodeUnified(witness []byte) (EVMInvokeData, error) {
+	inner, err := DecodeEVMWitness(witness)
+	if err != nil {
+		return nil, err
+	}
+	switch inner.(type) {
+	case *EVMCall, *EVMDeploy:
+		return &EVMUnified{inner}, nil
+	}
+	return nil, errors.New("unified evm invocation must be a call or deploy")
+}
+
+// unifiedSender returns the Ethereum address of the key guarding an EVM script.
+func unifiedSender(script []byte) (common.Address, error) {
+	_, policy := SplitEVMScript(script)
+	if len(policy) != 2+btcec.PubKeyBytesLenCompressed || policy[0] != txscript.OP_DATA_33 ||
+		policy[len(policy)-1] != txscript.OP_CHECKSIG {
+		return common.Address{}, errNotUnifiedPolicy
+	}
+	pub, err := btcec.ParsePubKey(policy[1 : len(policy)-1])
+	if err != nil {
+		return common.Address{}, err
+	}
+	return UnifiedEVMAddress(pub), nil
+}
+
+// unwrapUnified returns the call or deploy wrapped by a unified invocation.
+func unwrapUnified(data EVMInvokeData) EVMInvokeData {
+	if unified, ok := data.(*EVMUnified); ok {
+		return unified.EVMInvokeData
+	}
+	return data
+}
@@ -0,0 +1,109 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// spendUnified spends an output locked to script with the witness built by sign
+// and returns the invocations extracted from it.
+func spendUnified(t *testing.T, script []byte,
+	sign func(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes) (wire.TxWitness, error)) []EVMInvokeData {
+	payScript, err := PayToAddrScript(NewAddressEVMFromScript(script, &chaincfg.TestNet3Params))
+	assert.Nil(t, err)
+
+	tx := wire.NewMsgTx(2)
+	prevHash := chainhash.Hash{0xe0}
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevHash, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(8000, payScript))
+
+	fetcher := blockchain.NewUtxoViewpoint()
+	fetcher.Entries()[tx.TxIn[0].PreviousOutPoint] = blockchain.NewUtxoEntry(&wire.TxOut{Value: 9208, PkScript: payScript}, 0, false)
+	witness, err := sign(tx, txscript.NewTxSigHashes(tx, fetcher))
+	assert.Nil(t, err)
+	tx.TxIn[0].Witness = witness
+
+	err = blockchain.ValidateTransactionScripts(btcutil.NewTx(tx), fetcher,
+		txscript.StandardVerifyFlags, txscript.NewSigCache(10), txscript.NewHashCache(10))
+	assert.Nil(t, err)
+
+	return ExtractEVMWitness(tx, fetcher)
+}
+
+func TestUnifiedSender(t *testing.T) {
+	call := &EVMCall{To: common.HexToAddress("0x1234"), Data: []byte("hello unified")}
+	script := NewEVMScriptFromPubKey(privateKey.PubKey())
+	data := spendUnified(t, script, func(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes) (wire.TxWitness, error) {
+		return EVMWitnessSign(tx, sigHashes, 0, 9208, privateKey, &EVMUnified{call})
+	})
+	assert.Equal(t, 1, len(data))
+	got, ok := data[0].(*EVMCall)
+	assert.True(t, ok)
+	assert.Equal(t, crypto.PubkeyToAddress(privateKey.ToECDSA().PublicKey), got.From)
+	assert.Equal(t, call.To, got.To)
+	assert.True(t, bytes.Equal(call.Data, got.Data))
+
+	// The same key still owns its legacy account through the same script.
+	data = spendUnified(t, script, func(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes) (wire.TxWitness, error) {
+		return EVMWitnessSign(tx, sigHashes, 0, 9208, privateKey, call)
+	})
+	assert.Equal(t, 1, len(data))
+	assert.Equal(t, evmAddress.EvmAddress(), data[0].(*EVMCall).From)
+}
+
+func TestUnifiedDeploy(t *testing.T) {
+	deploy := &EVMDeploy{Data: []byte{0x60, 0x00, 0x60, 0x00, 0xf3}}
+	script := NewEVMScriptFromPubKey(privateKey.PubKey(), true)
+	data := spendUnified(t, script, func(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes) (wire.TxWitness, error) {
+		return EVMWitnessSign(tx, sigHashes, 0, 9208, privateKey, &EVMUnified{deploy})
+	})
+	assert.Equal(t, 1, len(data))
+	assert.Equal(t, UnifiedEVMAddress(privateKey.PubKey()), data[0].(*EVMDeploy).From)
+}
+
+func TestUnifiedRequiresSingleKeyPolicy(t *testing.T) {
+	var (
+		keys []*btcec.PrivateKey
+		pubs []*btcec.PublicKey
+	)
+	for i := 0; i < 2; i++ {
+		key, err := btcec.NewPrivateKey()
+		assert.Nil(t, err)
+		keys = append(keys, key)
+		pubs = append(pubs, key.PubKey())
+	}
+	policy, err := NewMultiSigPolicy(1, pubs)
+	assert.Nil(t, err)
+	script, err := NewEVMScript(policy)
+	assert.Nil(t, err)
+
+	call := &EVMUnified{&EVMCall{To: common.HexToAddress("0x1234")}}
+	data := spendUnified(t, script, func(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes) (wire.TxWitness, error) {
+		return EVMWitnessMultiSignature(tx, sigHashes, 0, 9208, script, txscript.SigHashAll, keys[1:], call)
+	})
+	assert.Equal(t, 0, len(data))
+}
+
+func TestDecodeUnified(t *testing.T) {
+	call := &EVMCall{To: common.HexToAddress("0x1234"), Data: []byte{0x01}}
+	data, err := DecodeEVMWitness(BytesConcat((&EVMUnified{call}).ToWitness()...))
+	assert.Nil(t, err)
+	assert.Equal(t, &EVMUnified{call}, data)
+
+	// Relayed transactions are signed by an Ethereum key already.
+	_, err = DecodeEVMWitness(BytesConcat((&EVMUnified{&EVMRelay{Tx: []byte{0x01}}}).ToWitness()...))
+	assert.NotNil(t, err)
+	_, err = DecodeEVMWitness(BytesConcat((&EVMUnified{&EVMUnified{call}}).ToWitness()...))
+	assert.NotNil(t, err)
+}
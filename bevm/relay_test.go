@@ -0,0 +1,120 @@
+package bevm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/bevm/protocol"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func newRelayTxs(t *testing.T, tx *types.Transaction) (*wire.MsgTx, *wire.MsgTx) {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newInvokeTxs(t, &protocol.EVMRelay{Tx: raw})
+}
+
+func TestRelayedTransactions(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	to := common.HexToAddress("0xdead")
+	signer := types.LatestSignerForChainID(big.NewInt(1337))
+
+	first := types.MustSignNewTx(key, signer, &types.DynamicFeeTx{ChainID: big.NewInt(1337), Nonce: 0, To: &to, Gas: 50000, Data: []byte{0x01}})
+	second := types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 1, To: &to, Gas: 50000})
+	foreign := types.MustSignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.LegacyTx{Nonce: 2, To: &to, Gas: 50000})
+	unprotected := types.MustSignNewTx(key, types.HomesteadSigner{}, &types.LegacyTx{Nonce: 2, To: &to, Gas: 50000})
+
+	var funding, spends []*wire.MsgTx
+	for _, tx := range []*types.Transaction{first, second, first, foreign, unprotected} {
+		f, s := newRelayTxs(t, tx)
+		funding, spends = append(funding, f), append(spends, s)
+	}
+	genesis := newBtcBlock(nil, 0)
+	block1 := newBtcBlock(genesis, 1, spends...)
+	client := NewFixtureClient(0, []*wire.MsgBlock{genesis, block1}, funding)
+
+	chain, db := newVerifyTestChain(t, genesis)
+	defer chain.Stop()
+	bt := NewBlockTranslatorWithClient(client)
+	bt.SetChainID(chain.Config().ChainID)
+	miner := &Miner{eth: NewMockBackend(chain, db), bt: bt}
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to sync fixture blocks: %v", err)
+	}
+	block := chain.GetBlockByNumber(1)
+	// the transactions signed for another chain or without chain id are dropped
+	if txs := block.Transactions(); len(txs) != 3 {
+		t.Fatalf("relayed tx count mismatch: have %d, want 3", len(txs))
+	}
+	receipts := chain.GetReceiptsByHash(block.Hash())
+	wantStatus := []uint64{types.ReceiptStatusSuccessful, types.ReceiptStatusSuccessful, types.ReceiptStatusFailed}
+	for i, receipt := range receipts {
+		if receipt.Status != wantStatus[i] {
+			t.Errorf("receipt %d status mismatch: have %d, want %d", i, receipt.Status, wantStatus[i])
+		}
+		if from, _ := types.Sender(signer, block.Transactions()[i]); from != sender {
+			t.Errorf("tx %d sender mismatch: have %x, want %x", i, from, sender)
+		}
+	}
+	if receipts[2].GasUsed != 0 {
+		t.Errorf("replayed transaction used gas: %d", receipts[2].GasUsed)
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce := statedb.GetNonce(sender); nonce != 2 {
+		t.Fatalf("sender nonce mismatch: have %d, want 2", nonce)
+	}
+}
+
+func TestRelayRejectReason(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	to := common.HexToAddress("0xdead")
+	foreign := types.MustSignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.LegacyTx{To: &to, Gas: 50000})
+	unprotected := types.MustSignNewTx(key, types.HomesteadSigner{}, &types.LegacyTx{To: &to, Gas: 50000})
+	encode := func(tx *types.Transaction) []byte {
+		raw, _ := tx.MarshalBinary()
+		return raw
+	}
+	bt := NewBlockTranslatorWithClient(nil)
+	bt.SetChainID(big.NewInt(1337))
+
+	tests := []struct {
+		translator *BlockTranslator
+		raw        []byte
+		reason     string
+	}{
+		{NewBlockTranslatorWithClient(nil), encode(foreign), "disabled"},
+		{bt, []byte{0x01, 0x02}, "invalid"},
+		{bt, encode(unprotected), "unprotected"},
+		{bt, encode(foreign), "signature"},
+	}
+	for i, tt := range tests {
+		_, err := tt.translator.witnessToBevmTx(&protocol.EVMRelay{Tx: tt.raw}, common.Hash{}, 0)
+		if err == nil {
+			t.Fatalf("test %d: relayed transaction accepted", i)
+		}
+		if reason := rejectReason(err); reason != tt.reason {
+			t.Errorf("test %d: reason mismatch: have %s, want %s (%v)", i, reason, tt.reason, err)
+		}
+	}
+}
+
+type unknownInvocation struct{ protocol.EVMCall }
+
+func TestUnknownInvocationRejectReason(t *testing.T) {
+	_, err := NewBlockTranslatorWithClient(nil).witnessToBevmTx(&unknownInvocation{}, common.Hash{}, 0)
+	if err == nil {
+		t.Fatal("unknown invocation accepted")
+	}
+	if reason := rejectReason(err); reason != "unknown" {
+		t.Errorf("reason mismatch: have %s, want unknown (%v)", reason, err)
+	}
+}
@@ -0,0 +1,399 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// snapshotVersion is the version of the state snapshot export format.
+const snapshotVersion = 1
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCodeHash is the known hash of the empty EVM bytecode.
+	emptyCodeHash = crypto.Keccak256Hash(nil)
+)
+
+// A state snapshot export is an RLP stream of a snapshotMeta, the header chain
+// from the genesis up to the exported block as snapshotHeader entries, the
+// nodes of its message MMR and finally the flat state as snapshotAccount
+// entries ordered by account hash, up to the end of the stream.
+
+type snapshotMeta struct {
+	Version  uint64
+	Head     *types.Block // block whose state is exported
+	MmrNodes uint64       // number of message MMR nodes after the headers
+}
+
+type snapshotHeader struct {
+	Header    *types.Header
+	BtcHeader []byte // raw BTC header the block is translated from, empty for the genesis
+}
+
+type snapshotAccount struct {
+	Hash    common.Hash
+	Account []byte // slim RLP account, as stored by core/state/snapshot
+	Code    []byte
+	Storage []snapshotSlot
+}
+
+type snapshotSlot struct {
+	Hash  common.Hash
+	Value []byte
+}
+
+// ExportSnapshot writes the flat state of the canonical block with the given
+// number to w, together with its header chain, the BTC headers it is anchored
+// to and its message MMR. The state is read from the state snapshot when it
+// covers the block and from the tries otherwise.
+func ExportSnapshot(chain *core.BlockChain, db ethdb.Database, number uint64, w io.Writer) error {
+	block := chain.GetBlockByNumber(number)
+	if block == nil {
+		return fmt.Errorf("block %d not found", number)
+	}
+	if !chain.HasState(block.Root()) {
+		return fmt.Errorf("missing state of block %d", number)
+	}
+	meta := &snapshotMeta{Version: snapshotVersion, Head: block, MmrNodes: mmrNodeCount(block.Nonce())}
+	if err := rlp.Encode(w, meta); err != nil {
+		return err
+	}
+	for n := uint64(0); n <= number; n++ {
+		header := chain.GetHeaderByNumber(n)
+		if header == nil {
+			return fmt.Errorf("missing header %d", n)
+		}
+		entry := &snapshotHeader{Header: header}
+		if n > 0 {
+			if entry.BtcHeader = chain.GetBtcHeader(header.UncleHash); entry.BtcHeader == nil {
+				return fmt.Errorf("missing btc header of block %d", n)
+			}
+		}
+		if err := rlp.Encode(w, entry); err != nil {
+			return err
+		}
+	}
+	for pos := uint64(0); pos < meta.MmrNodes; pos++ {
+		node := rawdb.ReadMmrNode(db, pos)
+		if node == (common.Hash{}) {
+			return fmt.Errorf("missing mmr node %d", pos)
+		}
+		if err := rlp.Encode(w, node); err != nil {
+			return err
+		}
+	}
+	var (
+		start    = time.Now()
+		accounts int
+		err      error
+	)
+	if snaps := chain.Snapshots(); snaps != nil && snaps.Snapshot(block.Root()) != nil {
+		accounts, err = exportSnapshotState(chain, snaps, block.Root(), w)
+	} else {
+		err = snapshot.ErrNotCoveredYet
+	}
+	if errors.Is(err, snapshot.ErrNotCoveredYet) || errors.Is(err, snapshot.ErrNotConstructed) {
+		log.Info("State snapshot unavailable, exporting from the tries", "number", number, "root", block.Root())
+		accounts, err = exportTrieState(chain, block.Root(), w)
+	}
+	if err != nil {
+		return err
+	}
+	log.Info("Exported state snapshot", "number", number, "root", block.Root(), "accounts", accounts, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// exportSnapshotState writes the accounts of the state with the given root,
+// read from the state snapshot. Nothing is written if the snapshot is not
+// generated yet.
+func exportSnapshotState(chain *core.BlockChain, snaps *snapshot.Tree, root common.Hash, w io.Writer) (int, error) {
+	accIt, err := snaps.AccountIterator(root, common.Hash{})
+	if err != nil {
+		return 0, err
+	}
+	defer accIt.Release()
+
+	var accounts int
+	for accIt.Next() {
+		account, err := snapshot.FullAccount(accIt.Account())
+		if err != nil {
+			return accounts, err
+		}
+		entry := &snapshotAccount{Hash: accIt.Hash(), Account: common.CopyBytes(accIt.Account())}
+		if entry.Code, err = accountCode(chain, account.CodeHash); err != nil {
+			return accounts, err
+		}
+		if common.BytesToHash(account.Root) != emptyRoot {
+			stIt, err := snaps.StorageIterator(root, accIt.Hash(), common.Hash{})
+			if err != nil {
+				return accounts, err
+			}
+			for stIt.Next() {
+				entry.Storage = append(entry.Storage, snapshotSlot{Hash: stIt.Hash(), Value: common.CopyBytes(stIt.Slot())})
+			}
+			err = stIt.Error()
+			stIt.Release()
+			if err != nil {
+				return accounts, err
+			}
+		}
+		if err := rlp.Encode(w, entry); err != nil {
+			return accounts, err
+		}
+		accounts++
+	}
+	return accounts, accIt.Error()
+}
+
+// exportTrieState writes the accounts of the state with the given root, read
+// from the tries.
+func exportTrieState(chain *core.BlockChain, root common.Hash, w io.Writer) (int, error) {
+	triedb := chain.StateCache().TrieDB()
+	tr, err := trie.NewSecure(root, triedb)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		accounts int
+		accIt    = trie.NewIterator(tr.NodeIterator(nil))
+	)
+	for accIt.Next() {
+		var account types.StateAccount
+		if err := rlp.DecodeBytes(accIt.Value, &account); err != nil {
+			return accounts, err
+		}
+		entry := &snapshotAccount{
+			Hash:    common.BytesToHash(accIt.Key),
+			Account: snapshot.SlimAccountRLP(account.Nonce, account.Balance, account.Root, account.CodeHash),
+		}
+		if entry.Code, err = accountCode(chain, account.CodeHash); err != nil {
+			return accounts, err
+		}
+		if account.Root != emptyRoot {
+			storage, err := trie.NewSecure(account.Root, triedb)
+			if err != nil {
+				return accounts, err
+			}
+			stIt := trie.NewIterator(storage.NodeIterator(nil))
+			for stIt.Next() {
+				entry.Storage = append(entry.Storage, snapshotSlot{Hash: common.BytesToHash(stIt.Key), Value: common.CopyBytes(stIt.Value)})
+			}
+			if stIt.Err != nil {
+				return accounts, stIt.Err
+			}
+		}
+		if err := rlp.Encode(w, entry); err != nil {
+			return accounts, err
+		}
+		accounts++
+	}
+	return accounts, accIt.Err
+}
+
+func accountCode(chain *core.BlockChain, codeHash []byte) ([]byte, error) {
+	hash := common.BytesToHash(codeHash)
+	if len(codeHash) == 0 || hash == emptyCodeHash {
+		return nil, nil
+	}
+	code, err := chain.ContractCode(hash)
+	if err != nil {
+		return nil, fmt.Errorf("missing code %x: %v", hash, err)
+	}
+	return code, nil
+}
+
+// ImportSnapshot writes a state snapshot exported by ExportSnapshot into db,
+// which must hold the same genesis and no other block, and makes its block
+// the head. The block must be anchored to the BTC block at the same height of
+// client, its header chain must link up to the genesis through BTC headers
+// linking up as well, and the imported state must hash to its state root.
+// Translation then resumes from the next BTC height. On failure the chain is
+// left at the genesis, but the database may hold stray data.
+func ImportSnapshot(db ethdb.Database, client BtcClient, r io.Reader) (*types.Header, error) {
+	stream := rlp.NewStream(r, 0)
+	var meta snapshotMeta
+	if err := stream.Decode(&meta); err != nil {
+		return nil, fmt.Errorf("invalid snapshot: %v", err)
+	}
+	if meta.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", meta.Version)
+	}
+	head := meta.Head.Header()
+	genesis := rawdb.ReadCanonicalHash(db, 0)
+	if genesis == (common.Hash{}) {
+		return nil, errors.New("database not initialised with a genesis")
+	}
+	if rawdb.ReadHeadHeaderHash(db) != genesis {
+		return nil, errors.New("database already holds blocks beyond the genesis")
+	}
+	if hash := types.DeriveSha(meta.Head.Transactions(), trie.NewStackTrie(nil)); hash != head.TxHash {
+		return nil, fmt.Errorf("transaction root mismatch: have %x, want %x", hash, head.TxHash)
+	}
+	anchor, err := client.GetBlockHash(head.Number.Int64())
+	if err != nil {
+		return nil, fmt.Errorf("get btc block hash %d error: %v", head.Number, err)
+	}
+	if BtcHashToEvmHash(*anchor) != head.UncleHash {
+		return nil, fmt.Errorf("block %d is derived from btc block %x, btc node has %s", head.Number, head.UncleHash, anchor)
+	}
+	log.Info("Importing state snapshot", "number", head.Number, "hash", head.Hash(), "btc", anchor)
+
+	// Store the header chain, canonical only once the state checks out.
+	var (
+		batch  = db.NewBatch()
+		hashes = make([]common.Hash, 0, head.Number.Uint64()+1)
+		parent *types.Header
+		td     *big.Int
+	)
+	for n := uint64(0); n <= head.Number.Uint64(); n++ {
+		var entry snapshotHeader
+		if err := stream.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("invalid header %d: %v", n, err)
+		}
+		header := entry.Header
+		if header.Number.Uint64() != n {
+			return nil, fmt.Errorf("header %d has number %d", n, header.Number)
+		}
+		if n == 0 {
+			if header.Hash() != genesis {
+				return nil, fmt.Errorf("genesis mismatch: have %x, want %x", header.Hash(), genesis)
+			}
+			parent, td = header, rawdb.ReadTd(db, genesis, 0)
+			hashes = append(hashes, genesis)
+			continue
+		}
+		if header.ParentHash != parent.Hash() {
+			return nil, fmt.Errorf("header %d does not extend %x", n, parent.Hash())
+		}
+		var bheader wire.BlockHeader
+		if err := bheader.Deserialize(bytes.NewReader(entry.BtcHeader)); err != nil {
+			return nil, fmt.Errorf("invalid btc header of block %d: %v", n, err)
+		}
+		if BtcHashToEvmHash(bheader.BlockHash()) != header.UncleHash || BtcHashToEvmHash(bheader.PrevBlock) != parent.UncleHash {
+			return nil, fmt.Errorf("btc header of block %d does not match its anchor", n)
+		}
+		td = new(big.Int).Add(td, header.Difficulty)
+		rawdb.WriteHeader(batch, header)
+		rawdb.WriteTd(batch, header.Hash(), n, td)
+		rawdb.WriteBtcHeader(batch, header.UncleHash, entry.BtcHeader)
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return nil, err
+			}
+			batch.Reset()
+		}
+		hashes = append(hashes, header.Hash())
+		parent = header
+	}
+	if parent.Hash() != head.Hash() {
+		return nil, fmt.Errorf("header chain ends at %x, snapshot block is %x", parent.Hash(), head.Hash())
+	}
+	// Store the message MMR of the block.
+	size := head.Nonce.Uint64()
+	if meta.MmrNodes != mmrNodeCount(size) {
+		return nil, fmt.Errorf("%d mmr nodes for %d messages", meta.MmrNodes, size)
+	}
+	for pos := uint64(0); pos < meta.MmrNodes; pos++ {
+		var node common.Hash
+		if err := stream.Decode(&node); err != nil {
+			return nil, fmt.Errorf("invalid mmr node %d: %v", pos, err)
+		}
+		rawdb.WriteMmrNode(batch, pos, node)
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	batch.Reset()
+	if size > 0 {
+		peaks, err := (&messageTree{db: db}).peaks(size)
+		if err != nil {
+			return nil, err
+		}
+		if root := mmrBagPeaks(peaks); root != head.MixDigest {
+			return nil, fmt.Errorf("mmr root mismatch: have %x, want %x", root, head.MixDigest)
+		}
+	}
+	// Store the flat state and regenerate the tries from it.
+	var accounts int
+	for {
+		var entry snapshotAccount
+		if err := stream.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid account %d: %v", accounts, err)
+		}
+		account, err := snapshot.FullAccount(entry.Account)
+		if err != nil {
+			return nil, fmt.Errorf("invalid account %x: %v", entry.Hash, err)
+		}
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != emptyCodeHash {
+			if hash := crypto.Keccak256Hash(entry.Code); hash != codeHash {
+				return nil, fmt.Errorf("code of account %x has hash %x, want %x", entry.Hash, hash, codeHash)
+			}
+			rawdb.WriteCode(batch, codeHash, entry.Code)
+		}
+		rawdb.WriteAccountSnapshot(batch, entry.Hash, entry.Account)
+		for _, slot := range entry.Storage {
+			rawdb.WriteStorageSnapshot(batch, entry.Hash, slot.Hash, slot.Value)
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return nil, err
+			}
+			batch.Reset()
+		}
+		accounts++
+	}
+	snapshot.MarkGenerated(batch, head.Root)
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	batch.Reset()
+	snaptree, err := snapshot.New(db, trie.NewDatabase(db), 256, head.Root, false, false, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshot.GenerateTrie(snaptree, head.Root, db, db); err != nil {
+		return nil, err
+	}
+	// Everything checks out, switch the chain over to the block.
+	for n, hash := range hashes {
+		rawdb.WriteCanonicalHash(batch, hash, uint64(n))
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return nil, err
+			}
+			batch.Reset()
+		}
+	}
+	rawdb.WriteBlock(batch, meta.Head)
+	rawdb.WriteTxLookupEntriesByBlock(batch, meta.Head)
+	rawdb.WriteHeadHeaderHash(batch, head.Hash())
+	rawdb.WriteHeadBlockHash(batch, head.Hash())
+	rawdb.WriteHeadFastBlockHash(batch, head.Hash())
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	log.Info("Imported state snapshot", "number", head.Number, "hash", head.Hash(), "accounts", accounts)
+	return head, nil
+}
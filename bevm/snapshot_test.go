@@ -0,0 +1,162 @@
+package bevm
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/layer2"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// newSnapshotTestDb returns a database holding only the genesis of
+// newVerifyTestChain.
+func newSnapshotTestDb(t *testing.T, genesis *wire.MsgBlock) ethdb.Database {
+	chain, db := newVerifyTestChain(t, genesis)
+	chain.Stop()
+	return db
+}
+
+// forgeSnapshot re-encodes an exported snapshot after letting forge modify the
+// storage of its accounts.
+func forgeSnapshot(t *testing.T, export []byte, forge func(*snapshotAccount)) []byte {
+	stream := rlp.NewStream(bytes.NewReader(export), 0)
+	var (
+		out  bytes.Buffer
+		meta snapshotMeta
+	)
+	if err := stream.Decode(&meta); err != nil {
+		t.Fatal(err)
+	}
+	rlp.Encode(&out, &meta)
+	for n := uint64(0); n <= meta.Head.NumberU64(); n++ {
+		var header snapshotHeader
+		if err := stream.Decode(&header); err != nil {
+			t.Fatal(err)
+		}
+		rlp.Encode(&out, &header)
+	}
+	for pos := uint64(0); pos < meta.MmrNodes; pos++ {
+		var node common.Hash
+		if err := stream.Decode(&node); err != nil {
+			t.Fatal(err)
+		}
+		rlp.Encode(&out, node)
+	}
+	for {
+		var account snapshotAccount
+		if err := stream.Decode(&account); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		forge(&account)
+		rlp.Encode(&out, &account)
+	}
+	return out.Bytes()
+}
+
+func TestSnapshotExportImport(t *testing.T) {
+	funding1, spend1 := newDeployTxs(t, []byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x00}) // sstore(0, 1)
+	funding3, spend3 := newDeployTxs(t, common.FromHex("0x600160005260206000602060006101005afa503d60005560014060015500"))
+	genesis := newBtcBlock(nil, 0)
+	block1 := newBtcBlock(genesis, 1, spend1)
+	block2 := newBtcBlock(block1, 2)
+	block3 := newBtcBlock(block2, 3, spend3)
+	block4 := newBtcBlock(block3, 4)
+	client := NewFixtureClient(0, []*wire.MsgBlock{genesis, block1, block2, block3}, []*wire.MsgTx{funding1, funding3})
+
+	source, sourceDb := newVerifyTestChain(t, genesis)
+	defer source.Stop()
+	miner := &Miner{eth: NewMockBackend(source, sourceDb), bt: NewBlockTranslatorWithClient(client)}
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to sync fixture blocks: %v", err)
+	}
+	var export bytes.Buffer
+	if err := ExportSnapshot(source, sourceDb, 3, &export); err != nil {
+		t.Fatalf("failed to export snapshot: %v", err)
+	}
+	// The state snapshot and the tries export the same accounts.
+	root := source.CurrentBlock().Root()
+	var fromSnap, fromTrie bytes.Buffer
+	if n, err := exportSnapshotState(source, source.Snapshots(), root, &fromSnap); err != nil || n == 0 {
+		t.Fatalf("failed to export from snapshot: %d, %v", n, err)
+	}
+	if n, err := exportTrieState(source, root, &fromTrie); err != nil || n == 0 {
+		t.Fatalf("failed to export from tries: %d, %v", n, err)
+	}
+	if !bytes.Equal(fromSnap.Bytes(), fromTrie.Bytes()) {
+		t.Fatal("snapshot and trie exports differ")
+	}
+
+	// A snapshot anchored to another BTC block or with forged state is refused.
+	fork3 := newBtcBlock(block2, 3)
+	fork3.Header.Nonce = 100
+	fork := NewFixtureClient(0, []*wire.MsgBlock{genesis, block1, block2, fork3}, nil)
+	if _, err := ImportSnapshot(newSnapshotTestDb(t, genesis), fork, bytes.NewReader(export.Bytes())); err == nil {
+		t.Fatal("imported snapshot anchored to unknown btc block")
+	}
+	forged := forgeSnapshot(t, export.Bytes(), func(account *snapshotAccount) {
+		for i := range account.Storage {
+			account.Storage[i].Value = []byte{0x02}
+		}
+	})
+	forgedDb := newSnapshotTestDb(t, genesis)
+	if _, err := ImportSnapshot(forgedDb, client, bytes.NewReader(forged)); err == nil {
+		t.Fatal("imported forged state")
+	}
+	if _, err := ImportSnapshot(forgedDb, client, bytes.NewReader(export.Bytes())); err != nil {
+		t.Fatalf("failed to import after refused snapshot: %v", err)
+	}
+
+	db := newSnapshotTestDb(t, genesis)
+	head, err := ImportSnapshot(db, client, bytes.NewReader(export.Bytes()))
+	if err != nil {
+		t.Fatalf("failed to import snapshot: %v", err)
+	}
+	if head.Hash() != source.CurrentHeader().Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head.Hash(), source.CurrentHeader().Hash())
+	}
+	if _, err := ImportSnapshot(db, client, bytes.NewReader(export.Bytes())); err == nil {
+		t.Fatal("imported snapshot over existing blocks")
+	}
+
+	// The imported node serves the state and resumes translating from BTC.
+	chain, err := core.NewBlockChain(db, nil, source.Config(), layer2.New(&params.Layer2InstantConfig{}), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to open imported chain: %v", err)
+	}
+	defer chain.Stop()
+	if chain.CurrentBlock().Hash() != head.Hash() {
+		t.Fatalf("chain head mismatch: have %x, want %x", chain.CurrentBlock().Hash(), head.Hash())
+	}
+	want, _ := source.State()
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("missing imported state: %v", err)
+	}
+	if have, want := statedb.Dump(nil), want.Dump(nil); !bytes.Equal(have, want) {
+		t.Fatalf("state mismatch:\nhave %s\nwant %s", have, want)
+	}
+	if chain.GetBtcHeader(chain.GetHeaderByNumber(2).UncleHash) == nil {
+		t.Fatal("missing btc header of ancestor")
+	}
+	client.addBlock(4, block4)
+	miner = &Miner{eth: NewMockBackend(chain, db), bt: NewBlockTranslatorWithClient(client)}
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to translate after import: %v", err)
+	}
+	miner = &Miner{eth: NewMockBackend(source, sourceDb), bt: NewBlockTranslatorWithClient(client)}
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to translate source block: %v", err)
+	}
+	if chain.CurrentBlock().Hash() != source.CurrentBlock().Hash() {
+		t.Fatalf("block 4 mismatch: have %x, want %x", chain.CurrentBlock().Hash(), source.CurrentBlock().Hash())
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	// DefaultHealthMaxLag is the largest number of untranslated BTC blocks the
+	// health endpoint still reports as healthy.
+	DefaultHealthMaxLag = 2
+
+	// healthStaleTime is how long the BTC tip may go without a refresh before
+	// the node is reported unhealthy.
+	healthStaleTime = 5 * time.Minute
+)
+
+// SyncStatus is the progress of translating the BTC chain.
+type SyncStatus struct {
+	StartingBlock hexutil.Uint64 `json:"startingBlock"` // head when the current catch up began
+	CurrentBlock  hexutil.Uint64 `json:"currentBlock"`  // number of the EVM head
+	BtcHeight     hexutil.Uint64 `json:"btcHeight"`     // height of the last translated BTC block
+	BtcTip        hexutil.Uint64 `json:"btcTip"`        // height of the best block of the BTC node
+	Lag           hexutil.Uint64 `json:"lag"`           // number of BTC blocks not translated yet
+	Updated       time.Time      `json:"updated"`       // time the status was last refreshed
+	Error         string         `json:"error,omitempty"`
+}
+
+// syncTracker records the translation progress reported by Miner.loop. A nil
+// tracker ignores updates.
+type syncTracker struct {
+	lock   sync.RWMutex
+	status SyncStatus
+}
+
+func newSyncTracker(head uint64) *syncTracker {
+	t := new(syncTracker)
+	t.status.StartingBlock = hexutil.Uint64(head)
+	t.status.CurrentBlock = hexutil.Uint64(head)
+	t.status.BtcHeight = hexutil.Uint64(head)
+	t.status.BtcTip = hexutil.Uint64(head)
+	return t
+}
+
+// setTip records the best BTC height polled from the node while the head is at
+// the given height, starting a new catch up if the node moved ahead of it.
+func (t *syncTracker) setTip(tip, head uint64) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.status.BtcTip <= t.status.CurrentBlock && tip > head {
+		t.status.StartingBlock = hexutil.Uint64(head)
+	}
+	t.status.BtcTip = hexutil.Uint64(tip)
+	t.status.Error = ""
+	t.update(head)
+}
+
+// setHead records that the BTC block at the given height was translated into
+// the new head.
+func (t *syncTracker) setHead(height uint64) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if height > uint64(t.status.BtcTip) {
+		t.status.BtcTip = hexutil.Uint64(height)
+	}
+	t.update(height)
+}
+
+// fail records an error polling the BTC node.
+func (t *syncTracker) fail(err error) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.status.Error = err.Error()
+}
+
+func (t *syncTracker) update(height uint64) {
+	t.status.CurrentBlock = hexutil.Uint64(height)
+	t.status.BtcHeight = hexutil.Uint64(height)
+	t.status.Lag = 0
+	if t.status.BtcTip > t.status.BtcHeight {
+		t.status.Lag = t.status.BtcTip - t.status.BtcHeight
+	}
+	t.status.Updated = time.Now()
+
+	syncTipGauge.Update(int64(t.status.BtcTip))
+	syncHeightGauge.Update(int64(t.status.BtcHeight))
+	syncLagGauge.Update(int64(t.status.Lag))
+}
+
+// Status returns the current translation progress.
+func (t *syncTracker) Status() SyncStatus {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.status
+}
+
+// Progress returns the translation progress in the form of eth_syncing.
+func (t *syncTracker) Progress() ethereum.SyncProgress {
+	status := t.Status()
+	return ethereum.SyncProgress{
+		StartingBlock: uint64(status.StartingBlock),
+		CurrentBlock:  uint64(status.CurrentBlock),
+		HighestBlock:  uint64(status.BtcTip),
+	}
+}
+
+// Healthy reports whether the node follows the BTC chain closely: the tip was
+// refreshed lately without error and at most maxLag blocks are untranslated.
+func (t *syncTracker) Healthy(maxLag uint64) bool {
+	status := t.Status()
+	return status.Error == "" && !status.Updated.IsZero() &&
+		time.Since(status.Updated) < healthStaleTime && uint64(status.Lag) <= maxLag
+}
+
+// healthHandler serves the sync status over HTTP, with a non-200 status code
+// if the node is not healthy.
+type healthHandler struct {
+	tracker *syncTracker
+	maxLag  uint64
+}
+
+// NewHealthHandler creates the HTTP health endpoint of the translation progress
+// tracked by miner.
+func NewHealthHandler(miner *Miner, maxLag uint64) http.Handler {
+	return &healthHandler{tracker: miner.sync, maxLag: maxLag}
+}
+
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	res := struct {
+		Healthy bool `json:"healthy"`
+		SyncStatus
+	}{h.tracker.Healthy(h.maxLag), h.tracker.Status()}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !res.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,96 @@
+package bevm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestSyncTracker(t *testing.T) {
+	blocks := newBtcChain(6)
+	client := NewFixtureClient(0, blocks[:4], nil)
+	chain, db := newVerifyTestChain(t, blocks[0])
+	defer chain.Stop()
+	miner := &Miner{
+		eth:  NewMockBackend(chain, db),
+		bt:   NewBlockTranslatorWithClient(client),
+		sync: newSyncTracker(0),
+	}
+	health := NewHealthHandler(miner, 1)
+	checkHealth := func(code int) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		health.ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+		if rec.Code != code {
+			t.Fatalf("health status mismatch: have %d, want %d", rec.Code, code)
+		}
+		var res struct{ Healthy bool }
+		if err := json.NewDecoder(rec.Body).Decode(&res); err != nil || res.Healthy != (code == http.StatusOK) {
+			t.Fatalf("health body mismatch: %v, %v", res, err)
+		}
+	}
+	// Nothing was polled yet.
+	checkHealth(http.StatusServiceUnavailable)
+
+	miner.sync.setTip(3, 0)
+	if progress := miner.sync.Progress(); progress.StartingBlock != 0 || progress.CurrentBlock != 0 || progress.HighestBlock != 3 {
+		t.Fatalf("progress mismatch: %+v", progress)
+	}
+	checkHealth(http.StatusServiceUnavailable)
+
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	status := miner.sync.Status()
+	if status.CurrentBlock != 3 || status.BtcHeight != 3 || status.BtcTip != 3 || status.Lag != 0 {
+		t.Fatalf("status mismatch: %+v", status)
+	}
+	checkHealth(http.StatusOK)
+
+	// A new catch up starts from the current head.
+	client.addBlock(4, blocks[4])
+	client.addBlock(5, blocks[5])
+	miner.sync.setTip(5, 3)
+	if progress := miner.sync.Progress(); progress.StartingBlock != 3 || progress.HighestBlock != 5 {
+		t.Fatalf("progress mismatch: %+v", progress)
+	}
+	checkHealth(http.StatusServiceUnavailable)
+
+	miner.sync.fail(errors.New("connection refused"))
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	checkHealth(http.StatusOK)
+}
+
+func TestSyncTrackerRecordsLoopErrors(t *testing.T) {
+	// The invocation of block 1 spends an output the BTC node does not know.
+	_, spend := newDeployTxs(t, []byte{0x00})
+	genesis := newBtcBlock(nil, 0)
+	client := NewFixtureClient(0, []*wire.MsgBlock{genesis, newBtcBlock(genesis, 1, spend)}, nil)
+	chain, db := newVerifyTestChain(t, genesis)
+	defer chain.Stop()
+	miner := &Miner{
+		eth:  NewMockBackend(chain, db),
+		bt:   NewBlockTranslatorWithClient(client),
+		sync: newSyncTracker(0),
+	}
+	go miner.Start()
+	defer miner.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for miner.sync.Status().Error == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("failed block fetch not recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if miner.sync.Healthy(DefaultHealthMaxLag) {
+		t.Fatalf("miner failing to fetch blocks reported healthy: %+v", miner.sync.Status())
+	}
+}
@@ -3,46 +3,84 @@ package bevm
 import (
 	"fmt"
 	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
-	"github.com/btcsuite/btcd/rpcclient"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/ethereum/go-ethereum/bevm/protocol"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/layer2"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/trie"
 	"math/big"
-	"os"
+	"time"
 )
 
 type BtcRpcConfig struct {
-	Host string
-	User string
-	Pass string
+	Host       string // Comma separated endpoints, later ones are fallbacks
+	User       string
+	Pass       string
+	CookieFile string // bitcoind cookie file, overrides User and Pass
+	TLS        bool   // Connect over TLS
+	CACert     string // PEM certificates of the CAs trusted for TLS, system roots if empty
+
+	Network string // Name of the BTC network, empty to use the one recorded in the chain config
+
+	CheckpointKey      string // File of the hex private key committing checkpoints to BTC, empty to not commit
+	CheckpointAddress  string // BTC address whose checkpoints are verified, defaults to that of CheckpointKey
+	CheckpointInterval uint64 // Number of blocks between checkpoints, 0 for DefaultCheckpointInterval
+	CheckpointFeeRate  int64  // Fee rate of checkpoints in sat/vB, 0 to use the estimate of the BTC node
+
+	HealthMaxLag uint64 // Largest number of untranslated BTC blocks the /health endpoint reports as healthy
+
+	VerifyInsert bool // Re-execute translated blocks through InsertChain before accepting them
+	Prefetch     int  // Number of BTC blocks downloaded ahead of execution during catch up, 0 to disable
+
+	PeerSync bool // Import the blocks served by bevm/1 peers before translating from BTC
+
+	Client BtcClient // BTC node used instead of the Host endpoints, e.g. a FixtureClient
+}
+
+// BtcClient is the subset of the BTC node RPC the translator and miner rely on.
+// It is satisfied by *rpcclient.Client and by FixtureClient.
+type BtcClient interface {
+	GetBlockCount() (int64, error)
+	GetBlockHash(blockHeight int64) (*chainhash.Hash, error)
+	GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error)
+	GetRawTransaction(txHash *chainhash.Hash) (*btcutil.Tx, error)
+	GetBlockChainInfo() (*btcjson.GetBlockChainInfoResult, error)
+	EstimateSmartFee(confTarget int64, mode *btcjson.EstimateSmartFeeMode) (*btcjson.EstimateSmartFeeResult, error)
 }
 
 type BlockTranslator struct {
 	fetcher txscript.PrevOutputFetcher
-	Client  *rpcclient.Client
+	signer  types.Signer          // signer of relayed Ethereum transactions, nil to ignore them
+	engine  *layer2.Layer2Instant // engine deciding the coinbase, nil for none
+	Client  BtcClient
 }
 
-func NewBlockTranslator(conf *BtcRpcConfig) *BlockTranslator {
-	log.Info("btc rpc config:", "conf", conf)
-	connCfg := &rpcclient.ConnConfig{
-		Host:         conf.Host,
-		User:         conf.User,
-		Pass:         conf.Pass,
-		HTTPPostMode: true,
-		DisableTLS:   true,
+// NewBlockTranslator creates a translator reading BTC blocks from the nodes
+// given by conf.
+func NewBlockTranslator(conf *BtcRpcConfig) (*BlockTranslator, error) {
+	if conf.Client != nil {
+		return NewBlockTranslatorWithClient(conf.Client), nil
 	}
-	client, err := rpcclient.New(connCfg, nil)
+	client, err := NewBtcClient(conf)
 	if err != nil {
-		log.Error("Failed to create BTC RPC client", "err", err)
-		os.Exit(-1)
+		return nil, err
 	}
+	log.Info("BTC rpc endpoints", "hosts", conf.Host, "tls", conf.TLS, "cookie", conf.CookieFile != "")
 
+	return NewBlockTranslatorWithClient(client), nil
+}
+
+// NewBlockTranslatorWithClient creates a translator reading BTC blocks from the
+// given client.
+func NewBlockTranslatorWithClient(client BtcClient) *BlockTranslator {
 	return &BlockTranslator{
 		fetcher: nil,
 		Client:  client,
@@ -50,23 +88,79 @@ func NewBlockTranslator(conf *BtcRpcConfig) *BlockTranslator {
 }
 
 func (self *BlockTranslator) PreparePrevOutPoint(bblock *wire.MsgBlock) (txscript.PrevOutputFetcher, error) {
+	fetcher, err := self.FetchPrevOuts(bblock)
+	if err != nil {
+		return nil, err
+	}
+	self.fetcher = fetcher
+
+	return fetcher, nil
+}
+
+// FetchPrevOuts queries the outputs spent by the EVM invocations of bblock. The
+// other outputs spent by the transactions carrying them or checkpoints are
+// queried as well, so that their fees and committers are known, but failing to
+// get those is not an error.
+// It does not touch the translator state and is safe for concurrent use.
+func (self *BlockTranslator) FetchPrevOuts(bblock *wire.MsgBlock) (txscript.PrevOutputFetcher, error) {
 	fetcher := txscript.NewMultiPrevOutFetcher(nil)
 	for _, tx := range bblock.Transactions {
 		points := protocol.PreparePrevOutPoints(tx)
+		if len(points) == 0 && !protocol.HasCheckpoint(tx) {
+			continue
+		}
 		for _, p := range points {
 			preTx, err := self.Client.GetRawTransaction(&p.Hash)
 			if err != nil {
 				return nil, err
 			}
+			if int(p.Index) >= len(preTx.MsgTx().TxOut) {
+				return nil, fmt.Errorf("prev output %v out of range", p)
+			}
 			txout := preTx.MsgTx().TxOut[p.Index]
 			fetcher.AddPrevOut(p, txout)
 		}
+		for _, txin := range tx.TxIn {
+			p := txin.PreviousOutPoint
+			if fetcher.FetchPrevOutput(p) != nil {
+				continue
+			}
+			preTx, err := self.Client.GetRawTransaction(&p.Hash)
+			if err != nil || int(p.Index) >= len(preTx.MsgTx().TxOut) {
+				log.Debug("Failed to get btc fee input", "tx", tx.TxHash(), "prevout", p, "err", err)
+				break
+			}
+			fetcher.AddPrevOut(p, preTx.MsgTx().TxOut[p.Index])
+		}
 	}
 
-	self.fetcher = fetcher
-
 	return fetcher, nil
 }
+
+// FetchBlock retrieves the BTC block at the given height together with the
+// outputs spent by its EVM invocations. It is safe for concurrent use.
+func (self *BlockTranslator) FetchBlock(height int64) (*wire.MsgBlock, txscript.PrevOutputFetcher, error) {
+	start := time.Now()
+	hash, err := self.Client.GetBlockHash(height)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get btc block hash error: %v", err)
+	}
+	bblock, err := self.Client.GetBlock(hash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get btc block error: %v", err)
+	}
+	syncFetchTimer.UpdateSince(start)
+
+	start = time.Now()
+	fetcher, err := self.FetchPrevOuts(bblock)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get btc block prev output point error: %v", err)
+	}
+	syncPrevOutTimer.UpdateSince(start)
+
+	return bblock, fetcher, nil
+}
+
 func BtcHashToEvmHash(hash chainhash.Hash) common.Hash {
 	const hashSize = 32
 	for i := 0; i < hashSize/2; i++ {
@@ -76,7 +170,31 @@ func BtcHashToEvmHash(hash chainhash.Hash) common.Hash {
 	return common.Hash(hash)
 }
 
+// SetChainID sets the chain id relayed Ethereum transactions must be signed for.
+func (self *BlockTranslator) SetChainID(chainID *big.Int) {
+	self.signer = types.LatestSignerForChainID(chainID)
+}
+
+// SetEngine sets the engine of the chain, which decides the coinbase of the
+// translated blocks.
+func (self *BlockTranslator) SetEngine(engine consensus.Engine) {
+	self.engine, _ = engine.(*layer2.Layer2Instant)
+}
+
 func (self *BlockTranslator) ParseBTCBlock(bblock *wire.MsgBlock, height int64, prevHash common.Hash) *types.Block {
+	return self.TranslateBlock(bblock, self.fetcher, height, prevHash)
+}
+
+// TranslateBlock builds the bevm block of bblock, resolving the outputs spent by
+// its EVM invocations through fetcher.
+func (self *BlockTranslator) TranslateBlock(bblock *wire.MsgBlock, fetcher txscript.PrevOutputFetcher, height int64, prevHash common.Hash) *types.Block {
+	block, _ := self.translateBlock(bblock, fetcher, height, prevHash)
+	return block
+}
+
+// translateBlock builds the bevm block of bblock like TranslateBlock, and also
+// returns the events of all its EVM invocations, rejected ones included.
+func (self *BlockTranslator) translateBlock(bblock *wire.MsgBlock, fetcher txscript.PrevOutputFetcher, height int64, prevHash common.Hash) (*types.Block, []*InvocationEvent) {
 	header := &types.Header{
 		Difficulty: blockchain.CalcWork(bblock.Header.Bits),
 		ParentHash: prevHash,
@@ -90,21 +208,51 @@ func (self *BlockTranslator) ParseBTCBlock(bblock *wire.MsgBlock, height int64,
 		Nonce:     types.BlockNonce{},
 		BaseFee:   nil,
 	}
+	if self.engine != nil {
+		header.Coinbase = self.engine.Coinbase(header.Number)
+	}
+
+	defer syncTranslateTimer.UpdateSince(time.Now())
+
+	invocations := self.extractInvocations(bblock, fetcher)
+	translateWitnessCounter.Inc(int64(len(invocations)))
 
 	var txs []*types.Transaction
+	for _, ev := range invocations {
+		if ev.Err != nil {
+			log.Info("ignore evm invocation", "tx", chainhash.Hash(ev.RefHash), "index", ev.Index, "err", ev.Err)
+			markRejected(ev.Err)
+			continue
+		}
+		txs = append(txs, ev.Tx)
+	}
+
+	return types.NewBlock(header, txs, nil, nil, trie.NewStackTrie(nil)), invocations
+}
+
+// extractInvocations returns the events of all the EVM invocations of bblock,
+// rejected ones included, without the block they end up in.
+func (self *BlockTranslator) extractInvocations(bblock *wire.MsgBlock, fetcher txscript.PrevOutputFetcher) []*InvocationEvent {
+	var invocations []*InvocationEvent
 	for _, tx := range bblock.Transactions {
-		witness := protocol.ExtractEVMWitness(tx, self.fetcher)
-		fmt.Println("witness ", witness)
+		witness := protocol.ExtractEVMWitness(tx, fetcher)
+		log.Trace("Extracted evm invocations", "tx", tx.TxHash(), "count", len(witness))
 		for i, w := range witness {
-			btx := witnessToBevmTx(w, common.Hash(tx.TxHash()), uint64(i))
-			txs = append(txs, types.NewTx(btx))
+			ev := &InvocationEvent{RefHash: common.Hash(tx.TxHash()), Index: uint64(i)}
+			invocations = append(invocations, ev)
+
+			btx, err := self.witnessToBevmTx(w, ev.RefHash, ev.Index)
+			if err != nil {
+				ev.Err = err
+				continue
+			}
+			ev.Tx = types.NewTx(btx)
 		}
 	}
-
-	return types.NewBlock(header, txs, nil, nil, trie.NewStackTrie(nil))
+	return invocations
 }
 
-func witnessToBevmTx(witness protocol.EVMInvokeData, txHash common.Hash, index uint64) *types.BevmTx {
+func (self *BlockTranslator) witnessToBevmTx(witness protocol.EVMInvokeData, txHash common.Hash, index uint64) (*types.BevmTx, error) {
 	tx := types.BevmTx{
 		RefHash: txHash,
 		Index:   index,
@@ -119,7 +267,40 @@ func witnessToBevmTx(witness protocol.EVMInvokeData, txHash common.Hash, index u
 		tx.From = data.From
 		tx.To = nil
 		tx.Data = data.Data
+	case *protocol.EVMRelay:
+		return self.relayedBevmTx(data, &tx)
+	default:
+		return nil, fmt.Errorf("%w: %T", errUnknownInvocation, witness)
 	}
 
-	return &tx
+	return &tx, nil
+}
+
+// relayedBevmTx fills tx with the signed Ethereum transaction carried by relay.
+// Only replay protected transactions signed for this chain are accepted.
+func (self *BlockTranslator) relayedBevmTx(relay *protocol.EVMRelay, tx *types.BevmTx) (*types.BevmTx, error) {
+	if self.signer == nil {
+		return nil, errRelayDisabled
+	}
+	var etx types.Transaction
+	if err := etx.UnmarshalBinary(relay.Tx); err != nil {
+		return nil, fmt.Errorf("%w: %v", errRelayInvalid, err)
+	}
+	if !etx.Protected() {
+		return nil, errRelayUnprotected
+	}
+	from, err := types.Sender(self.signer, &etx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errRelaySignature, err)
+	}
+	tx.From = from
+	tx.To = etx.To()
+	tx.Data = etx.Data()
+	tx.Relayed = &types.BevmRelay{
+		Nonce: etx.Nonce(),
+		Gas:   etx.Gas(),
+		Value: etx.Value(),
+		Raw:   relay.Tx,
+	}
+	return tx, nil
 }
@@ -0,0 +1,146 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Divergence describes the first difference found between a stored bevm block
+// and the block re-derived from its BTC counterpart.
+type Divergence struct {
+	Number  uint64 // Number of the diverging block
+	Field   string // Name of the diverging item, e.g. "stateRoot" or "receipt"
+	Index   int    // Transaction index for per transaction items, -1 otherwise
+	Stored  string // Value found in the local database
+	Derived string // Value obtained by re-deriving the block
+}
+
+func (d *Divergence) String() string {
+	item := d.Field
+	if d.Index >= 0 {
+		item = fmt.Sprintf("%s %d", d.Field, d.Index)
+	}
+	return fmt.Sprintf("block %d: %s mismatch, stored %s, derived %s", d.Number, item, d.Stored, d.Derived)
+}
+
+// VerifyRange re-derives the bevm blocks from..to (inclusive) from the BTC
+// blocks served by bt and compares them against the blocks stored in chain.
+// The derived blocks are executed on a scratch state seeded from the stored
+// parent of from, nothing is written to the database. The first divergence
+// is returned, or nil if the whole range matches.
+func VerifyRange(chain *core.BlockChain, bt *BlockTranslator, from, to uint64) (*Divergence, error) {
+	bt.SetChainID(chain.Config().ChainID)
+	bt.SetEngine(chain.Engine())
+	if from == 0 {
+		return nil, fmt.Errorf("genesis block can not be re-derived")
+	}
+	if from > to {
+		return nil, fmt.Errorf("invalid range %d-%d", from, to)
+	}
+	parent := chain.GetHeaderByNumber(from - 1)
+	if parent == nil {
+		return nil, fmt.Errorf("missing parent header %d", from-1)
+	}
+	statedb, err := chain.StateAt(parent.Root)
+	if err != nil {
+		return nil, fmt.Errorf("missing state of block %d: %v", from-1, err)
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for number := from; number <= to; number++ {
+		stored := chain.GetBlockByNumber(number)
+		if stored == nil {
+			return nil, fmt.Errorf("missing block %d", number)
+		}
+		hash, err := bt.Client.GetBlockHash(int64(number))
+		if err != nil {
+			return nil, fmt.Errorf("get btc block hash %d error: %v", number, err)
+		}
+		bblock, err := bt.Client.GetBlock(hash)
+		if err != nil {
+			return nil, fmt.Errorf("get btc block %s error: %v", hash, err)
+		}
+		if btcHash := BtcHashToEvmHash(bblock.BlockHash()); btcHash != stored.UncleHash() {
+			return &Divergence{Number: number, Field: "btcHash", Index: -1, Stored: stored.UncleHash().Hex(), Derived: btcHash.Hex()}, nil
+		}
+		if _, err := bt.PreparePrevOutPoint(bblock); err != nil {
+			return nil, fmt.Errorf("get btc block prev output point error: %v", err)
+		}
+		derived := bt.ParseBTCBlock(bblock, int64(number), stored.ParentHash())
+		if d := diffTransactions(number, stored.Transactions(), derived.Transactions()); d != nil {
+			return d, nil
+		}
+		derived, receipts, _, _, err := executeBlock(chain, statedb, derived)
+		if err != nil {
+			return nil, fmt.Errorf("execute block %d error: %v", number, err)
+		}
+		if d := diffReceipts(number, chain.GetReceiptsByHash(stored.Hash()), receipts); d != nil {
+			return d, nil
+		}
+		if d := diffHeaders(stored.Header(), derived.Header()); d != nil {
+			return d, nil
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying bevm blocks", "number", number, "remaining", to-number, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Verified bevm blocks", "from", from, "to", to, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil, nil
+}
+
+func diffTransactions(number uint64, stored, derived types.Transactions) *Divergence {
+	for i := 0; i < len(stored) && i < len(derived); i++ {
+		if stored[i].Hash() != derived[i].Hash() {
+			return &Divergence{Number: number, Field: "transaction", Index: i, Stored: stored[i].Hash().Hex(), Derived: derived[i].Hash().Hex()}
+		}
+	}
+	if len(stored) != len(derived) {
+		return &Divergence{Number: number, Field: "txCount", Index: -1, Stored: fmt.Sprint(len(stored)), Derived: fmt.Sprint(len(derived))}
+	}
+	return nil
+}
+
+func diffReceipts(number uint64, stored, derived types.Receipts) *Divergence {
+	for i := 0; i < len(stored) && i < len(derived); i++ {
+		want, _ := rlp.EncodeToBytes(stored[i])
+		have, _ := rlp.EncodeToBytes(derived[i])
+		if !bytes.Equal(want, have) {
+			return &Divergence{Number: number, Field: "receipt", Index: i, Stored: describeReceipt(stored[i]), Derived: describeReceipt(derived[i])}
+		}
+	}
+	if len(stored) != len(derived) {
+		return &Divergence{Number: number, Field: "receiptCount", Index: -1, Stored: fmt.Sprint(len(stored)), Derived: fmt.Sprint(len(derived))}
+	}
+	return nil
+}
+
+func describeReceipt(receipt *types.Receipt) string {
+	return fmt.Sprintf("{status: %d, cumulativeGas: %d, logs: %d}", receipt.Status, receipt.CumulativeGasUsed, len(receipt.Logs))
+}
+
+func diffHeaders(stored, derived *types.Header) *Divergence {
+	number := stored.Number.Uint64()
+	switch {
+	case stored.GasUsed != derived.GasUsed:
+		return &Divergence{Number: number, Field: "gasUsed", Index: -1, Stored: fmt.Sprint(stored.GasUsed), Derived: fmt.Sprint(derived.GasUsed)}
+	case stored.ReceiptHash != derived.ReceiptHash:
+		return &Divergence{Number: number, Field: "receiptRoot", Index: -1, Stored: stored.ReceiptHash.Hex(), Derived: derived.ReceiptHash.Hex()}
+	case stored.Root != derived.Root:
+		return &Divergence{Number: number, Field: "stateRoot", Index: -1, Stored: stored.Root.Hex(), Derived: derived.Root.Hex()}
+	case stored.Hash() != derived.Hash():
+		return &Divergence{Number: number, Field: "blockHash", Index: -1, Stored: stored.Hash().Hex(), Derived: derived.Hash().Hex()}
+	}
+	return nil
+}
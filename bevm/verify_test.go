@@ -0,0 +1,204 @@
+package bevm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/bevm/protocol"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/layer2"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func newCoinbaseTx(height int64) *wire.MsgTx {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), []byte{byte(height), 0x51}, nil))
+	tx.AddTxOut(wire.NewTxOut(50e8, []byte{txscript.OP_TRUE}))
+	return tx
+}
+
+func newBtcBlock(prev *wire.MsgBlock, height int64, txs ...*wire.MsgTx) *wire.MsgBlock {
+	var prevHash chainhash.Hash
+	if prev != nil {
+		prevHash = prev.BlockHash()
+	}
+	header := wire.NewBlockHeader(1, &prevHash, &chainhash.Hash{}, 0x207fffff, uint32(height))
+	header.Timestamp = time.Unix(1600000000+height*600, 0)
+	block := wire.NewMsgBlock(header)
+	block.AddTransaction(newCoinbaseTx(height))
+	for _, tx := range txs {
+		block.AddTransaction(tx)
+	}
+	return block
+}
+
+// newDeployTxs returns a funding transaction paying to an EVM deploy script and
+// the transaction spending it with the given init code in its witness.
+func newDeployTxs(t *testing.T, code []byte) (*wire.MsgTx, *wire.MsgTx) {
+	return newInvokeTxs(t, &protocol.EVMDeploy{Data: code})
+}
+
+// newInvokeTxs returns a funding transaction paying to an EVM deploy script and
+// the transaction spending it with evmData in its witness.
+func newInvokeTxs(t *testing.T, evmData protocol.EVMInvokeData) (*wire.MsgTx, *wire.MsgTx) {
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := protocol.NewEVMScript(protocol.NewPubKeyPolicy(key.PubKey()), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scriptHash := chainhash.HashB(script)
+	pkScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(scriptHash).Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	funding := wire.NewMsgTx(wire.TxVersion)
+	funding.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	funding.AddTxOut(wire.NewTxOut(1e5, pkScript))
+
+	witness, err := protocol.BuildEVMWitness([][]byte{make([]byte, 71)}, script, evmData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fundingHash := funding.TxHash()
+	spend := wire.NewMsgTx(wire.TxVersion)
+	spend.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&fundingHash, 0), nil, witness))
+	spend.AddTxOut(wire.NewTxOut(9e4, []byte{txscript.OP_TRUE}))
+	return funding, spend
+}
+
+func newVerifyTestChain(t *testing.T, genesis *wire.MsgBlock) (*core.BlockChain, ethdb.Database) {
+	return newVerifyTestChainWithConfig(t, genesis, newVerifyTestConfig(), layer2.New(&params.Layer2InstantConfig{}))
+}
+
+func newVerifyTestConfig() *params.ChainConfig {
+	return &params.ChainConfig{
+		ChainID:             big.NewInt(1337),
+		HomesteadBlock:      big.NewInt(0),
+		EIP150Block:         big.NewInt(0),
+		EIP155Block:         big.NewInt(0),
+		EIP158Block:         big.NewInt(0),
+		ByzantiumBlock:      big.NewInt(0),
+		ConstantinopleBlock: big.NewInt(0),
+		PetersburgBlock:     big.NewInt(0),
+		IstanbulBlock:       big.NewInt(0),
+		BevmBlock:           big.NewInt(0),
+	}
+}
+
+func newVerifyTestChainWithConfig(t *testing.T, genesis *wire.MsgBlock, config *params.ChainConfig, engine consensus.Engine) (*core.BlockChain, ethdb.Database) {
+	db := rawdb.NewMemoryDatabase()
+	gspec := &core.Genesis{
+		Config:     config,
+		Timestamp:  uint64(genesis.Header.Timestamp.Unix()),
+		GasLimit:   math.MaxUint64,
+		Difficulty: blockchain.CalcWork(genesis.Header.Bits),
+		UncleHash:  BtcHashToEvmHash(genesis.BlockHash()),
+	}
+	gspec.MustCommit(db)
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("can't create new chain %v", err)
+	}
+	return chain, db
+}
+
+func TestVerifyRange(t *testing.T) {
+	funding, spend := newDeployTxs(t, []byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x00}) // sstore(0, 1)
+	genesis := newBtcBlock(nil, 0)
+	block1 := newBtcBlock(genesis, 1, spend)
+	block2 := newBtcBlock(block1, 2)
+	client := NewFixtureClient(0, []*wire.MsgBlock{genesis, block1, block2}, []*wire.MsgTx{funding})
+
+	chain, db := newVerifyTestChain(t, genesis)
+	defer chain.Stop()
+	miner := &Miner{
+		eth: NewMockBackend(chain, db),
+		bt:  NewBlockTranslatorWithClient(client),
+	}
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to sync fixture blocks: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.NumberU64() != 2 {
+		t.Fatalf("head mismatch: have %d, want 2", head.NumberU64())
+	}
+	if txs := chain.GetBlockByNumber(1).Transactions(); len(txs) != 1 {
+		t.Fatalf("translated tx count mismatch: have %d, want 1", len(txs))
+	}
+	divergence, err := VerifyRange(chain, NewBlockTranslatorWithClient(client), 1, 2)
+	if err != nil {
+		t.Fatalf("failed to verify range: %v", err)
+	}
+	if divergence != nil {
+		t.Fatalf("unexpected divergence: %v", divergence)
+	}
+
+	// A different BTC block at height 2 must be reported as the first divergence.
+	forked := newBtcBlock(block1, 3)
+	client = NewFixtureClient(0, []*wire.MsgBlock{genesis, block1, forked}, []*wire.MsgTx{funding})
+	divergence, err = VerifyRange(chain, NewBlockTranslatorWithClient(client), 1, 2)
+	if err != nil {
+		t.Fatalf("failed to verify range: %v", err)
+	}
+	if divergence == nil || divergence.Number != 2 || divergence.Field != "btcHash" {
+		t.Fatalf("divergence mismatch: have %v, want btcHash at block 2", divergence)
+	}
+}
+
+func TestLoadFixtureClient(t *testing.T) {
+	funding, spend := newDeployTxs(t, nil)
+	genesis := newBtcBlock(nil, 0)
+	block1 := newBtcBlock(genesis, 1, spend)
+
+	dir := t.TempDir()
+	writeHex := func(name string, msg interface{ Serialize(io.Writer) error }) {
+		var buf bytes.Buffer
+		if err := msg.Serialize(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(hex.EncodeToString(buf.Bytes())+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeHex("block-0.hex", genesis)
+	writeHex("block-1.hex", block1)
+	writeHex("tx-"+funding.TxHash().String()+".hex", funding)
+
+	client, err := LoadFixtureClient(dir)
+	if err != nil {
+		t.Fatalf("failed to load fixtures: %v", err)
+	}
+	if tip, _ := client.GetBlockCount(); tip != 1 {
+		t.Fatalf("tip mismatch: have %d, want 1", tip)
+	}
+	hash, err := client.GetBlockHash(1)
+	if err != nil || *hash != block1.BlockHash() {
+		t.Fatalf("block hash mismatch: have %v, want %v (err %v)", hash, block1.BlockHash(), err)
+	}
+	fundingHash := funding.TxHash()
+	if _, err := client.GetRawTransaction(&fundingHash); err != nil {
+		t.Fatalf("funding transaction not found: %v", err)
+	}
+	spendHash := spend.TxHash()
+	if _, err := client.GetRawTransaction(&spendHash); err != nil {
+		t.Fatalf("block transaction not found: %v", err)
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/layer2"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/mock_state"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Witness holds the parts of the state and of the chain read by executing a
+// single bevm block, enough to execute it again without a database. Nodes and
+// codes are authenticated by their hash, headers by linking up to the parent
+// of the block and BTC headers by the uncle hashes of those headers.
+type Witness struct {
+	Accounts   []common.Address // accounts read by the block
+	Storage    []WitnessStorage // storage slots read by the block
+	Codes      [][]byte         // contract codes read by the block
+	Nodes      [][]byte         // trie nodes on the paths of all reads and writes
+	Headers    []*types.Header  // ancestors below the parent read by BLOCKHASH or the BTC header precompiles
+	BtcHeaders [][]byte         // raw BTC headers read by the BTC header precompiles
+}
+
+// WitnessStorage lists the storage slots of an account read by a block.
+type WitnessStorage struct {
+	Address common.Address
+	Slots   []common.Hash
+}
+
+// StatelessBlock bundles a block with its parent header and witness, which is
+// everything needed to verify its post-state root.
+type StatelessBlock struct {
+	Parent  *types.Header
+	Block   *types.Block
+	Witness *Witness
+}
+
+// witnessRecorder is a key-value store serving the trie nodes and codes of a
+// live state database and recording every one read. Writes stay in memory.
+type witnessRecorder struct {
+	*memorydb.Database
+	source state.Database
+	nodes  map[common.Hash][]byte
+	codes  map[common.Hash][]byte
+}
+
+func newWitnessRecorder(source state.Database) *witnessRecorder {
+	return &witnessRecorder{
+		Database: memorydb.New(),
+		source:   source,
+		nodes:    make(map[common.Hash][]byte),
+		codes:    make(map[common.Hash][]byte),
+	}
+}
+
+func (r *witnessRecorder) Has(key []byte) (bool, error) {
+	_, err := r.Get(key)
+	return err == nil, nil
+}
+
+func (r *witnessRecorder) Get(key []byte) ([]byte, error) {
+	if value, err := r.Database.Get(key); err == nil {
+		return value, nil
+	}
+	switch {
+	case len(key) == common.HashLength:
+		hash := common.BytesToHash(key)
+		node, err := r.source.TrieDB().Node(hash)
+		if err != nil {
+			return nil, err
+		}
+		r.nodes[hash] = node
+		return node, nil
+	case len(key) == len(rawdb.CodePrefix)+common.HashLength && bytes.HasPrefix(key, rawdb.CodePrefix):
+		hash := common.BytesToHash(key[len(rawdb.CodePrefix):])
+		code, err := r.source.ContractCode(common.Hash{}, hash)
+		if err != nil {
+			return nil, err
+		}
+		r.codes[hash] = code
+		return code, nil
+	}
+	return nil, errors.New("not found")
+}
+
+// recordingChain serves the headers and BTC headers of chain, recording every
+// one read.
+type recordingChain struct {
+	*core.BlockChain
+	headers    map[common.Hash]*types.Header
+	btcHeaders map[common.Hash][]byte
+}
+
+func (c *recordingChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	header := c.BlockChain.GetHeader(hash, number)
+	if header != nil {
+		c.headers[hash] = header
+	}
+	return header
+}
+
+func (c *recordingChain) GetBtcHeader(hash common.Hash) []byte {
+	header := c.BlockChain.GetBtcHeader(hash)
+	if header != nil {
+		c.btcHeaders[hash] = header
+	}
+	return header
+}
+
+// BuildWitness executes block on the state of its parent and records the
+// witness needed to execute it again statelessly. The state of the parent must
+// be available.
+func BuildWitness(chain *core.BlockChain, block *types.Block) (*Witness, error) {
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis block has no witness")
+	}
+	parent := chain.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("missing parent header %d", block.NumberU64()-1)
+	}
+	var (
+		recorder = newWitnessRecorder(chain.StateCache())
+		mock     = mock_state.NewMockDatabase(state.NewDatabase(rawdb.NewDatabase(recorder)))
+		reader   = &recordingChain{BlockChain: chain, headers: make(map[common.Hash]*types.Header), btcHeaders: make(map[common.Hash][]byte)}
+	)
+	statedb, err := state.New(parent.Root, mock, nil)
+	if err != nil {
+		return nil, fmt.Errorf("missing state of block %d: %v", parent.Number, err)
+	}
+	root, _, _, err := applyStateless(chain.Config(), reader, statedb, block)
+	if err != nil {
+		return nil, fmt.Errorf("execute block %d error: %v", block.NumberU64(), err)
+	}
+	if root != block.Root() {
+		return nil, fmt.Errorf("state root mismatch: have %x, want %x", root, block.Root())
+	}
+	witness := new(Witness)
+	accounts, storage := mock.ReadKeys()
+	owners := make(map[common.Hash]common.Address)
+	for _, key := range accounts {
+		addr := common.BytesToAddress(key)
+		witness.Accounts = append(witness.Accounts, addr)
+		owners[crypto.Keccak256Hash(addr[:])] = addr
+	}
+	sort.Slice(witness.Accounts, func(i, j int) bool {
+		return bytes.Compare(witness.Accounts[i][:], witness.Accounts[j][:]) < 0
+	})
+	for owner, keys := range storage {
+		slots := WitnessStorage{Address: owners[owner]}
+		for _, key := range keys {
+			slots.Slots = append(slots.Slots, common.BytesToHash(key))
+		}
+		sort.Slice(slots.Slots, func(i, j int) bool {
+			return bytes.Compare(slots.Slots[i][:], slots.Slots[j][:]) < 0
+		})
+		witness.Storage = append(witness.Storage, slots)
+	}
+	sort.Slice(witness.Storage, func(i, j int) bool {
+		return bytes.Compare(witness.Storage[i].Address[:], witness.Storage[j].Address[:]) < 0
+	})
+	witness.Codes = sortedValues(recorder.codes)
+	witness.Nodes = sortedValues(recorder.nodes)
+	witness.BtcHeaders = sortedValues(reader.btcHeaders)
+	for hash, header := range reader.headers {
+		if hash != parent.Hash() {
+			witness.Headers = append(witness.Headers, header)
+		}
+	}
+	sort.Slice(witness.Headers, func(i, j int) bool {
+		return witness.Headers[i].Number.Cmp(witness.Headers[j].Number) > 0
+	})
+	return witness, nil
+}
+
+// sortedValues returns the values of items ordered by key.
+func sortedValues(items map[common.Hash][]byte) [][]byte {
+	keys := make([]common.Hash, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = items[key]
+	}
+	return values
+}
+
+// witnessChain serves the headers and BTC headers of a witness.
+type witnessChain struct {
+	engine     consensus.Engine
+	headers    map[common.Hash]*types.Header
+	btcHeaders map[common.Hash][]byte
+}
+
+func (c *witnessChain) Engine() consensus.Engine { return c.engine }
+
+func (c *witnessChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := c.headers[hash]; header != nil && header.Number.Uint64() == number {
+		return header
+	}
+	return nil
+}
+
+func (c *witnessChain) GetBtcHeader(hash common.Hash) []byte {
+	return c.btcHeaders[hash]
+}
+
+// VerifyStateless executes block on top of parent using only the state and
+// chain data of witness, and checks the gas used, receipt root, bloom, state
+// root and message MMR of the block against the result. The parent header is
+// trusted, the witness and the transactions of the block are not.
+func VerifyStateless(config *params.ChainConfig, parent *types.Header, block *types.Block, witness *Witness) error {
+	if block.ParentHash() != parent.Hash() || block.NumberU64() != parent.Number.Uint64()+1 {
+		return fmt.Errorf("block %d is not a child of %d [%x]", block.NumberU64(), parent.Number, parent.Hash())
+	}
+	if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != block.TxHash() {
+		return fmt.Errorf("transaction root mismatch: have %x, want %x", hash, block.TxHash())
+	}
+	engine := layer2.NewForChain(config)
+	chain := &witnessChain{
+		engine:     engine,
+		headers:    map[common.Hash]*types.Header{parent.Hash(): parent},
+		btcHeaders: make(map[common.Hash][]byte),
+	}
+	// Only accept the headers linked to the parent.
+	byHash := make(map[common.Hash]*types.Header, len(witness.Headers))
+	for _, header := range witness.Headers {
+		byHash[header.Hash()] = header
+	}
+	for header := parent; header.Number.Sign() > 0; {
+		if header = byHash[header.ParentHash]; header == nil {
+			break
+		}
+		chain.headers[header.Hash()] = header
+	}
+	for _, header := range witness.BtcHeaders {
+		chain.btcHeaders[BtcHashToEvmHash(chainhash.DoubleHashH(header))] = header
+	}
+	db := memorydb.New()
+	for _, node := range witness.Nodes {
+		rawdb.WriteTrieNode(db, crypto.Keccak256Hash(node), node)
+	}
+	for _, code := range witness.Codes {
+		rawdb.WriteCode(db, crypto.Keccak256Hash(code), code)
+	}
+	statedb, err := state.New(parent.Root, state.NewDatabase(rawdb.NewDatabase(db)), nil)
+	if err != nil {
+		return fmt.Errorf("incomplete witness: %v", err)
+	}
+	root, receipts, usedGas, err := applyStateless(config, chain, statedb, block)
+	if err != nil {
+		return err
+	}
+	if usedGas != block.GasUsed() {
+		return fmt.Errorf("gas used mismatch: have %d, want %d", usedGas, block.GasUsed())
+	}
+	if hash := types.DeriveSha(receipts, trie.NewStackTrie(nil)); hash != block.ReceiptHash() {
+		return fmt.Errorf("receipt root mismatch: have %x, want %x", hash, block.ReceiptHash())
+	}
+	if bloom := types.CreateBloom(receipts); bloom != block.Bloom() {
+		return fmt.Errorf("bloom mismatch: have %x, want %x", bloom, block.Bloom())
+	}
+	if root != block.Root() {
+		return fmt.Errorf("state root mismatch: have %x, want %x", root, block.Root())
+	}
+	if !engine.IsCrossLayer(block.Number()) {
+		return nil
+	}
+	size, mmrRoot := engine.ExtractMMR(receipts)
+	if size == 0 {
+		size, mmrRoot = parent.Nonce.Uint64(), parent.MixDigest
+	}
+	if size != block.Nonce() || mmrRoot != block.MixDigest() {
+		return fmt.Errorf("mmr mismatch: have %d [%x], want %d [%x]", size, mmrRoot, block.Nonce(), block.MixDigest())
+	}
+	return nil
+}
+
+// applyStateless executes the transactions of block on statedb the way the
+// state processor does, reading ancestors through chain, and returns the post
+// state root. Missing trie nodes surface as an incomplete witness error.
+func applyStateless(config *params.ChainConfig, chain core.ChainContext, statedb *state.StateDB, block *types.Block) (common.Hash, types.Receipts, uint64, error) {
+	var (
+		receipts types.Receipts
+		usedGas  uint64
+		header   = block.Header()
+		gp       = new(core.GasPool).AddGas(block.GasLimit())
+	)
+	for i, tx := range block.Transactions() {
+		statedb.Prepare(tx.Hash(), i)
+		receipt, err := core.ApplyTransaction(config, chain, nil, gp, statedb, header, tx, &usedGas, vm.Config{})
+		if err == nil {
+			err = statedb.Error()
+		}
+		if err != nil {
+			return common.Hash{}, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+		}
+		receipts = append(receipts, receipt)
+	}
+	root := statedb.IntermediateRoot(config.IsEIP158(header.Number))
+	if err := statedb.Error(); err != nil {
+		return common.Hash{}, nil, 0, fmt.Errorf("incomplete witness: %v", err)
+	}
+	return root, receipts, usedGas, nil
+}
@@ -0,0 +1,104 @@
+package bevm
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestStatelessVerification(t *testing.T) {
+	funding1, spend1 := newDeployTxs(t, []byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x00}) // sstore(0, 1)
+	// sstore(0, returndatasize(staticcall(btcHeader(1)))); sstore(1, blockhash(1))
+	funding3, spend3 := newDeployTxs(t, common.FromHex("0x600160005260206000602060006101005afa503d60005560014060015500"))
+	genesis := newBtcBlock(nil, 0)
+	block1 := newBtcBlock(genesis, 1, spend1)
+	block2 := newBtcBlock(block1, 2)
+	block3 := newBtcBlock(block2, 3, spend3)
+	client := NewFixtureClient(0, []*wire.MsgBlock{genesis, block1, block2, block3}, []*wire.MsgTx{funding1, funding3})
+
+	chain, db := newVerifyTestChain(t, genesis)
+	defer chain.Stop()
+	miner := &Miner{
+		eth: NewMockBackend(chain, db),
+		bt:  NewBlockTranslatorWithClient(client),
+	}
+	if err := miner.loop(); err != nil {
+		t.Fatalf("failed to sync fixture blocks: %v", err)
+	}
+	for number := uint64(1); number <= 3; number++ {
+		block := chain.GetBlockByNumber(number)
+		witness, err := BuildWitness(chain, block)
+		if err != nil {
+			t.Fatalf("block %d: failed to build witness: %v", number, err)
+		}
+		enc, err := rlp.EncodeToBytes(&StatelessBlock{Parent: chain.GetHeaderByNumber(number - 1), Block: block, Witness: witness})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundle := new(StatelessBlock)
+		if err := rlp.DecodeBytes(enc, bundle); err != nil {
+			t.Fatalf("block %d: failed to decode bundle: %v", number, err)
+		}
+		if err := VerifyStateless(chain.Config(), bundle.Parent, bundle.Block, bundle.Witness); err != nil {
+			t.Fatalf("block %d: stateless verification failed: %v", number, err)
+		}
+	}
+
+	block := chain.GetBlockByNumber(3)
+	parent := chain.GetHeaderByNumber(2)
+	witness, _ := BuildWitness(chain, block)
+	if len(witness.BtcHeaders) != 1 || len(witness.Headers) != 1 || witness.Headers[0].Number.Uint64() != 1 {
+		t.Fatalf("chain data mismatch: %d btc headers, %d headers", len(witness.BtcHeaders), len(witness.Headers))
+	}
+	if len(witness.Storage) != 1 || len(witness.Storage[0].Slots) != 2 || len(witness.Accounts) == 0 {
+		t.Fatalf("read keys mismatch: %+v", witness)
+	}
+
+	statedb, _ := chain.State()
+	if size := statedb.GetState(witness.Storage[0].Address, common.Hash{}); size != common.BigToHash(big.NewInt(80)) {
+		t.Fatalf("btc header not relayed: %x", size)
+	}
+	if hash := statedb.GetState(witness.Storage[0].Address, common.BigToHash(common.Big1)); hash != chain.GetHeaderByNumber(1).Hash() {
+		t.Fatalf("block hash mismatch: %x", hash)
+	}
+
+	// Dropping any part of the witness must fail the verification.
+	for i := range witness.Nodes {
+		partial := *witness
+		partial.Nodes = append(append([][]byte{}, witness.Nodes[:i]...), witness.Nodes[i+1:]...)
+		if err := VerifyStateless(chain.Config(), parent, block, &partial); err == nil {
+			t.Fatalf("verified without node %d", i)
+		}
+	}
+	partial := *witness
+	partial.BtcHeaders = nil
+	if err := VerifyStateless(chain.Config(), parent, block, &partial); err == nil {
+		t.Fatal("verified without btc header")
+	}
+	partial = *witness
+	partial.Headers = nil
+	if err := VerifyStateless(chain.Config(), parent, block, &partial); err == nil {
+		t.Fatal("verified without ancestor header")
+	}
+	// A block claiming a different state root must fail.
+	header := block.Header()
+	header.Root = common.Hash{0x01}
+	if err := VerifyStateless(chain.Config(), parent, block.WithSeal(header), witness); err == nil {
+		t.Fatal("verified forged state root")
+	}
+	header = block.Header()
+	header.Bloom = types.Bloom{0x01}
+	if err := VerifyStateless(chain.Config(), parent, block.WithSeal(header), witness); err == nil {
+		t.Fatal("verified forged bloom")
+	}
+	// Transactions not committed to by the header must fail.
+	substituted := block.WithBody(chain.GetBlockByNumber(1).Transactions(), nil)
+	if err := VerifyStateless(chain.Config(), parent, substituted, witness); err == nil || !strings.Contains(err.Error(), "transaction root") {
+		t.Fatalf("verified substituted transactions: %v", err)
+	}
+}
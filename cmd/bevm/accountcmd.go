@@ -22,6 +22,8 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/bevm"
+	"github.com/ethereum/go-ethereum/bevm/protocol"
 	"github.com/ethereum/go-ethereum/cmd/bevm/utils"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
@@ -151,6 +153,31 @@ For non-interactive use the password can be specified with the --password flag:
 
 Since only one password can be given, only format update can be performed,
 changing your password is only possible interactively.
+`,
+			},
+			{
+				Name:      "btc",
+				Usage:     "Print the BTC addresses of existing accounts",
+				Action:    utils.MigrateFlags(accountBtc),
+				ArgsUsage: "<address>",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.BtcNetworkFlag,
+					utils.BtcNetConfigFlag,
+				},
+				Description: `
+    bevm account btc [options] <address>...
+
+Print the bevm forms of the keys of the given accounts on the BTC network: the
+EVM script address invocations are sent from, the P2WSH address funding it, the
+EVM account of the script and the unified EVM account, which is the address of
+the account.
+
+The key files do not store the public keys, you are prompted for the password
+of each account. For non-interactive use the passwords can be specified with the
+--password flag.
 `,
 			},
 			{
@@ -257,6 +284,34 @@ func ambiguousAddrRecovery(ks *keystore.KeyStore, err *keystore.AmbiguousAddrErr
 	return *match
 }
 
+// accountBtc prints the BTC and EVM addresses of the keys of accounts.
+func accountBtc(ctx *cli.Context) error {
+	if len(ctx.Args()) == 0 {
+		utils.Fatalf("No accounts specified")
+	}
+	stack, _ := makeConfigNode(ctx)
+	net, err := protocol.NetParamsByName(ctx.GlobalString(utils.BtcNetworkFlag.Name))
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	passwords := utils.MakePasswordList(ctx)
+	for i, addr := range ctx.Args() {
+		account, _ := unlockAccount(ks, addr, i, passwords)
+		info, err := bevm.AccountInfo(ks, account, nil, net)
+		ks.Lock(account.Address)
+		if err != nil {
+			utils.Fatalf("Could not derive the addresses of %s: %v", addr, err)
+		}
+		fmt.Printf("Account {%x} on %s\n", account.Address, info.Network)
+		fmt.Printf("  EVM script address:    %s\n", info.EVMScriptAddress)
+		fmt.Printf("  P2WSH funding address: %s\n", info.P2WSHAddress)
+		fmt.Printf("  Script EVM address:    %s\n", info.EVMAddress.Hex())
+		fmt.Printf("  Unified EVM address:   %s\n", info.UnifiedAddress.Hex())
+	}
+	return nil
+}
+
 // accountCreate creates a new account into the keystore defined by the CLI flags.
 func accountCreate(ctx *cli.Context) error {
 	cfg := gethConfig{Node: defaultNodeConfig()}
@@ -0,0 +1,53 @@
+// Copyright 2023 The Goshen network Authors
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/bevm/protocol"
+	butils "github.com/ethereum/go-ethereum/bevm/protocol/utils"
+	"github.com/ethereum/go-ethereum/cmd/bevm/utils"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var addressCommand = cli.Command{
+	Action:    utils.MigrateFlags(convertAddress),
+	Name:      "address",
+	Usage:     "Convert an address between its BTC and EVM forms",
+	ArgsUsage: "<address|pubkey>",
+	Flags: []cli.Flag{
+		utils.BtcNetworkFlag,
+		utils.BtcNetConfigFlag,
+	},
+	Category: "MISCELLANEOUS COMMANDS",
+	Description: `
+The address command resolves any of
+
+    - a bech32 EVM P2WSH address (bc1e..., tbe1...)
+    - a plain P2WSH address funding an EVM script (bc1q..., tb1q...)
+    - a 0x EVM address
+    - a hex encoded secp256k1 public key
+
+into the EVM P2WSH address, the P2WSH address and the EVM account it maps to,
+and reports the network and script class of the input. Public keys are resolved
+on the network given by --btc.network, which may be a custom network defined in
+--btc.netconfig. Other BTC addresses are only validated.`,
+}
+
+func convertAddress(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an address or public key argument.")
+	}
+	utils.RegisterBtcNetworks(ctx)
+	net, err := protocol.NetParamsByName(ctx.String(utils.BtcNetworkFlag.Name))
+	if err != nil {
+		return err
+	}
+	info, err := protocol.ConvertAddress(ctx.Args().First(), net)
+	if err != nil {
+		return err
+	}
+	fmt.Println(butils.JsonString(info))
+	return nil
+}
@@ -22,7 +22,9 @@ import (
 	"errors"
 	"fmt"
 	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/ethereum/go-ethereum/bevm"
+	"github.com/ethereum/go-ethereum/bevm/protocol"
 	utils2 "github.com/ethereum/go-ethereum/bevm/protocol/utils"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/params"
@@ -56,6 +58,11 @@ var (
 		ArgsUsage: "<btcRpcHost> <user> <pass>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.BtcRpcCookieFlag,
+			utils.BtcRpcTLSFlag,
+			utils.BtcRpcCACertFlag,
+			utils.BtcNetworkFlag,
+			utils.BtcNetConfigFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -63,7 +70,8 @@ The init command initializes a new genesis block and definition for the network.
 This is a destructive action and changes the network in which you will be
 participating.
 
-It expects the genesis file as argument.`,
+It derives the genesis from the genesis block of the given BTC node and records
+its network, detected from getblockchaininfo unless --btc.network is given.`,
 	}
 	initCommand = cli.Command{
 		Action:    utils.MigrateFlags(initGenesis),
@@ -214,11 +222,17 @@ func initByUrlGenesis(ctx *cli.Context) error {
 	}
 	user := ctx.Args().Get(1)
 	pass := ctx.Args().Get(2)
-	bt := bevm.NewBlockTranslator(&bevm.BtcRpcConfig{
-		Host: host,
-		User: user,
-		Pass: pass,
+	bt, err := bevm.NewBlockTranslator(&bevm.BtcRpcConfig{
+		Host:       host,
+		User:       user,
+		Pass:       pass,
+		CookieFile: ctx.GlobalString(utils.BtcRpcCookieFlag.Name),
+		TLS:        ctx.GlobalBool(utils.BtcRpcTLSFlag.Name),
+		CACert:     ctx.GlobalString(utils.BtcRpcCACertFlag.Name),
 	})
+	if err != nil {
+		return err
+	}
 	hash, err := bt.Client.GetBlockHash(0)
 	if err != nil {
 		return fmt.Errorf("get btc block hash error: %v", err)
@@ -229,6 +243,23 @@ func initByUrlGenesis(ctx *cli.Context) error {
 		return fmt.Errorf("get btc block error: %v", err)
 	}
 	log.Info("get btc genesis block success", "hash", hash)
+	info, err := bt.Client.GetBlockChainInfo()
+	if err != nil {
+		return fmt.Errorf("get btc chain info error: %v", err)
+	}
+	utils.RegisterBtcNetworks(ctx)
+	var net *chaincfg.Params
+	if ctx.GlobalIsSet(utils.BtcNetworkFlag.Name) {
+		if net, err = protocol.NetParamsByName(ctx.GlobalString(utils.BtcNetworkFlag.Name)); err != nil {
+			return err
+		}
+		if !protocol.IsChain(net, info.Chain) || !net.GenesisHash.IsEqual(hash) {
+			return fmt.Errorf("btc node serves chain %s with genesis %s, not network %s", info.Chain, hash, net.Name)
+		}
+	} else if net, err = protocol.NetParamsByChain(info.Chain, hash); err != nil {
+		return err
+	}
+	log.Info("Detected btc network", "name", net.Name)
 
 	alloc := make(map[common.Address]core.GenesisAccount)
 	for i := 0; i < 256; i++ {
@@ -251,6 +282,8 @@ func initByUrlGenesis(ctx *cli.Context) error {
 			ConstantinopleBlock: big.NewInt(0),
 			PetersburgBlock:     big.NewInt(0),
 			IstanbulBlock:       big.NewInt(0),
+			BevmBlock:           big.NewInt(0),
+			BtcNetwork:          net.Name,
 		},
 		Nonce:      0,
 		Timestamp:  uint64(block.Header.Timestamp.Unix()),
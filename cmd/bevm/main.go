@@ -148,6 +148,19 @@ var (
 		utils.BtcRpcHost,
 		utils.BtcRpcUser,
 		utils.BtcRpcPass,
+		utils.BtcRpcCookieFlag,
+		utils.BtcRpcTLSFlag,
+		utils.BtcRpcCACertFlag,
+		utils.BtcNetworkFlag,
+		utils.BtcNetConfigFlag,
+		utils.BtcPrefetchFlag,
+		utils.VerifyInsertFlag,
+		utils.PeerSyncFlag,
+		utils.HealthMaxLagFlag,
+		utils.BtcCheckpointKeyFlag,
+		utils.BtcCheckpointAddressFlag,
+		utils.BtcCheckpointIntervalFlag,
+		utils.BtcCheckpointFeeRateFlag,
 	}
 
 	metricsFlags = []cli.Flag{
@@ -204,6 +217,11 @@ func init() {
 		versionCommand,
 		versionCheckCommand,
 		licenseCommand,
+		// See addresscmd.go:
+		addressCommand,
+		// See verifycmd.go:
+		verifyRangeCommand,
+		verifyStatelessCommand,
 		// See config.go
 		dumpConfigCommand,
 		// see dbcmd.go
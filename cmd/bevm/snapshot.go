@@ -18,11 +18,17 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/bevm"
 	"github.com/ethereum/go-ethereum/cmd/bevm/utils"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
@@ -175,6 +181,56 @@ as the backend data source, making this command a lot faster.
 
 The argument is interpreted as block number or hash. If none is provided, the latest
 block is used.
+`,
+			},
+			{
+				Name:      "export",
+				Usage:     "Export the flat state of a block with its header chain and BTC anchor",
+				ArgsUsage: "<file> [<blockNum>]",
+				Action:    utils.MigrateFlags(exportSnapshot),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.CacheFlag,
+				},
+				Description: `
+bevm snapshot export <file> [<blockNum>]
+writes the flat state of the given block (default = head) to the file, along with
+the header chain from the genesis, the BTC headers the blocks are translated from
+and the message MMR of the block. The state is read from the state snapshot if
+it covers the block and from the tries otherwise. If the file ends with .gz, the
+output is gzipped.
+`,
+			},
+			{
+				Name:      "import",
+				Usage:     "Bootstrap the chain from a state snapshot anchored to BTC",
+				ArgsUsage: "<file>",
+				Action:    utils.MigrateFlags(importSnapshot),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.CacheFlag,
+					utils.BtcRpcHost,
+					utils.BtcRpcUser,
+					utils.BtcRpcPass,
+					utils.BtcRpcCookieFlag,
+					utils.BtcRpcTLSFlag,
+					utils.BtcRpcCACertFlag,
+				},
+				Description: `
+bevm snapshot import <file>
+imports a state snapshot written by 'bevm snapshot export' into a database that
+was initialised with the same genesis and holds no other block. The block of the
+snapshot must be anchored to the BTC block at the same height of the node given
+by --btc.host, its header chain must link up to the genesis and the imported
+state must hash to its state root. bevm then resumes translating from the next
+BTC height.
+
+The state root itself is taken from the snapshot: only import snapshots from a
+trusted source, or check the root against a BTC checkpoint.
 `,
 			},
 		},
@@ -533,3 +589,74 @@ func dumpState(ctx *cli.Context) error {
 		"elapsed", common.PrettyDuration(time.Since(start)))
 	return nil
 }
+
+func exportSnapshot(ctx *cli.Context) error {
+	if ctx.NArg() < 1 || ctx.NArg() > 2 {
+		utils.Fatalf("This command requires one or two arguments.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack)
+	defer db.Close()
+	defer chain.Stop()
+
+	number := chain.CurrentBlock().NumberU64()
+	if ctx.NArg() == 2 {
+		n, err := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+		if err != nil {
+			utils.Fatalf("Invalid block number: %v", err)
+		}
+		number = n
+	}
+	fn := ctx.Args().Get(0)
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	return bevm.ExportSnapshot(chain, db, number, writer)
+}
+
+func importSnapshot(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	var rpcConfig bevm.BtcRpcConfig
+	utils.SetBtcRpcConfig(ctx, &rpcConfig)
+	client, err := bevm.NewBtcClient(&rpcConfig)
+	if err != nil {
+		return err
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	fn := ctx.Args().Get(0)
+	fh, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = fh
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return err
+		}
+	}
+	head, err := bevm.ImportSnapshot(db, client, reader)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported block %d [%x], translation resumes at BTC height %d\n", head.Number, head.Hash(), head.Number.Uint64()+1)
+	return nil
+}
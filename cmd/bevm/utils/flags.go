@@ -21,6 +21,7 @@ import (
 	"crypto/ecdsa"
 	"fmt"
 	"github.com/ethereum/go-ethereum/bevm"
+	"github.com/ethereum/go-ethereum/bevm/protocol"
 	"io"
 	"io/ioutil"
 	"math"
@@ -38,9 +39,6 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/fdlimit"
-	"github.com/ethereum/go-ethereum/consensus"
-	"github.com/ethereum/go-ethereum/consensus/clique"
-	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -789,7 +787,7 @@ var (
 
 	BtcRpcHost = cli.StringFlag{
 		Name:  "btc.host",
-		Usage: "btc rpc host",
+		Usage: "btc rpc host, several comma separated hosts are used as fallbacks and cross checked",
 		Value: "127.0.0.1:12345",
 	}
 	BtcRpcUser = cli.StringFlag{
@@ -802,6 +800,62 @@ var (
 		Usage: "btc rpc pass",
 		Value: "",
 	}
+	BtcRpcCookieFlag = cli.StringFlag{
+		Name:  "btc.cookie",
+		Usage: "bitcoind rpc cookie file, used instead of --btc.user and --btc.pass",
+	}
+	BtcRpcTLSFlag = cli.BoolFlag{
+		Name:  "btc.tls",
+		Usage: "connect to the btc rpc over TLS",
+	}
+	BtcRpcCACertFlag = cli.StringFlag{
+		Name:  "btc.cacert",
+		Usage: "PEM file of the CA certificates trusted for the btc rpc TLS connection (default = system roots)",
+	}
+	BtcPrefetchFlag = cli.IntFlag{
+		Name:  "btc.prefetch",
+		Usage: "Number of BTC blocks downloaded ahead of execution while catching up (0 = sequential sync)",
+		Value: 0,
+	}
+	VerifyInsertFlag = cli.BoolFlag{
+		Name:  "verify-insert",
+		Usage: "Validate and re-execute every translated block through the regular block import before accepting it",
+	}
+	PeerSyncFlag = cli.BoolFlag{
+		Name:  "peersync",
+		Usage: "Import the EVM blocks served by bevm/1 peers, checked against the blocks translated from BTC, before translating from BTC",
+	}
+	BtcNetworkFlag = cli.StringFlag{
+		Name:  "btc.network",
+		Usage: "btc network (mainnet, testnet3, regtest, signet, simnet or a network of --btc.netconfig)",
+		Value: "mainnet",
+	}
+	BtcNetConfigFlag = cli.StringFlag{
+		Name:  "btc.netconfig",
+		Usage: "JSON file defining custom btc networks, e.g. private signets",
+	}
+	HealthMaxLagFlag = cli.Uint64Flag{
+		Name:  "health.maxlag",
+		Usage: "Largest number of untranslated btc blocks the /health endpoint of the HTTP-RPC server reports as healthy",
+		Value: bevm.DefaultHealthMaxLag,
+	}
+	BtcCheckpointKeyFlag = cli.StringFlag{
+		Name:  "btc.checkpoint.key",
+		Usage: "File of the hex private key committing state checkpoints to btc, funded by the btc node wallet",
+	}
+	BtcCheckpointAddressFlag = cli.StringFlag{
+		Name:  "btc.checkpoint.address",
+		Usage: "btc address whose state checkpoints are verified (default = address of --btc.checkpoint.key)",
+	}
+	BtcCheckpointIntervalFlag = cli.Uint64Flag{
+		Name:  "btc.checkpoint.interval",
+		Usage: "Number of blocks between state checkpoints",
+		Value: bevm.DefaultCheckpointInterval,
+	}
+	BtcCheckpointFeeRateFlag = cli.Int64Flag{
+		Name:  "btc.checkpoint.feerate",
+		Usage: "Fee rate of state checkpoints in sat/vB (0 = estimate of the btc node)",
+	}
 
 	//rollup config
 	RollupEnableFlag = cli.BoolFlag{
@@ -1217,9 +1271,39 @@ func CheckExclusive(ctx *cli.Context, args ...interface{}) {
 }
 
 func SetBtcRpcConfig(ctx *cli.Context, cfg *bevm.BtcRpcConfig) {
+	RegisterBtcNetworks(ctx)
+	if ctx.GlobalIsSet(BtcNetworkFlag.Name) {
+		cfg.Network = ctx.GlobalString(BtcNetworkFlag.Name)
+	}
 	cfg.Host = ctx.GlobalString(BtcRpcHost.Name)
 	cfg.User = ctx.GlobalString(BtcRpcUser.Name)
 	cfg.Pass = ctx.GlobalString(BtcRpcPass.Name)
+	cfg.CookieFile = ctx.GlobalString(BtcRpcCookieFlag.Name)
+	cfg.TLS = ctx.GlobalBool(BtcRpcTLSFlag.Name)
+	cfg.CACert = ctx.GlobalString(BtcRpcCACertFlag.Name)
+	cfg.Prefetch = ctx.GlobalInt(BtcPrefetchFlag.Name)
+	cfg.VerifyInsert = ctx.GlobalBool(VerifyInsertFlag.Name)
+	cfg.PeerSync = ctx.GlobalBool(PeerSyncFlag.Name)
+	cfg.HealthMaxLag = ctx.GlobalUint64(HealthMaxLagFlag.Name)
+	cfg.CheckpointKey = ctx.GlobalString(BtcCheckpointKeyFlag.Name)
+	cfg.CheckpointAddress = ctx.GlobalString(BtcCheckpointAddressFlag.Name)
+	cfg.CheckpointInterval = ctx.GlobalUint64(BtcCheckpointIntervalFlag.Name)
+	cfg.CheckpointFeeRate = ctx.GlobalInt64(BtcCheckpointFeeRateFlag.Name)
+}
+
+// RegisterBtcNetworks registers the custom btc networks of --btc.netconfig.
+func RegisterBtcNetworks(ctx *cli.Context) {
+	path := ctx.GlobalString(BtcNetConfigFlag.Name)
+	if path == "" {
+		return
+	}
+	nets, err := protocol.LoadNetworks(path)
+	if err != nil {
+		Fatalf("Failed to load btc networks: %v", err)
+	}
+	for _, net := range nets {
+		log.Info("Registered custom btc network", "name", net.Name, "genesis", net.GenesisHash)
+	}
 }
 
 // SetEthConfig applies eth-related command line flags to the config.
@@ -1590,24 +1674,8 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	if err != nil {
 		Fatalf("%v", err)
 	}
-	var engine consensus.Engine
-	if config.Clique != nil {
-		engine = clique.New(config.Clique, chainDb)
-	} else {
-		engine = ethash.NewFaker()
-		if !ctx.GlobalBool(FakePoWFlag.Name) {
-			engine = ethash.New(ethash.Config{
-				CacheDir:         stack.ResolvePath(ethconfig.Defaults.Ethash.CacheDir),
-				CachesInMem:      ethconfig.Defaults.Ethash.CachesInMem,
-				CachesOnDisk:     ethconfig.Defaults.Ethash.CachesOnDisk,
-				CachesLockMmap:   ethconfig.Defaults.Ethash.CachesLockMmap,
-				DatasetDir:       stack.ResolvePath(ethconfig.Defaults.Ethash.DatasetDir),
-				DatasetsInMem:    ethconfig.Defaults.Ethash.DatasetsInMem,
-				DatasetsOnDisk:   ethconfig.Defaults.Ethash.DatasetsOnDisk,
-				DatasetsLockMmap: ethconfig.Defaults.Ethash.DatasetsLockMmap,
-			}, nil, false)
-		}
-	}
+	// The stored config may predate bevmBlock, pick the engine like the node.
+	engine := bevm.CreateConsensusEngine(config)
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
@@ -0,0 +1,162 @@
+// Copyright 2023 The Goshen network Authors
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/bevm"
+	"github.com/ethereum/go-ethereum/cmd/bevm/utils"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/rlp"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	fixturesFlag = cli.StringFlag{
+		Name:  "fixtures",
+		Usage: "Directory of hex encoded BTC blocks (block-<height>.hex) and transactions (tx-<txid>.hex) to use instead of the BTC RPC",
+	}
+	verifyRangeCommand = cli.Command{
+		Action:    utils.MigrateFlags(verifyRange),
+		Name:      "verify-range",
+		Usage:     "Re-derive a range of blocks from BTC and compare them with the local chain",
+		ArgsUsage: "<from> <to>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.GCModeFlag,
+			utils.BtcRpcHost,
+			utils.BtcRpcUser,
+			utils.BtcRpcPass,
+			utils.BtcRpcCookieFlag,
+			utils.BtcRpcTLSFlag,
+			utils.BtcRpcCACertFlag,
+			utils.BtcNetworkFlag,
+			utils.BtcNetConfigFlag,
+			fixturesFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The verify-range command re-fetches the BTC blocks from..to (inclusive), translates
+and executes them again on a scratch state seeded from the stored parent of
+<from>, and compares the transactions, receipts, gas usage, state roots and
+block hashes with the locally stored chain. The first divergence is printed and
+the command fails; nothing is written to the database.
+
+BTC blocks are read from the node given by --btc.host, or from a fixture
+directory given by --fixtures. The state of block <from>-1 must be available.`,
+	}
+	verifyStatelessCommand = cli.Command{
+		Action:    utils.MigrateFlags(verifyStateless),
+		Name:      "verify-stateless",
+		Usage:     "Re-execute a block from its witness and check its state root",
+		ArgsUsage: "<genesisPath> <witnessPath>",
+		Category:  "BLOCKCHAIN COMMANDS",
+		Description: `
+The verify-stateless command executes a single block without any database, using
+only its parent header, the block itself and the witness of the state and chain
+data it reads, as returned by bevm_getWitness (hex or binary RLP). The gas used,
+receipt root and state root of the block are checked against the result.
+
+The chain rules are taken from the genesis file. The parent header is trusted
+as given; compare its hash with a trusted source such as a BTC checkpoint.`,
+	}
+)
+
+func verifyRange(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires two arguments.")
+	}
+	from, ferr := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+	to, terr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	if ferr != nil || terr != nil {
+		utils.Fatalf("Range arguments must be block numbers")
+	}
+	var (
+		bt        *bevm.BlockTranslator
+		rpcConfig bevm.BtcRpcConfig
+		fixtures  = ctx.GlobalString(fixturesFlag.Name)
+	)
+	if fixtures != "" {
+		client, err := bevm.LoadFixtureClient(fixtures)
+		if err != nil {
+			return err
+		}
+		bt = bevm.NewBlockTranslatorWithClient(client)
+	} else {
+		utils.SetBtcRpcConfig(ctx, &rpcConfig)
+		var err error
+		if bt, err = bevm.NewBlockTranslator(&rpcConfig); err != nil {
+			return err
+		}
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack)
+	defer db.Close()
+	defer chain.Stop()
+
+	if fixtures == "" {
+		if _, err := bevm.CheckChainNetwork(chain, bt, rpcConfig.Network); err != nil {
+			return err
+		}
+	}
+
+	divergence, err := bevm.VerifyRange(chain, bt, from, to)
+	if err != nil {
+		return err
+	}
+	if divergence != nil {
+		fmt.Println("First divergence:", divergence)
+		return errors.New("stored chain diverges from BTC")
+	}
+	fmt.Printf("Blocks %d-%d match their BTC derivation\n", from, to)
+	return nil
+}
+
+func verifyStateless(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires two arguments.")
+	}
+	file, err := os.Open(ctx.Args().Get(0))
+	if err != nil {
+		utils.Fatalf("Failed to read genesis file: %v", err)
+	}
+	defer file.Close()
+
+	genesis := new(core.Genesis)
+	if err := json.NewDecoder(file).Decode(genesis); err != nil {
+		utils.Fatalf("invalid genesis file: %v", err)
+	}
+	if genesis.Config == nil {
+		utils.Fatalf("Genesis file has no chain config")
+	}
+	data, err := ioutil.ReadFile(ctx.Args().Get(1))
+	if err != nil {
+		utils.Fatalf("Failed to read witness file: %v", err)
+	}
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("0x")) {
+		if data, err = hexutil.Decode(string(trimmed)); err != nil {
+			utils.Fatalf("Invalid hex witness: %v", err)
+		}
+	}
+	bundle := new(bevm.StatelessBlock)
+	if err := rlp.DecodeBytes(data, bundle); err != nil {
+		utils.Fatalf("Invalid witness: %v", err)
+	}
+	if err := bevm.VerifyStateless(genesis.Config, bundle.Parent, bundle.Block, bundle.Witness); err != nil {
+		return err
+	}
+	fmt.Printf("Block %d [%x] verified, state root %x\n", bundle.Block.NumberU64(), bundle.Block.Hash(), bundle.Block.Root())
+	return nil
+}
@@ -20,6 +20,7 @@ type Layer2Instant struct {
 	Timestamp           uint64
 	L2CrossLayerWitness common.Address
 	FeeCollector        common.Address
+	CrossLayerBlock     *big.Int // first block using the fee collector and message MMR, nil for none
 }
 
 func New(config *params.Layer2InstantConfig) *Layer2Instant {
@@ -27,16 +28,46 @@ func New(config *params.Layer2InstantConfig) *Layer2Instant {
 		Timestamp:           uint64(time.Now().Unix()),
 		L2CrossLayerWitness: config.L2CrossLayerWitness,
 		FeeCollector:        config.FeeCollector,
+		CrossLayerBlock:     common.Big0,
 	}
 }
 
+// NewForChain creates the engine configured by the layer2Instant section of the
+// chain config from its cross layer fork on. Chains without the fork keep the
+// blocks of an engine without fee collector and cross layer witness.
+func NewForChain(config *params.ChainConfig) *Layer2Instant {
+	if config.Layer2Instant == nil || config.CrossLayerBlock == nil {
+		engine := New(&params.Layer2InstantConfig{})
+		engine.CrossLayerBlock = nil
+		return engine
+	}
+	engine := New(config.Layer2Instant)
+	engine.CrossLayerBlock = config.CrossLayerBlock
+	return engine
+}
+
+// IsCrossLayer reports whether the block with the given number carries the
+// message MMR in its nonce and mix digest.
+func (self *Layer2Instant) IsCrossLayer(number *big.Int) bool {
+	return self.CrossLayerBlock != nil && self.CrossLayerBlock.Cmp(number) <= 0
+}
+
+// Coinbase returns the coinbase of the block with the given number, the fee
+// collector from the cross layer fork on.
+func (self *Layer2Instant) Coinbase(number *big.Int) common.Address {
+	if !self.IsCrossLayer(number) {
+		return common.Address{}
+	}
+	return self.FeeCollector
+}
+
 func (self *Layer2Instant) Author(header *types.Header) (common.Address, error) {
 	return header.Coinbase, nil
 }
 
 func (self *Layer2Instant) verifyHeader(header, parent *types.Header) error {
-	if header.Coinbase != self.FeeCollector {
-		return fmt.Errorf("invalid coinbase, expected: %s, found: %s", self.FeeCollector, header.Coinbase)
+	if coinbase := self.Coinbase(header.Number); header.Coinbase != coinbase {
+		return fmt.Errorf("invalid coinbase, expected: %s, found: %s", coinbase, header.Coinbase)
 	}
 	if len(header.Extra) != 0 {
 		return fmt.Errorf("extra data not nil, found: %x", header.Extra)
@@ -105,7 +136,7 @@ func (self *Layer2Instant) Prepare(chain consensus.ChainHeaderReader, header *ty
 	if header.Time == 0 {
 		return errors.New("header's timestamp must be set")
 	}
-	header.Coinbase = self.FeeCollector
+	header.Coinbase = self.Coinbase(header.Number)
 	header.Difficulty = big.NewInt(1)
 	header.Extra = nil
 	currNumber := header.Number.Uint64()
@@ -139,7 +170,7 @@ func (self *Layer2Instant) FinalizeAndAssemble(chain consensus.ChainHeaderReader
 	uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
 	self.Finalize(chain, header, state, txs, uncles)
 	mmrSize, mmrRoot := self.ExtractMMR(receipts)
-	if mmrSize != 0 {
+	if mmrSize != 0 && self.IsCrossLayer(header.Number) {
 		if mmrSize <= header.Nonce.Uint64() {
 			return nil, fmt.Errorf("mmr size not increase, prev: %d, curr:%d", header.Nonce.Uint64(), mmrSize)
 		}
@@ -105,7 +105,7 @@ func (v *BlockValidator) ValidateState(block *types.Block, statedb *state.StateD
 		return fmt.Errorf("invalid merkle root (remote: %x local: %x)", header.Root, root)
 	}
 
-	if engine, ok := v.engine.(*layer2.Layer2Instant); ok {
+	if engine, ok := v.engine.(*layer2.Layer2Instant); ok && engine.IsCrossLayer(header.Number) {
 		mmrSize, mmrRoot := engine.ExtractMMR(receipts)
 		if mmrSize == 0 {
 			parent := v.bc.GetHeader(header.ParentHash, header.Number.Uint64()-1)
@@ -1190,6 +1190,29 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	return bc.writeBlockWithState(block, receipts, logs, state, emitHeadEvent)
 }
 
+// WriteExecutedBlock writes a block the caller already executed on top of its
+// parent state, skipping the validation and re-execution done by InsertChain.
+// The processing time is accounted like that of an inserted canonical block.
+func (bc *BlockChain) WriteExecutedBlock(block *types.Block, receipts []*types.Receipt, logs []*types.Log, state *state.StateDB, proctime time.Duration) (WriteStatus, error) {
+	if !bc.chainmu.TryLock() {
+		return NonStatTy, errChainStopped
+	}
+	defer bc.chainmu.Unlock()
+
+	start := time.Now()
+	status, err := bc.writeBlockWithState(block, receipts, logs, state, true)
+	if err != nil {
+		return status, err
+	}
+	blockWriteTimer.UpdateSince(start)
+	if status == CanonStatTy {
+		bc.gcproc += proctime
+	}
+	log.Debug("Wrote executed block", "number", block.Number(), "hash", block.Hash(), "status", status,
+		"txs", len(block.Transactions()), "gas", block.GasUsed(), "root", block.Root())
+	return status, nil
+}
+
 // writeBlockWithState writes the block and all associated state to the database,
 // but is expects the chain mutex to be held.
 func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.Receipt, logs []*types.Log, state *state.StateDB, emitHeadEvent bool) (status WriteStatus, err error) {
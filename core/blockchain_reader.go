@@ -73,6 +73,12 @@ func (bc *BlockChain) GetHeaderByNumber(number uint64) *types.Header {
 	return bc.hc.GetHeaderByNumber(number)
 }
 
+// GetBtcHeader retrieves the raw BTC block header with the given hash, as
+// recorded by the bevm translator.
+func (bc *BlockChain) GetBtcHeader(hash common.Hash) []byte {
+	return rawdb.ReadBtcHeader(bc.db, hash)
+}
+
 // GetBody retrieves a block body (transactions and uncles) from the database by
 // hash, caching it if found.
 func (bc *BlockChain) GetBody(hash common.Hash) *types.Body {
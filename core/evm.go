@@ -17,6 +17,7 @@
 package core
 
 import (
+	"crypto/sha256"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -35,6 +36,13 @@ type ChainContext interface {
 	GetHeader(common.Hash, uint64) *types.Header
 }
 
+// BtcHeaderReader is implemented by chain contexts which also keep the raw BTC
+// block headers that bevm blocks were translated from.
+type BtcHeaderReader interface {
+	// GetBtcHeader returns the raw BTC block header with the given hash.
+	GetBtcHeader(common.Hash) []byte
+}
+
 // NewEVMBlockContext creates a new context for use in the EVM.
 func NewEVMBlockContext(header *types.Header, chain ChainContext, author *common.Address) vm.BlockContext {
 	var (
@@ -52,15 +60,16 @@ func NewEVMBlockContext(header *types.Header, chain ChainContext, author *common
 		baseFee = new(big.Int).Set(header.BaseFee)
 	}
 	return vm.BlockContext{
-		CanTransfer: CanTransfer,
-		Transfer:    Transfer,
-		GetHash:     GetHashFn(header, chain),
-		Coinbase:    beneficiary,
-		BlockNumber: new(big.Int).Set(header.Number),
-		Time:        new(big.Int).SetUint64(header.Time),
-		Difficulty:  new(big.Int).Set(header.Difficulty),
-		BaseFee:     baseFee,
-		GasLimit:    header.GasLimit,
+		CanTransfer:  CanTransfer,
+		Transfer:     Transfer,
+		GetHash:      GetHashFn(header, chain),
+		GetBtcHeader: GetBtcHeaderFn(header, chain),
+		Coinbase:     beneficiary,
+		BlockNumber:  new(big.Int).Set(header.Number),
+		Time:         new(big.Int).SetUint64(header.Time),
+		Difficulty:   new(big.Int).Set(header.Difficulty),
+		BaseFee:      baseFee,
+		GasLimit:     header.GasLimit,
 	}
 }
 
@@ -106,6 +115,59 @@ func GetHashFn(ref *types.Header, chain ChainContext) func(n uint64) common.Hash
 	}
 }
 
+// GetBtcHeaderFn returns a GetBtcHeaderFunc which retrieves the raw BTC block
+// headers that ref and its ancestors were translated from. The BTC block hash
+// of every bevm block is carried in its uncle hash, so the stored headers are
+// checked against it before being handed out.
+func GetBtcHeaderFn(ref *types.Header, chain ChainContext) func(n uint64) []byte {
+	reader, ok := chain.(BtcHeaderReader)
+	if !ok {
+		return func(n uint64) []byte { return nil }
+	}
+	// Cache will initially contain [ref.btcHash],
+	// Then fill up with [ref.p.btcHash, ref.pp.btcHash, ...]
+	var (
+		cache []common.Hash
+		last  = ref
+	)
+	return func(n uint64) []byte {
+		if n > ref.Number.Uint64() {
+			return nil
+		}
+		if len(cache) == 0 {
+			cache = append(cache, ref.UncleHash)
+		}
+		idx := ref.Number.Uint64() - n
+		for uint64(len(cache)) <= idx {
+			if last.Number.Sign() == 0 {
+				return nil
+			}
+			parent := chain.GetHeader(last.ParentHash, last.Number.Uint64()-1)
+			if parent == nil {
+				return nil
+			}
+			cache = append(cache, parent.UncleHash)
+			last = parent
+		}
+		header := reader.GetBtcHeader(cache[idx])
+		if btcHeaderHash(header) != cache[idx] {
+			return nil
+		}
+		return header
+	}
+}
+
+// btcHeaderHash computes the BTC block hash of a raw header, in the reversed
+// byte order used for the uncle hash of bevm blocks.
+func btcHeaderHash(header []byte) common.Hash {
+	first := sha256.Sum256(header)
+	hash := sha256.Sum256(first[:])
+	for i := 0; i < len(hash)/2; i++ {
+		hash[i], hash[len(hash)-1-i] = hash[len(hash)-1-i], hash[i]
+	}
+	return common.Hash(hash)
+}
+
 // CanTransfer checks whether there are enough funds in the address' account to make a transfer.
 // This does not take the necessary gas in to account to make the transfer valid.
 func CanTransfer(db vm.StateDB, addr common.Address, amount *big.Int) bool {
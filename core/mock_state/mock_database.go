@@ -37,6 +37,7 @@ func (m *MockDatabase) OpenStorageTrie(addrHash, root common.Hash) (state.Trie,
 		return nil, err
 	}
 	mockTrie := NewMockSecureTrie(tr, root)
+	mockTrie.Owner = addrHash
 	m.usedTries[mockTrie] = struct{}{}
 	return mockTrie, nil
 }
@@ -79,6 +80,31 @@ func (db *SimpleHashSet) Delete(key []byte) error {
 	return nil
 }
 
+// ReadKeys returns the keys read from the account trie and from the storage
+// tries, grouped by the hash of the owning account.
+func (m *MockDatabase) ReadKeys() ([][]byte, map[common.Hash][][]byte) {
+	var (
+		accounts [][]byte
+		storage  = make(map[common.Hash][][]byte)
+		seen     = make(map[string]struct{})
+	)
+	for t := range m.usedTries {
+		for keyStr := range t.ReadStorageKey {
+			id := string(t.Owner[:]) + keyStr
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			if t.Owner == (common.Hash{}) {
+				accounts = append(accounts, []byte(keyStr))
+			} else {
+				storage[t.Owner] = append(storage[t.Owner], []byte(keyStr))
+			}
+		}
+	}
+	return accounts, storage
+}
+
 func (m *MockDatabase) GetReadStorageKeyProof() ([][]byte, error) {
 	nodeSet := &SimpleHashSet{nodes: make(map[string][]byte, 0)}
 	for t := range m.usedTries {
@@ -8,6 +8,7 @@ import (
 type MockSecureTrie struct {
 	state.Trie
 	RootHash       common.Hash
+	Owner          common.Hash // hash of the account owning a storage trie, zero for the account trie
 	ReadStorageKey map[string]struct{}
 }
 
@@ -0,0 +1,94 @@
+// Copyright 2023 The Goshen network Authors
+
+package rawdb
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ReadBtcHeader retrieves the raw 80-byte BTC block header with the given
+// (EVM byte order) BTC block hash.
+func ReadBtcHeader(db ethdb.KeyValueReader, hash common.Hash) []byte {
+	data, _ := db.Get(btcHeaderKey(hash))
+	return data
+}
+
+// HasBtcHeader checks if the BTC block header with the given hash is present.
+func HasBtcHeader(db ethdb.KeyValueReader, hash common.Hash) bool {
+	has, _ := db.Has(btcHeaderKey(hash))
+	return has
+}
+
+// WriteBtcHeader stores the raw BTC block header under its (EVM byte order)
+// BTC block hash.
+func WriteBtcHeader(db ethdb.KeyValueWriter, hash common.Hash, header []byte) {
+	if err := db.Put(btcHeaderKey(hash), header); err != nil {
+		log.Crit("Failed to store btc header", "err", err)
+	}
+}
+
+// DeleteBtcHeader removes the BTC block header with the given hash.
+func DeleteBtcHeader(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Delete(btcHeaderKey(hash)); err != nil {
+		log.Crit("Failed to delete btc header", "err", err)
+	}
+}
+
+// ReadBtcFees retrieves the encoded fees paid by the BTC transactions carrying
+// the invocations of the bevm block with the given hash.
+func ReadBtcFees(db ethdb.KeyValueReader, hash common.Hash) []byte {
+	data, _ := db.Get(btcFeesKey(hash))
+	return data
+}
+
+// WriteBtcFees stores the encoded fees paid by the BTC transactions carrying
+// the invocations of the bevm block with the given hash.
+func WriteBtcFees(db ethdb.KeyValueWriter, hash common.Hash, fees []byte) {
+	if err := db.Put(btcFeesKey(hash), fees); err != nil {
+		log.Crit("Failed to store btc fees", "err", err)
+	}
+}
+
+// DeleteBtcFees removes the BTC fees of the bevm block with the given hash.
+func DeleteBtcFees(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Delete(btcFeesKey(hash)); err != nil {
+		log.Crit("Failed to delete btc fees", "err", err)
+	}
+}
+
+// ReadLatestCheckpoint retrieves the encoded latest BTC checkpoint of the chain.
+func ReadLatestCheckpoint(db ethdb.KeyValueReader) []byte {
+	data, _ := db.Get(latestCheckpointKey)
+	return data
+}
+
+// WriteLatestCheckpoint stores the encoded latest BTC checkpoint of the chain.
+func WriteLatestCheckpoint(db ethdb.KeyValueWriter, checkpoint []byte) {
+	if err := db.Put(latestCheckpointKey, checkpoint); err != nil {
+		log.Crit("Failed to store the latest checkpoint", "err", err)
+	}
+}
+
+// DeleteLatestCheckpoint removes the latest BTC checkpoint of the chain.
+func DeleteLatestCheckpoint(db ethdb.KeyValueWriter) {
+	if err := db.Delete(latestCheckpointKey); err != nil {
+		log.Crit("Failed to delete the latest checkpoint", "err", err)
+	}
+}
+
+// ReadMmrNode retrieves the node of the cross layer message MMR stored at the
+// given position, or the zero hash if it is missing.
+func ReadMmrNode(db ethdb.KeyValueReader, pos uint64) common.Hash {
+	data, _ := db.Get(mmrNodeKey(pos))
+	return common.BytesToHash(data)
+}
+
+// WriteMmrNode stores a node of the cross layer message MMR at the given
+// position.
+func WriteMmrNode(db ethdb.KeyValueWriter, pos uint64, node common.Hash) {
+	if err := db.Put(mmrNodeKey(pos), node.Bytes()); err != nil {
+		log.Crit("Failed to store mmr node", "err", err)
+	}
+}
@@ -322,6 +322,9 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		preimages       stat
 		bloomBits       stat
 		cliqueSnaps     stat
+		btcHeaders      stat
+		btcFees         stat
+		mmrNodes        stat
 
 		// Ancient store statistics
 		ancientHeadersSize  common.StorageSize
@@ -381,6 +384,12 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			bloomBits.Add(size)
 		case bytes.HasPrefix(key, []byte("clique-")) && len(key) == 7+common.HashLength:
 			cliqueSnaps.Add(size)
+		case bytes.HasPrefix(key, btcHeaderPrefix) && len(key) == (len(btcHeaderPrefix)+common.HashLength):
+			btcHeaders.Add(size)
+		case bytes.HasPrefix(key, btcFeesPrefix) && len(key) == (len(btcFeesPrefix)+common.HashLength):
+			btcFees.Add(size)
+		case bytes.HasPrefix(key, mmrNodePrefix) && len(key) == (len(mmrNodePrefix)+8):
+			mmrNodes.Add(size)
 		case bytes.HasPrefix(key, []byte("cht-")) ||
 			bytes.HasPrefix(key, []byte("chtIndexV2-")) ||
 			bytes.HasPrefix(key, []byte("chtRootV2-")): // Canonical hash trie
@@ -395,7 +404,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 				databaseVersionKey, headHeaderKey, headBlockKey, headFastBlockKey, lastPivotKey,
 				fastTrieProgressKey, snapshotDisabledKey, SnapshotRootKey, snapshotJournalKey,
 				snapshotGeneratorKey, snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey,
-				uncleanShutdownKey, badBlockKey,
+				uncleanShutdownKey, badBlockKey, latestCheckpointKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -442,6 +451,9 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Account snapshot", accountSnaps.Size(), accountSnaps.Count()},
 		{"Key-Value store", "Storage snapshot", storageSnaps.Size(), storageSnaps.Count()},
 		{"Key-Value store", "Clique snapshots", cliqueSnaps.Size(), cliqueSnaps.Count()},
+		{"Key-Value store", "BTC headers", btcHeaders.Size(), btcHeaders.Count()},
+		{"Key-Value store", "BTC fees", btcFees.Size(), btcFees.Count()},
+		{"Key-Value store", "Message MMR nodes", mmrNodes.Size(), mmrNodes.Count()},
 		{"Key-Value store", "Singleton metadata", metadata.Size(), metadata.Count()},
 		{"Ancient store", "Headers", ancientHeadersSize.String(), ancients.String()},
 		{"Ancient store", "Bodies", ancientBodiesSize.String(), ancients.String()},
@@ -75,6 +75,9 @@ var (
 	// uncleanShutdownKey tracks the list of local crashes
 	uncleanShutdownKey = []byte("unclean-shutdown") // config prefix for the db
 
+	// latestCheckpointKey tracks the latest BTC checkpoint of the chain seen by the translator.
+	latestCheckpointKey = []byte("bevm-latest-checkpoint")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -93,6 +96,10 @@ var (
 	PreimagePrefix = []byte("secure-key-")      // PreimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
+	btcHeaderPrefix = []byte("bevm-btc-header-") // btcHeaderPrefix + btc block hash -> raw btc block header
+	btcFeesPrefix   = []byte("bevm-btc-fees-")   // btcFeesPrefix + block hash -> fees of the btc transactions carrying the block
+	mmrNodePrefix   = []byte("bevm-mmr-node-")   // mmrNodePrefix + position (uint64 big endian) -> cross layer message MMR node
+
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 
@@ -226,6 +233,21 @@ func IsCodeKey(key []byte) (bool, []byte) {
 	return false, nil
 }
 
+// btcHeaderKey = btcHeaderPrefix + hash
+func btcHeaderKey(hash common.Hash) []byte {
+	return append(btcHeaderPrefix, hash.Bytes()...)
+}
+
+// btcFeesKey = btcFeesPrefix + hash
+func btcFeesKey(hash common.Hash) []byte {
+	return append(btcFeesPrefix, hash.Bytes()...)
+}
+
+// mmrNodeKey = mmrNodePrefix + pos (uint64 big endian)
+func mmrNodeKey(pos uint64) []byte {
+	return append(mmrNodePrefix, encodeBlockNumber(pos)...)
+}
+
 // configKey = configPrefix + hash
 func configKey(hash common.Hash) []byte {
 	return append(configPrefix, hash.Bytes()...)
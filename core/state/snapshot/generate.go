@@ -203,6 +203,15 @@ func journalProgress(db ethdb.KeyValueWriter, marker []byte, stats *generatorSta
 	rawdb.WriteSnapshotGenerator(db, blob)
 }
 
+// MarkGenerated records the flat state written to db as a completely generated
+// disk layer with the given root, as after importing it from elsewhere. Any
+// previous diff journal is discarded.
+func MarkGenerated(db ethdb.KeyValueWriter, root common.Hash) {
+	rawdb.WriteSnapshotRoot(db, root)
+	rawdb.DeleteSnapshotJournal(db)
+	journalProgress(db, nil, nil)
+}
+
 // proofResult contains the output of range proving which can be used
 // for further processing regardless if it is successful or not.
 type proofResult struct {
@@ -98,9 +98,20 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainCon
 	evm.Reset(txContext, statedb)
 
 	// Apply the transaction to the current state (included in the env).
+	var (
+		snapshot = statedb.Snapshot()
+		gasLeft  = gp.Gas()
+	)
 	result, err := ApplyMessage(evm, msg, gp)
 	if err != nil {
-		return nil, err
+		if !tx.IsRelayed() {
+			return nil, err
+		}
+		// Relayed transactions are included by btc miners unaware of the state,
+		// an invalid one (e.g. a replayed nonce) only gets a failed receipt.
+		statedb.RevertToSnapshot(snapshot)
+		*gp = GasPool(gasLeft)
+		result, err = &ExecutionResult{Err: err}, nil
 	}
 
 	// Update the state with pending changes.
@@ -79,6 +79,7 @@ type Message interface {
 
 	Nonce() uint64
 	IsFake() bool
+	IsEnqueued() bool
 	Data() []byte
 	AccessList() types.AccessList
 }
@@ -219,7 +220,7 @@ func (st *StateTransition) preCheck() error {
 	// Only check transactions that are not fake
 	if !st.msg.IsFake() {
 		//maybe use param in chainConfig
-		if st.msg.Nonce() >= consts.InitialEnqueueNonceNonce { //l1 queue tx already checked in l1 contracats
+		if st.msg.IsEnqueued() { // authenticated by the btc script spend
 			log.Info("state transition skipping nonce check with bevm tx")
 		} else {
 			// Make sure this transaction's nonce is correct.
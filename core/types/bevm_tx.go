@@ -18,6 +18,18 @@ type BevmTx struct {
 	Data    []byte          // contract invocation input data
 	RefHash [32]byte        // btc transaction hash
 	Index   uint64          // index in the btc transaction
+
+	Relayed *BevmRelay `rlp:"optional"` // signed Ethereum transaction relayed by the btc transaction
+}
+
+// BevmRelay holds the fields of a signed Ethereum transaction relayed inside a
+// btc transaction. From of the enclosing BevmTx is the recovered signer, and the
+// nonce is checked against the sender account like any Ethereum transaction.
+type BevmRelay struct {
+	Nonce uint64
+	Gas   uint64
+	Value *big.Int
+	Raw   []byte // canonical encoding of the signed transaction
 }
 
 // copy creates a deep copy of the transaction data and initializes all fields.
@@ -29,6 +41,17 @@ func (tx *BevmTx) copy() TxData {
 		RefHash: tx.RefHash,
 		Index:   tx.Index,
 	}
+	if tx.Relayed != nil {
+		cpy.Relayed = &BevmRelay{
+			Nonce: tx.Relayed.Nonce,
+			Gas:   tx.Relayed.Gas,
+			Value: new(big.Int),
+			Raw:   common.CopyBytes(tx.Relayed.Raw),
+		}
+		if tx.Relayed.Value != nil {
+			cpy.Relayed.Value.Set(tx.Relayed.Value)
+		}
+	}
 	return cpy
 }
 
@@ -37,14 +60,32 @@ func (tx *BevmTx) txType() byte           { return LegacyTxType }
 func (tx *BevmTx) chainID() *big.Int      { return big.NewInt(0) }
 func (tx *BevmTx) accessList() AccessList { return nil }
 func (tx *BevmTx) data() []byte           { return tx.Data }
-func (tx *BevmTx) gas() uint64            { return MaxGas }
 func (tx *BevmTx) gasPrice() *big.Int     { return big.NewInt(0) }
 func (tx *BevmTx) gasTipCap() *big.Int    { return big.NewInt(0) }
 func (tx *BevmTx) gasFeeCap() *big.Int    { return big.NewInt(0) }
-func (tx *BevmTx) value() *big.Int        { return big.NewInt(0) }
-func (tx *BevmTx) nonce() uint64          { return consts.InitialEnqueueNonceNonce }
 func (tx *BevmTx) to() *common.Address    { return tx.To }
 
+func (tx *BevmTx) gas() uint64 {
+	if tx.Relayed != nil && tx.Relayed.Gas < MaxGas {
+		return tx.Relayed.Gas
+	}
+	return MaxGas
+}
+
+func (tx *BevmTx) value() *big.Int {
+	if tx.Relayed != nil && tx.Relayed.Value != nil {
+		return tx.Relayed.Value
+	}
+	return big.NewInt(0)
+}
+
+func (tx *BevmTx) nonce() uint64 {
+	if tx.Relayed != nil {
+		return tx.Relayed.Nonce
+	}
+	return consts.InitialEnqueueNonceNonce
+}
+
 func (tx *BevmTx) rawSignatureValues() (v, r, s *big.Int) {
 	return big.NewInt(0), big.NewInt(0), big.NewInt(0)
 }
@@ -0,0 +1,49 @@
+// Copyright 2023 The Goshen network Authors
+
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/consts"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestBevmTxRLP(t *testing.T) {
+	to := common.HexToAddress("0x1234")
+	plain := &BevmTx{From: common.HexToAddress("0xabcd"), To: &to, Data: []byte{1, 2}, RefHash: [32]byte{3}, Index: 1}
+	relayed := plain.copy().(*BevmTx)
+	relayed.Relayed = &BevmRelay{Nonce: 7, Gas: 21000, Value: big.NewInt(5), Raw: []byte{0xf8}}
+
+	// a relay must not change the encoding of plain transactions
+	enc, _ := rlp.EncodeToBytes(plain)
+	legacy, _ := rlp.EncodeToBytes([]interface{}{plain.From, plain.To, plain.Data, plain.RefHash, plain.Index})
+	if string(enc) != string(legacy) {
+		t.Fatalf("plain encoding changed: %x != %x", enc, legacy)
+	}
+	for _, inner := range []*BevmTx{plain, relayed} {
+		tx := NewTx(inner)
+		enc, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dec Transaction
+		if err := rlp.DecodeBytes(enc, &dec); err != nil {
+			t.Fatalf("failed to decode: %v", err)
+		}
+		if dec.Hash() != tx.Hash() || dec.Nonce() != tx.Nonce() || dec.IsRelayed() != (inner.Relayed != nil) {
+			t.Fatalf("decoded transaction mismatch")
+		}
+		if from, err := Sender(HomesteadSigner{}, &dec); err != nil || from != inner.From {
+			t.Fatalf("sender mismatch: have %x, want %x (err %v)", from, inner.From, err)
+		}
+	}
+	if n := NewTx(plain).Nonce(); n != consts.InitialEnqueueNonceNonce {
+		t.Errorf("plain nonce mismatch: have %d", n)
+	}
+	if tx := NewTx(relayed); tx.Nonce() != 7 || tx.Gas() != 21000 || tx.Value().Int64() != 5 {
+		t.Errorf("relayed fields mismatch: nonce %d gas %d value %v", tx.Nonce(), tx.Gas(), tx.Value())
+	}
+}
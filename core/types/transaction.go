@@ -413,6 +413,30 @@ func (tx *Transaction) IsQueue() bool {
 	return tx.Nonce() >= consts.InitialEnqueueNonceNonce
 }
 
+// IsEnqueued reports whether tx is an invocation enqueued by a btc script spend.
+// Its sender is authenticated by the btc transaction instead of a nonce.
+func (tx *Transaction) IsEnqueued() bool {
+	inner, ok := tx.inner.(*BevmTx)
+	return ok && inner.Relayed == nil
+}
+
+// IsRelayed reports whether tx is a signed Ethereum transaction relayed inside
+// a btc transaction.
+func (tx *Transaction) IsRelayed() bool {
+	inner, ok := tx.inner.(*BevmTx)
+	return ok && inner.Relayed != nil
+}
+
+// BtcRef returns the hash of the btc transaction carrying tx and the index of
+// the invocation within it. ok is false if tx was not derived from btc.
+func (tx *Transaction) BtcRef() (hash common.Hash, index uint64, ok bool) {
+	inner, ok := tx.inner.(*BevmTx)
+	if !ok {
+		return common.Hash{}, 0, false
+	}
+	return inner.RefHash, inner.Index, true
+}
+
 // Transactions implements DerivableList for transactions.
 type Transactions []*Transaction
 
@@ -589,6 +613,7 @@ type Message struct {
 	data       []byte
 	accessList AccessList
 	isFake     bool
+	isEnqueued bool
 }
 
 func NewMessage(from common.Address, to *common.Address, nonce uint64, amount *big.Int, gasLimit uint64, gasPrice, gasFeeCap, gasTipCap *big.Int, data []byte, accessList AccessList, isFake bool) Message {
@@ -620,6 +645,7 @@ func (tx *Transaction) AsMessage(s Signer, baseFee *big.Int) (Message, error) {
 		data:       tx.Data(),
 		accessList: tx.AccessList(),
 		isFake:     false,
+		isEnqueued: tx.IsEnqueued(),
 	}
 	// If baseFee provided, set gasPrice to effectiveGasPrice.
 	if baseFee != nil {
@@ -641,6 +667,7 @@ func (m Message) Nonce() uint64          { return m.nonce }
 func (m Message) Data() []byte           { return m.data }
 func (m Message) AccessList() AccessList { return m.accessList }
 func (m Message) IsFake() bool           { return m.isFake }
+func (m Message) IsEnqueued() bool       { return m.isEnqueued }
 
 // copyAddressPtr copies an address.
 func copyAddressPtr(a *common.Address) *common.Address {
@@ -139,7 +139,7 @@ func Sender(signer Signer, tx *Transaction) (common.Address, error) {
 		}
 	}
 
-	if inner := tx.inner.(*BevmTx); inner != nil {
+	if inner, ok := tx.inner.(*BevmTx); ok {
 		tx.from.Store(sigCache{signer: signer, from: inner.From})
 		return inner.From, nil
 	}
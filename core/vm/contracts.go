@@ -111,6 +111,7 @@ var (
 	PrecompiledAddressesIstanbul  []common.Address
 	PrecompiledAddressesByzantium []common.Address
 	PrecompiledAddressesHomestead []common.Address
+	PrecompiledAddressesBevm      []common.Address
 )
 
 func init() {
@@ -126,20 +127,28 @@ func init() {
 	for k := range PrecompiledContractsBerlin {
 		PrecompiledAddressesBerlin = append(PrecompiledAddressesBerlin, k)
 	}
+	for k := range PrecompiledContractsBevm {
+		PrecompiledAddressesBevm = append(PrecompiledAddressesBevm, k)
+	}
 }
 
 // ActivePrecompiles returns the precompiles enabled with the current configuration.
 func ActivePrecompiles(rules params.Rules) []common.Address {
+	var active []common.Address
 	switch {
 	case rules.IsBerlin:
-		return PrecompiledAddressesBerlin
+		active = PrecompiledAddressesBerlin
 	case rules.IsIstanbul:
-		return PrecompiledAddressesIstanbul
+		active = PrecompiledAddressesIstanbul
 	case rules.IsByzantium:
-		return PrecompiledAddressesByzantium
+		active = PrecompiledAddressesByzantium
 	default:
-		return PrecompiledAddressesHomestead
+		active = PrecompiledAddressesHomestead
+	}
+	if rules.IsBevm {
+		active = append(append([]common.Address{}, active...), PrecompiledAddressesBevm...)
 	}
+	return active
 }
 
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
@@ -0,0 +1,221 @@
+// Copyright 2023 The Goshen network Authors
+
+package vm
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// PrecompiledContractsBevm contains the bevm specific pre-compiled contracts,
+// available on top of the regular set once the bevm fork is active.
+var PrecompiledContractsBevm = map[common.Address]PrecompiledContract{
+	common.BytesToAddress([]byte{1, 0}): &btcHeader{},
+	common.BytesToAddress([]byte{1, 1}): &btcMerkleProof{},
+	common.BytesToAddress([]byte{1, 2}): &schnorrVerify{},
+	common.BytesToAddress([]byte{1, 3}): &taprootOutputKey{},
+}
+
+var (
+	errBtcHeaderUnavailable = errors.New("btc header unavailable")
+	errBtcMerkleProofLength = errors.New("invalid btc merkle proof length")
+	errBtcMerkleProofIndex  = errors.New("btc merkle proof index out of range")
+	errSchnorrInputLength   = errors.New("invalid schnorr verify input length")
+	errTaprootInputLength   = errors.New("invalid taproot output key input length")
+	errTaprootInternalKey   = errors.New("invalid taproot internal key")
+)
+
+// contextualPrecompile is implemented by precompiles which need read access to
+// the block context they are executed in.
+type contextualPrecompile interface {
+	PrecompiledContract
+
+	// withContext returns a copy of the contract bound to the given context.
+	withContext(ctx *BlockContext) PrecompiledContract
+}
+
+// btcHeaderAt returns the raw BTC header of the given height, provided it lies
+// within the BtcHeaderWindow most recent heights of the current block.
+func btcHeaderAt(ctx *BlockContext, height *big.Int) ([]byte, error) {
+	if ctx == nil || ctx.GetBtcHeader == nil || !height.IsUint64() {
+		return nil, errBtcHeaderUnavailable
+	}
+	var (
+		current = ctx.BlockNumber.Uint64()
+		n       = height.Uint64()
+	)
+	if n > current || current-n > params.BtcHeaderWindow {
+		return nil, errBtcHeaderUnavailable
+	}
+	header := ctx.GetBtcHeader(n)
+	if len(header) != btcHeaderSize {
+		return nil, errBtcHeaderUnavailable
+	}
+	return header, nil
+}
+
+const btcHeaderSize = 80
+
+// btcHeader returns the raw 80-byte BTC block header that the bevm block of the
+// requested height was translated from.
+//
+// Input is the 32-byte big endian height; the current block and the
+// BtcHeaderWindow blocks before it may be queried.
+type btcHeader struct {
+	ctx *BlockContext
+}
+
+func (c *btcHeader) withContext(ctx *BlockContext) PrecompiledContract {
+	return &btcHeader{ctx: ctx}
+}
+
+func (c *btcHeader) RequiredGas(input []byte) uint64 {
+	return params.BtcHeaderGas
+}
+
+func (c *btcHeader) Run(input []byte) ([]byte, error) {
+	height := new(big.Int).SetBytes(getData(input, 0, 32))
+	header, err := btcHeaderAt(c.ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	return common.CopyBytes(header), nil
+}
+
+// btcMerkleProof verifies that a BTC transaction is included in the block of a
+// relayed BTC header.
+//
+// Input is laid out as height (32 bytes) | txid (32 bytes) | index (32 bytes) |
+// siblings (32 bytes each, leaf first). The txid and siblings are in internal
+// byte order, i.e. the raw double-sha256 output as used inside the merkle tree.
+// Output is a 32-byte word that is 1 if the proof is valid and 0 otherwise.
+//
+// Note a 64-byte transaction can be passed off as an inner merkle node, so
+// callers proving payments should also check the transaction itself.
+type btcMerkleProof struct {
+	ctx *BlockContext
+}
+
+func (c *btcMerkleProof) withContext(ctx *BlockContext) PrecompiledContract {
+	return &btcMerkleProof{ctx: ctx}
+}
+
+func (c *btcMerkleProof) RequiredGas(input []byte) uint64 {
+	var levels uint64
+	if len(input) > 96 {
+		levels = uint64(len(input)-96+31) / 32
+	}
+	return params.BtcMerkleProofBaseGas + levels*params.BtcMerkleProofLevelGas
+}
+
+func (c *btcMerkleProof) Run(input []byte) ([]byte, error) {
+	if len(input) < 96 || (len(input)-96)%32 != 0 {
+		return nil, errBtcMerkleProofLength
+	}
+	var (
+		height   = new(big.Int).SetBytes(input[:32])
+		index    = new(big.Int).SetBytes(input[64:96])
+		siblings = input[96:]
+		levels   = len(siblings) / 32
+	)
+	if levels > 32 || index.BitLen() > levels {
+		return nil, errBtcMerkleProofIndex
+	}
+	header, err := btcHeaderAt(c.ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		node = common.CopyBytes(input[32:64])
+		pos  = index.Uint64()
+	)
+	for i := 0; i < levels; i++ {
+		sibling := siblings[i*32 : (i+1)*32]
+		if pos&1 == 0 {
+			node = btcDoubleSha256(node, sibling)
+		} else {
+			node = btcDoubleSha256(sibling, node)
+		}
+		pos >>= 1
+	}
+	// The merkle root lives at offset 36 of the header, after the version and
+	// the previous block hash.
+	if common.BytesToHash(node) != common.BytesToHash(header[36:68]) {
+		return common.LeftPadBytes(nil, 32), nil
+	}
+	return common.LeftPadBytes([]byte{1}, 32), nil
+}
+
+func btcDoubleSha256(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write(left)
+	h.Write(right)
+	first := h.Sum(nil)
+	second := sha256.Sum256(first)
+	return second[:]
+}
+
+// schnorrVerify verifies a BIP-340 schnorr signature.
+//
+// Input is laid out as x-only public key (32 bytes) | message (32 bytes) |
+// signature (64 bytes). Output is a 32-byte word that is 1 if the signature is
+// valid and 0 otherwise, malformed keys and signatures being merely invalid.
+type schnorrVerify struct{}
+
+func (c *schnorrVerify) RequiredGas(input []byte) uint64 {
+	return params.SchnorrVerifyGas
+}
+
+func (c *schnorrVerify) Run(input []byte) ([]byte, error) {
+	if len(input) != 128 {
+		return nil, errSchnorrInputLength
+	}
+	invalid := common.LeftPadBytes(nil, 32)
+	pubKey, err := schnorr.ParsePubKey(input[:32])
+	if err != nil {
+		return invalid, nil
+	}
+	sig, err := schnorr.ParseSignature(input[64:128])
+	if err != nil {
+		return invalid, nil
+	}
+	if !sig.Verify(input[32:64], pubKey) {
+		return invalid, nil
+	}
+	return common.LeftPadBytes([]byte{1}, 32), nil
+}
+
+// taprootOutputKey tweaks a taproot internal key into its BIP-341 output key.
+//
+// Input is the x-only internal key (32 bytes), optionally followed by the
+// script tree merkle root (32 bytes); without a root the key is tweaked as a
+// key-path only output (BIP-86). Output is the x-only output key (32 bytes)
+// followed by a 32-byte word holding the parity of its y coordinate.
+type taprootOutputKey struct{}
+
+func (c *taprootOutputKey) RequiredGas(input []byte) uint64 {
+	return params.TaprootOutputKeyGas
+}
+
+func (c *taprootOutputKey) Run(input []byte) ([]byte, error) {
+	if len(input) != 32 && len(input) != 64 {
+		return nil, errTaprootInputLength
+	}
+	internalKey, err := schnorr.ParsePubKey(input[:32])
+	if err != nil {
+		return nil, errTaprootInternalKey
+	}
+	outputKey := txscript.ComputeTaprootOutputKey(internalKey, input[32:])
+	compressed := outputKey.SerializeCompressed()
+
+	output := make([]byte, 64)
+	copy(output, compressed[1:])
+	output[63] = compressed[0] & 1
+	return output, nil
+}
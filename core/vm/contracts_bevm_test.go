@@ -0,0 +1,178 @@
+// Copyright 2023 The Goshen network Authors
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// btcGenesisHeader is the raw header of the BTC mainnet genesis block.
+var btcGenesisHeader = common.Hex2Bytes("0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c")
+
+func btcTestContext(number uint64, headers map[uint64][]byte) *BlockContext {
+	return &BlockContext{
+		BlockNumber:  new(big.Int).SetUint64(number),
+		GetBtcHeader: func(n uint64) []byte { return headers[n] },
+	}
+}
+
+func TestBtcHeaderPrecompile(t *testing.T) {
+	ctx := btcTestContext(params.BtcHeaderWindow+1, map[uint64][]byte{
+		0:                          btcGenesisHeader,
+		1:                          btcGenesisHeader,
+		params.BtcHeaderWindow + 1: btcGenesisHeader,
+	})
+	p := new(btcHeader).withContext(ctx)
+
+	for _, test := range []struct {
+		height uint64
+		ok     bool
+	}{
+		{0, false}, // out of window
+		{1, true},  // oldest reachable
+		{2, false}, // unknown header
+		{params.BtcHeaderWindow + 1, true},
+		{params.BtcHeaderWindow + 2, false}, // future
+	} {
+		in := common.LeftPadBytes(new(big.Int).SetUint64(test.height).Bytes(), 32)
+		res, _, err := RunPrecompiledContract(p, in, params.BtcHeaderGas)
+		if test.ok {
+			if err != nil {
+				t.Errorf("height %d: unexpected error: %v", test.height, err)
+			} else if !bytes.Equal(res, btcGenesisHeader) {
+				t.Errorf("height %d: header mismatch: have %x", test.height, res)
+			}
+		} else if err != errBtcHeaderUnavailable {
+			t.Errorf("height %d: expected %v, got %v", test.height, errBtcHeaderUnavailable, err)
+		}
+	}
+}
+
+func TestBtcMerkleProofPrecompile(t *testing.T) {
+	// Assemble a block with four transactions and plant its merkle root in a
+	// copy of the genesis header.
+	var leaves [][]byte
+	for i := byte(0); i < 4; i++ {
+		leaves = append(leaves, bytes.Repeat([]byte{i + 1}, 32))
+	}
+	left := btcDoubleSha256(leaves[0], leaves[1])
+	right := btcDoubleSha256(leaves[2], leaves[3])
+	root := btcDoubleSha256(left, right)
+
+	header := common.CopyBytes(btcGenesisHeader)
+	copy(header[36:68], root)
+	p := new(btcMerkleProof).withContext(btcTestContext(10, map[uint64][]byte{10: header}))
+
+	proof := func(height, index uint64, leaf []byte, siblings ...[]byte) []byte {
+		in := common.LeftPadBytes(new(big.Int).SetUint64(height).Bytes(), 32)
+		in = append(in, leaf...)
+		in = append(in, common.LeftPadBytes(new(big.Int).SetUint64(index).Bytes(), 32)...)
+		for _, sibling := range siblings {
+			in = append(in, sibling...)
+		}
+		return in
+	}
+	var (
+		valid   = common.LeftPadBytes([]byte{1}, 32)
+		invalid = make([]byte, 32)
+	)
+	for i, test := range []struct {
+		input []byte
+		want  []byte
+		err   error
+	}{
+		{proof(10, 2, leaves[2], leaves[3], left), valid, nil},
+		{proof(10, 1, leaves[1], leaves[0], right), valid, nil},
+		{proof(10, 3, leaves[2], leaves[3], left), invalid, nil},
+		{proof(10, 2, leaves[0], leaves[3], left), invalid, nil},
+		{proof(10, 4, leaves[2], leaves[3], left), nil, errBtcMerkleProofIndex},
+		{proof(9, 2, leaves[2], leaves[3], left), nil, errBtcHeaderUnavailable},
+		{proof(10, 2, leaves[2], leaves[3], left)[:150], nil, errBtcMerkleProofLength},
+	} {
+		gas := p.RequiredGas(test.input)
+		res, _, err := RunPrecompiledContract(p, test.input, gas)
+		if err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+		if !bytes.Equal(res, test.want) {
+			t.Errorf("test %d: result mismatch: have %x, want %x", i, res, test.want)
+		}
+	}
+	if gas := p.RequiredGas(proof(10, 2, leaves[2], leaves[3], left)); gas != params.BtcMerkleProofBaseGas+2*params.BtcMerkleProofLevelGas {
+		t.Errorf("gas mismatch: have %d", gas)
+	}
+}
+
+// BIP-340 test vectors, see https://github.com/bitcoin/bips/blob/master/bip-0340/test-vectors.csv
+var schnorrVerifyTests = []struct {
+	pubKey, message, signature string
+	valid                      bool
+}{
+	{"F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9", "0000000000000000000000000000000000000000000000000000000000000000", "E907831F80848D1069A5371B402410364BDF1C5F8307B0084C55F1CE2DCA821525F66A4A85EA8B71E482A74F382D2CE5EBEEE8FDB2172F477DF4900D310536C0", true},
+	{"DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659", "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89", "6896BD60EEAE296DB48A229FF71DFE071BDE413E6D43F917DC8DCF8C78DE33418906D11AC976ABCCB20B091292BFF4EA897EFCB639EA871CFA95F6DE339E4B0A", true},
+	{"DD308AFEC5777E13121FA72B9CC1B7CC0139715309B086C960E18FD969774EB8", "7E2D58D8B3BCDF1ABADEC7829054F90DDA9805AAB56C77333024B9D0A508B75C", "5831AAEED7B44BB74E5EAB94BA9D4294C49BCF2A60728D8B4C200F50DD313C1BAB745879A5AD954A72C45A91C3A51D3C7ADEA98D82F8481E0E1E03674A6F3FB7", true},
+	{"25D1DFF95105F5253C4022F628A996AD3A0D95FBF21D468A1B33F8C160D8F517", "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF", "7EB0509757E246F19449885651611CB965ECC1A187DD51B64FDA1EDC9637D5EC97582B9CB13DB3933705B32BA982AF5AF25FD78881EBB32771FC5922EFC66EA3", true},
+	{"D69C3509BB99E412E68B0FE8544E72837DFA30746D8BE2AA65975F29D22DC7B9", "4DF3C3F68FCC83B27E9D42C90431A72499F17875C81A599B566C9889B9696703", "00000000000000000000003B78CE563F89A0ED9414F5AA28AD0D96D6795F9C6376AFB1548AF603B3EB45C9F8207DEE1060CB71C04E80F593060B07D28308D7F4", true},
+	// public key not on the curve
+	{"EEFDEA4CDB677750A420FEE807EACF21EB9898AE79B9768766E4FAA04A2D4A34", "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89", "6CFF5C3BA86C69EA4B7376F31A9BCB4F74C1976089B2D9963DA2E5543E17776969E89B4C5564D00349106B8497785DD7D1D713A8AE82B32FA79D5F7FC407D39B", false},
+	// has_even_y(R) is false
+	{"DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659", "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89", "FFF97BD5755EEEA420453A14355235D382F6472F8568A18B2F057A14602975563CC27944640AC607CD107AE10923D9EF7A73C643E166BE5EBEAFA34B1AC553E2", false},
+	// negated message
+	{"DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659", "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89", "1FA62E331EDBC21C394792D2AB1100A7B432B013DF3F6FF4F99FCB33E0E1515F28890B3EDB6E7189B630448B515CE4F8622A954CFE545735AAEA5134FCCDB2BD", false},
+	// negated s value
+	{"DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659", "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89", "6CFF5C3BA86C69EA4B7376F31A9BCB4F74C1976089B2D9963DA2E5543E177769961764B3AA9B2FFCB6EF947B6887A226E8D7C93E00C5ED0C1834FF0D0C2E6DA6", false},
+	// sG - eP is infinite
+	{"DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659", "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89", "0000000000000000000000000000000000000000000000000000000000000000123DDA8328AF9C23A94C1FEECFD123BA4FB73476F0D594DCB65C6425BD186051", false},
+	// sig[0:32] is not an X coordinate on the curve
+	{"DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659", "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89", "4A298DACAE57395A15D0795DDBFD1DCB564DA82B0F269BC70A74F8220429BA1D69E89B4C5564D00349106B8497785DD7D1D713A8AE82B32FA79D5F7FC407D39B", false},
+	// public key exceeds the field size
+	{"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC30", "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89", "6CFF5C3BA86C69EA4B7376F31A9BCB4F74C1976089B2D9963DA2E5543E17776969E89B4C5564D00349106B8497785DD7D1D713A8AE82B32FA79D5F7FC407D39B", false},
+}
+
+func TestSchnorrVerifyPrecompile(t *testing.T) {
+	p := new(schnorrVerify)
+	for i, test := range schnorrVerifyTests {
+		in := common.FromHex(test.pubKey + test.message + test.signature)
+		res, _, err := RunPrecompiledContract(p, in, params.SchnorrVerifyGas)
+		if err != nil {
+			t.Fatalf("vector %d: unexpected error: %v", i, err)
+		}
+		if valid := res[31] == 1; valid != test.valid {
+			t.Errorf("vector %d: validity mismatch: have %v, want %v", i, valid, test.valid)
+		}
+	}
+	if _, _, err := RunPrecompiledContract(p, make([]byte, 127), params.SchnorrVerifyGas); err != errSchnorrInputLength {
+		t.Errorf("short input: expected %v, got %v", errSchnorrInputLength, err)
+	}
+}
+
+func TestTaprootOutputKeyPrecompile(t *testing.T) {
+	p := new(taprootOutputKey)
+	for i, test := range []struct {
+		internalKey, merkleRoot, outputKey string
+	}{
+		// BIP-86 test vector: m/86'/0'/0'/0/0
+		{"cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115", "", "a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c"},
+		// BIP-341 wallet test vector: scriptPubKey 3
+		{"187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27", "5b75adecf53548f3ec6ad7d78383bf84cc57b55a3127c72b9a2481752dd88b21", "147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3"},
+	} {
+		res, _, err := RunPrecompiledContract(p, common.FromHex(test.internalKey+test.merkleRoot), params.TaprootOutputKeyGas)
+		if err != nil {
+			t.Fatalf("vector %d: unexpected error: %v", i, err)
+		}
+		if have := common.Bytes2Hex(res[:32]); have != test.outputKey {
+			t.Errorf("vector %d: output key mismatch: have %s, want %s", i, have, test.outputKey)
+		}
+	}
+	if _, _, err := RunPrecompiledContract(p, make([]byte, 33), params.TaprootOutputKeyGas); err != errTaprootInputLength {
+		t.Errorf("bad length: expected %v, got %v", errTaprootInputLength, err)
+	}
+	if _, _, err := RunPrecompiledContract(p, common.FromHex("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC30"), params.TaprootOutputKeyGas); err != errTaprootInternalKey {
+		t.Errorf("bad key: expected %v, got %v", errTaprootInternalKey, err)
+	}
+}
@@ -39,6 +39,9 @@ type (
 	// GetHashFunc returns the n'th block hash in the blockchain
 	// and is used by the BLOCKHASH EVM op code.
 	GetHashFunc func(uint64) common.Hash
+	// GetBtcHeaderFunc returns the raw BTC block header the n'th bevm block
+	// was translated from and is used by the bevm BTC relay precompiles.
+	GetBtcHeaderFunc func(uint64) []byte
 )
 
 func (evm *EVM) precompile(addr common.Address) (PrecompiledContract, bool) {
@@ -54,6 +57,13 @@ func (evm *EVM) precompile(addr common.Address) (PrecompiledContract, bool) {
 		precompiles = PrecompiledContractsHomestead
 	}
 	p, ok := precompiles[addr]
+	if !ok && evm.chainRules.IsBevm {
+		if p, ok = PrecompiledContractsBevm[addr]; ok {
+			if contextual, isContextual := p.(contextualPrecompile); isContextual {
+				p = contextual.withContext(&evm.Context)
+			}
+		}
+	}
 	return p, ok
 }
 
@@ -67,6 +77,8 @@ type BlockContext struct {
 	Transfer TransferFunc
 	// GetHash returns the hash corresponding to n
 	GetHash GetHashFunc
+	// GetBtcHeader returns the raw BTC block header corresponding to n
+	GetBtcHeader GetBtcHeaderFunc
 
 	// Block information
 	Coinbase    common.Address // Provides information for COINBASE
@@ -0,0 +1,412 @@
+// Copyright 2023 The Goshen network Authors
+
+// Package bevmclient provides an RPC client for the bevm-specific APIs.
+package bevmclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/bevm/protocol"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Client is a wrapper around rpc.Client that implements the bevm namespace and
+// decodes the BTC origin of bevm blocks and transactions.
+//
+// If you want to use the standardized Ethereum RPC functionality, use ethclient.Client instead.
+type Client struct {
+	c *rpc.Client
+}
+
+// Dial connects a client to the given URL.
+func Dial(rawurl string) (*Client, error) {
+	return DialContext(context.Background(), rawurl)
+}
+
+// DialContext connects a client to the given URL with context.
+func DialContext(ctx context.Context, rawurl string) (*Client, error) {
+	c, err := rpc.DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return New(c), nil
+}
+
+// New creates a client that uses the given RPC client.
+func New(c *rpc.Client) *Client {
+	return &Client{c}
+}
+
+// Close closes the underlying RPC connection.
+func (bc *Client) Close() {
+	bc.c.Close()
+}
+
+// Transaction is a bevm transaction along with its position in the chain and,
+// for invocations, the BTC transaction it was translated from.
+type Transaction struct {
+	Hash        common.Hash
+	From        common.Address
+	To          *common.Address // nil for contract creations
+	Input       []byte
+	Nonce       uint64
+	Gas         uint64
+	Value       *big.Int
+	BlockHash   common.Hash
+	BlockNumber *big.Int // nil if the transaction is not in a block
+	TxIndex     uint64   // position of the transaction in its block
+
+	RefHash *common.Hash // hash of the btc transaction carrying the invocation, nil if not derived from btc
+	Index   uint64       // index of the invocation in the btc transaction
+}
+
+// Invocation reports whether tx was derived from a BTC transaction.
+func (tx *Transaction) Invocation() bool {
+	return tx.RefHash != nil
+}
+
+type rpcTransaction struct {
+	Hash             common.Hash     `json:"hash"`
+	From             common.Address  `json:"from"`
+	To               *common.Address `json:"to"`
+	Input            hexutil.Bytes   `json:"input"`
+	Nonce            hexutil.Uint64  `json:"nonce"`
+	Gas              hexutil.Uint64  `json:"gas"`
+	Value            *hexutil.Big    `json:"value"`
+	BlockHash        *common.Hash    `json:"blockHash"`
+	BlockNumber      *hexutil.Big    `json:"blockNumber"`
+	TransactionIndex *hexutil.Uint64 `json:"transactionIndex"`
+	RefHash          *common.Hash    `json:"refHash"`
+	RefIndex         *hexutil.Uint64 `json:"refIndex"`
+}
+
+func (tx *rpcTransaction) toTransaction() *Transaction {
+	res := &Transaction{
+		Hash:    tx.Hash,
+		From:    tx.From,
+		To:      tx.To,
+		Input:   tx.Input,
+		Nonce:   uint64(tx.Nonce),
+		Gas:     uint64(tx.Gas),
+		Value:   (*big.Int)(tx.Value),
+		RefHash: tx.RefHash,
+	}
+	if tx.BlockHash != nil {
+		res.BlockHash = *tx.BlockHash
+	}
+	if tx.BlockNumber != nil {
+		res.BlockNumber = (*big.Int)(tx.BlockNumber)
+	}
+	if tx.TransactionIndex != nil {
+		res.TxIndex = uint64(*tx.TransactionIndex)
+	}
+	if tx.RefIndex != nil {
+		res.Index = uint64(*tx.RefIndex)
+	}
+	return res
+}
+
+// TransactionByHash returns the transaction with the given hash.
+func (bc *Client) TransactionByHash(ctx context.Context, hash common.Hash) (*Transaction, error) {
+	var tx *rpcTransaction
+	if err := bc.c.CallContext(ctx, &tx, "eth_getTransactionByHash", hash); err != nil {
+		return nil, err
+	} else if tx == nil {
+		return nil, ethereum.NotFound
+	}
+	return tx.toTransaction(), nil
+}
+
+// BlockTransactions returns the transactions of the block with the given number.
+// The block number can be nil, in which case the latest block is used.
+func (bc *Client) BlockTransactions(ctx context.Context, number *big.Int) ([]*Transaction, error) {
+	var block *struct {
+		Transactions []*rpcTransaction `json:"transactions"`
+	}
+	if err := bc.c.CallContext(ctx, &block, "eth_getBlockByNumber", toBlockNumArg(number), true); err != nil {
+		return nil, err
+	} else if block == nil {
+		return nil, ethereum.NotFound
+	}
+	txs := make([]*Transaction, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		txs[i] = tx.toTransaction()
+	}
+	return txs, nil
+}
+
+// Anchor is a bevm header along with the hash of the BTC block of the same
+// height it was translated from.
+type Anchor struct {
+	Header  *types.Header
+	BtcHash chainhash.Hash
+}
+
+// UnmarshalJSON decodes an anchor from the RPC representation of a header.
+func (a *Anchor) UnmarshalJSON(input []byte) error {
+	var header types.Header
+	if err := json.Unmarshal(input, &header); err != nil {
+		return err
+	}
+	a.Header = &header
+	a.BtcHash = evmHashToBtcHash(header.UncleHash)
+	return nil
+}
+
+// AnchorByNumber returns the anchor of the block with the given number. The
+// block number can be nil, in which case the latest block is used.
+func (bc *Client) AnchorByNumber(ctx context.Context, number *big.Int) (*Anchor, error) {
+	var anchor *Anchor
+	if err := bc.c.CallContext(ctx, &anchor, "eth_getBlockByNumber", toBlockNumArg(number), false); err != nil {
+		return nil, err
+	} else if anchor == nil {
+		return nil, ethereum.NotFound
+	}
+	return anchor, nil
+}
+
+// SubscribeAnchors subscribes to notifications about the blocks translated from
+// BTC, as they become the head of the chain.
+func (bc *Client) SubscribeAnchors(ctx context.Context, ch chan<- *Anchor) (ethereum.Subscription, error) {
+	return bc.c.EthSubscribe(ctx, ch, "newHeads")
+}
+
+// BtcBlock is the notification of a BTC block translated by the node.
+type BtcBlock struct {
+	BtcHash      common.Hash    `json:"btcHash"` // in the display byte order of BTC, like the uncle hash of the block
+	Number       hexutil.Uint64 `json:"number"`
+	Hash         common.Hash    `json:"hash"`
+	Transactions hexutil.Uint64 `json:"transactions"` // number of accepted invocations
+}
+
+// Invocation is an EVM invocation found in a BTC block translated by the node. Rejected invocations have no hash and carry the
+// reason they were rejected for.
+type Invocation struct {
+	BlockHash   common.Hash     `json:"blockHash"`
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+	RefHash     common.Hash     `json:"refHash"`
+	RefIndex    hexutil.Uint64  `json:"refIndex"`
+	Hash        *common.Hash    `json:"hash,omitempty"`
+	From        *common.Address `json:"from,omitempty"`
+	To          *common.Address `json:"to,omitempty"`
+	Reason      string          `json:"reason,omitempty"` // disabled, invalid, unprotected, signature, unknown or other
+	Error       string          `json:"error,omitempty"`
+}
+
+// BlockRange is an inclusive range of block numbers.
+type BlockRange struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"`
+}
+
+// Reorg is the notification of a BTC reorg replacing translated blocks. Added
+// is the range of the new BTC branch, nil if it is not longer than the fork.
+type Reorg struct {
+	Removed BlockRange  `json:"removed"`
+	Added   *BlockRange `json:"added"`
+}
+
+// SubscribeBtcBlocks subscribes to notifications about the BTC blocks translated
+// by the node, including those of the blocks it imported from peers.
+func (bc *Client) SubscribeBtcBlocks(ctx context.Context, ch chan<- *BtcBlock) (ethereum.Subscription, error) {
+	return bc.c.Subscribe(ctx, "bevm", ch, "btcBlocks")
+}
+
+// SubscribeInvocations subscribes to notifications about the EVM invocations
+// found in the BTC blocks translated by the node, rejected ones included.
+func (bc *Client) SubscribeInvocations(ctx context.Context, ch chan<- *Invocation) (ethereum.Subscription, error) {
+	return bc.c.Subscribe(ctx, "bevm", ch, "invocations")
+}
+
+// SubscribeReorgs subscribes to notifications about the BTC reorgs replacing
+// translated blocks.
+func (bc *Client) SubscribeReorgs(ctx context.Context, ch chan<- *Reorg) (ethereum.Subscription, error) {
+	return bc.c.Subscribe(ctx, "bevm", ch, "reorgs")
+}
+
+// Checkpoint is a checkpoint of the chain found on the BTC chain, along with
+// whether it matches the local chain of the node.
+type Checkpoint struct {
+	Height    uint64      `json:"height"`
+	BlockHash common.Hash `json:"blockHash"`
+	StateRoot common.Hash `json:"stateRoot"`
+	BtcTxHash common.Hash `json:"btcTxHash"` // same byte order as the RefHash of invocations
+	BtcHeight uint64      `json:"btcHeight"`
+	Valid     bool        `json:"valid"`
+}
+
+// LatestCheckpoint returns the latest checkpoint of the chain found on the BTC
+// chain, and whether it matches the local chain of the node.
+func (bc *Client) LatestCheckpoint(ctx context.Context) (*Checkpoint, error) {
+	var record *Checkpoint
+	if err := bc.c.CallContext(ctx, &record, "bevm_latestCheckpoint"); err != nil {
+		return nil, err
+	} else if record == nil {
+		return nil, ethereum.NotFound
+	}
+	return record, nil
+}
+
+// SyncStatus is the progress of the node translating the BTC chain.
+type SyncStatus struct {
+	StartingBlock hexutil.Uint64 `json:"startingBlock"` // head when the current catch up began
+	CurrentBlock  hexutil.Uint64 `json:"currentBlock"`  // number of the EVM head
+	BtcHeight     hexutil.Uint64 `json:"btcHeight"`     // height of the last translated BTC block
+	BtcTip        hexutil.Uint64 `json:"btcTip"`        // height of the best block of the BTC node
+	Lag           hexutil.Uint64 `json:"lag"`           // number of BTC blocks not translated yet
+	Updated       time.Time      `json:"updated"`       // time the status was last refreshed
+	Error         string         `json:"error,omitempty"`
+}
+
+// SyncStatus returns the progress of the node translating the BTC chain.
+func (bc *Client) SyncStatus(ctx context.Context) (*SyncStatus, error) {
+	var status SyncStatus
+	if err := bc.c.CallContext(ctx, &status, "bevm_syncStatus"); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ConvertAddress converts an EVM P2WSH address, plain P2WSH address, 0x EVM
+// address or hex encoded public key into all of its bevm forms. Public keys are
+// resolved on the given BTC network, or on that of the chain if empty.
+func (bc *Client) ConvertAddress(ctx context.Context, address string, network string) (*protocol.AddressInfo, error) {
+	var (
+		info *protocol.AddressInfo
+		err  error
+	)
+	if network == "" {
+		err = bc.c.CallContext(ctx, &info, "bevm_convertAddress", address)
+	} else {
+		err = bc.c.CallContext(ctx, &info, "bevm_convertAddress", address, network)
+	}
+	return info, err
+}
+
+// FeeHistory is the history of the fee rates paid by the BTC transactions
+// carrying the invocations of a range of blocks.
+type FeeHistory struct {
+	OldestBlock *hexutil.Big     `json:"oldestBlock"`
+	SatPerVByte [][]float64      `json:"satPerVByte,omitempty"` // fee rate percentiles of each block, weighted by vsize
+	TxCount     []hexutil.Uint64 `json:"txCount"`               // number of btc transactions with a known fee per block
+}
+
+// FeeHistory returns the fee rates in sat/vB paid at the given percentiles by the
+// BTC transactions carrying the invocations of each of the blockCount blocks up
+// to lastBlock. The block number can be nil, in which case the latest block is
+// used.
+func (bc *Client) FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, percentiles []float64) (*FeeHistory, error) {
+	var history FeeHistory
+	if err := bc.c.CallContext(ctx, &history, "bevm_feeHistory", hexutil.Uint(blockCount), toBlockNumArg(lastBlock), percentiles); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// Witness lists the state and chain data read by a block, in the RLP encoding
+// of the node.
+type Witness struct {
+	Accounts   []common.Address // accounts read by the block
+	Storage    []WitnessStorage // storage slots read by the block
+	Codes      [][]byte         // contract codes read by the block
+	Nodes      [][]byte         // trie nodes on the paths of all reads and writes
+	Headers    []*types.Header  // ancestors below the parent read by BLOCKHASH or the BTC header precompiles
+	BtcHeaders [][]byte         // raw BTC headers read by the BTC header precompiles
+}
+
+// WitnessStorage lists the storage slots of an account read by a block.
+type WitnessStorage struct {
+	Address common.Address
+	Slots   []common.Hash
+}
+
+// StatelessBlock is a block along with the header of its parent and the
+// witness needed to verify it without the state.
+type StatelessBlock struct {
+	Parent  *types.Header
+	Block   *types.Block
+	Witness *Witness
+}
+
+// Witness returns the block with the given number along with the header of its
+// parent and the witness needed to verify it statelessly. The block number can
+// be nil, in which case the latest block is used.
+func (bc *Client) Witness(ctx context.Context, number *big.Int) (*StatelessBlock, error) {
+	var blob hexutil.Bytes
+	if err := bc.c.CallContext(ctx, &blob, "bevm_getWitness", toBlockNumArg(number)); err != nil {
+		return nil, err
+	}
+	block := new(StatelessBlock)
+	if err := rlp.DecodeBytes(blob, block); err != nil {
+		return nil, err
+	}
+	if block.Parent == nil || block.Block == nil || block.Witness == nil {
+		return nil, errors.New("server returned incomplete witness")
+	}
+	return block, nil
+}
+
+// Invocations returns the EVM invocations found in the BTC block the block with
+// the given number was translated from, rejected ones included. The block
+// number can be nil, in which case the latest block is used.
+func (bc *Client) Invocations(ctx context.Context, number *big.Int) ([]*Invocation, error) {
+	var invocations []*Invocation
+	if err := bc.c.CallContext(ctx, &invocations, "bevm_getInvocations", toBlockNumArg(number)); err != nil {
+		return nil, err
+	}
+	return invocations, nil
+}
+
+// MessageProof is the inclusion proof of a cross layer message in the message
+// MMR of a block.
+type MessageProof struct {
+	MessageIndex hexutil.Uint64 `json:"messageIndex"`
+	Leaf         common.Hash    `json:"leaf"`     // crossLayerMessageHash of the message
+	Siblings     []common.Hash  `json:"siblings"` // audit path from the leaf up to the root
+	Peaks        []common.Hash  `json:"peaks"`    // roots of the perfect subtrees, left to right
+	MmrSize      hexutil.Uint64 `json:"mmrSize"`
+	MmrRoot      common.Hash    `json:"mmrRoot"`
+	BlockNumber  hexutil.Uint64 `json:"blockNumber"`
+	BlockHash    common.Hash    `json:"blockHash"`
+}
+
+// MessageProof returns the inclusion proof of the cross layer message with the
+// given index in the message MMR of the block with the given number. The block
+// number can be nil, in which case the latest block is used.
+func (bc *Client) MessageProof(ctx context.Context, index uint64, number *big.Int) (*MessageProof, error) {
+	var proof MessageProof
+	if err := bc.c.CallContext(ctx, &proof, "bevm_getMessageProof", hexutil.Uint64(index), toBlockNumArg(number)); err != nil {
+		return nil, err
+	}
+	return &proof, nil
+}
+
+// evmHashToBtcHash returns the BTC block hash recorded in the uncle hash of a
+// bevm header, which holds it in the display byte order.
+func evmHashToBtcHash(hash common.Hash) chainhash.Hash {
+	for i := 0; i < len(hash)/2; i++ {
+		hash[i], hash[len(hash)-1-i] = hash[len(hash)-1-i], hash[i]
+	}
+	return chainhash.Hash(hash)
+}
+
+func toBlockNumArg(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	pending := big.NewInt(-1)
+	if number.Cmp(pending) == 0 {
+		return "pending"
+	}
+	return hexutil.EncodeBig(number)
+}
@@ -0,0 +1,304 @@
+// Copyright 2023 The Goshen network Authors
+
+package bevmclient
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/bevm"
+	"github.com/ethereum/go-ethereum/bevm/protocol"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var testChainConfig = &params.ChainConfig{
+	ChainID:             big.NewInt(1337),
+	HomesteadBlock:      big.NewInt(0),
+	EIP150Block:         big.NewInt(0),
+	EIP155Block:         big.NewInt(0),
+	EIP158Block:         big.NewInt(0),
+	ByzantiumBlock:      big.NewInt(0),
+	ConstantinopleBlock: big.NewInt(0),
+	PetersburgBlock:     big.NewInt(0),
+	IstanbulBlock:       big.NewInt(0),
+	BevmBlock:           big.NewInt(0),
+}
+
+func newBtcBlock(prev *wire.MsgBlock, height int64, txs ...*wire.MsgTx) *wire.MsgBlock {
+	var prevHash chainhash.Hash
+	if prev != nil {
+		prevHash = prev.BlockHash()
+	}
+	header := wire.NewBlockHeader(1, &prevHash, &chainhash.Hash{}, 0x207fffff, uint32(height))
+	header.Timestamp = time.Unix(1600000000+height*600, 0)
+	block := wire.NewMsgBlock(header)
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), []byte{byte(height), 0x51}, nil))
+	coinbase.AddTxOut(wire.NewTxOut(50e8, []byte{txscript.OP_TRUE}))
+	block.AddTransaction(coinbase)
+	for _, tx := range txs {
+		block.AddTransaction(tx)
+	}
+	return block
+}
+
+// newDeployTxs returns a funding transaction paying to an EVM deploy script of
+// key and the transaction spending it with the given init code in its witness.
+func newDeployTxs(t *testing.T, key *btcec.PrivateKey, code []byte) (*wire.MsgTx, *wire.MsgTx) {
+	return newInvokeTxs(t, key, &protocol.EVMDeploy{Data: code})
+}
+
+// newInvokeTxs returns a funding transaction paying to an EVM deploy script of
+// key and the transaction spending it with evmData in its witness.
+func newInvokeTxs(t *testing.T, key *btcec.PrivateKey, evmData protocol.EVMInvokeData) (*wire.MsgTx, *wire.MsgTx) {
+	script, err := protocol.NewEVMScript(protocol.NewPubKeyPolicy(key.PubKey()), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(chainhash.HashB(script)).Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	funding := wire.NewMsgTx(wire.TxVersion)
+	funding.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	funding.AddTxOut(wire.NewTxOut(1e5, pkScript))
+
+	witness, err := protocol.BuildEVMWitness([][]byte{make([]byte, 71)}, script, evmData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fundingHash := funding.TxHash()
+	spend := wire.NewMsgTx(wire.TxVersion)
+	spend.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&fundingHash, 0), nil, witness))
+	spend.AddTxOut(wire.NewTxOut(9e4, []byte{txscript.OP_TRUE}))
+	return funding, spend
+}
+
+// newTestBackend creates a bevm service translating the given BTC blocks and an
+// in-process RPC server exposing its APIs. The miner is not started.
+func newTestBackend(t *testing.T, blocks []*wire.MsgBlock, extra []*wire.MsgTx) (*node.Node, *bevm.Ethereum, *rpc.Server) {
+	n, err := node.New(&node.Config{})
+	if err != nil {
+		t.Fatalf("can't create new node: %v", err)
+	}
+	genesis := &core.Genesis{
+		Config:     testChainConfig,
+		Timestamp:  uint64(blocks[0].Header.Timestamp.Unix()),
+		GasLimit:   math.MaxUint64,
+		Difficulty: blockchain.CalcWork(blocks[0].Header.Bits),
+		UncleHash:  bevm.BtcHashToEvmHash(blocks[0].BlockHash()),
+	}
+	config := &ethconfig.Config{Genesis: genesis, NetworkId: 1, GPO: ethconfig.Defaults.GPO}
+	ethservice, err := bevm.New(n, config, &bevm.BtcRpcConfig{Client: bevm.NewFixtureClient(0, blocks, extra)})
+	if err != nil {
+		t.Fatalf("can't create new bevm service: %v", err)
+	}
+	server := rpc.NewServer()
+	for _, api := range ethservice.APIs() {
+		if err := server.RegisterName(api.Namespace, api.Service); err != nil {
+			t.Fatalf("can't register %s api: %v", api.Namespace, err)
+		}
+	}
+	return n, ethservice, server
+}
+
+func TestBevmClient(t *testing.T) {
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	funding, spend := newDeployTxs(t, key, []byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x00}) // sstore(0, 1)
+	relayKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	relayFunding, relaySpend := newInvokeTxs(t, relayKey, &protocol.EVMRelay{Tx: []byte{0x01, 0x02}})
+	genesis := newBtcBlock(nil, 0)
+	block1 := newBtcBlock(genesis, 1, spend, relaySpend)
+	block2 := newBtcBlock(block1, 2)
+	blocks := []*wire.MsgBlock{genesis, block1, block2}
+
+	n, ethservice, server := newTestBackend(t, blocks, []*wire.MsgTx{funding, relayFunding})
+	defer n.Close()
+	defer server.Stop()
+	client := New(rpc.DialInProc(server))
+	defer client.Close()
+	ctx := context.Background()
+
+	// Translate the BTC blocks while subscribed to their anchors.
+	anchors := make(chan *Anchor)
+	sub, err := client.SubscribeAnchors(ctx, anchors)
+	if err != nil {
+		t.Fatalf("failed to subscribe to anchors: %v", err)
+	}
+	defer sub.Unsubscribe()
+	btcBlocks := make(chan *BtcBlock, 2)
+	blocksSub, err := client.SubscribeBtcBlocks(ctx, btcBlocks)
+	if err != nil {
+		t.Fatalf("failed to subscribe to btc blocks: %v", err)
+	}
+	defer blocksSub.Unsubscribe()
+	invocations := make(chan *Invocation, 2)
+	invocationsSub, err := client.SubscribeInvocations(ctx, invocations)
+	if err != nil {
+		t.Fatalf("failed to subscribe to invocations: %v", err)
+	}
+	defer invocationsSub.Unsubscribe()
+	reorgsSub, err := client.SubscribeReorgs(ctx, make(chan *Reorg))
+	if err != nil {
+		t.Fatalf("failed to subscribe to reorgs: %v", err)
+	}
+	defer reorgsSub.Unsubscribe()
+	time.Sleep(100 * time.Millisecond) // wait for the subscriptions to be installed
+	go ethservice.Start()
+	defer ethservice.Stop()
+
+	for number := int64(1); number <= 2; number++ {
+		select {
+		case anchor := <-anchors:
+			if anchor.Header.Number.Int64() != number || anchor.BtcHash != blocks[number].BlockHash() {
+				t.Fatalf("anchor mismatch: have %d [%s], want %d [%s]", anchor.Header.Number, anchor.BtcHash, number, blocks[number].BlockHash())
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timeout waiting for anchor %d", number)
+		}
+	}
+	for number := uint64(1); number <= 2; number++ {
+		block := <-btcBlocks
+		header := ethservice.BlockChain().GetHeaderByNumber(number)
+		if uint64(block.Number) != number || block.Hash != header.Hash() || block.BtcHash != bevm.BtcHashToEvmHash(blocks[number].BlockHash()) {
+			t.Fatalf("btc block %d mismatch: %+v", number, block)
+		}
+	}
+	invocation := <-invocations
+	if invocation.BlockNumber != 1 || invocation.RefHash != common.Hash(spend.TxHash()) || invocation.Hash == nil || invocation.Error != "" {
+		t.Fatalf("invocation mismatch: %+v", invocation)
+	}
+
+	anchor, err := client.AnchorByNumber(ctx, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("failed to get anchor: %v", err)
+	}
+	if anchor.BtcHash != block1.BlockHash() || anchor.Header.Hash() != ethservice.BlockChain().GetHeaderByNumber(1).Hash() {
+		t.Fatalf("anchor 1 mismatch: have %s, want %s", anchor.BtcHash, block1.BlockHash())
+	}
+	if _, err := client.AnchorByNumber(ctx, big.NewInt(3)); err != ethereum.NotFound {
+		t.Fatalf("error mismatch: have %v, want %v", err, ethereum.NotFound)
+	}
+
+	// Invocations report the BTC transaction they were translated from.
+	txs, err := client.BlockTransactions(ctx, big.NewInt(1))
+	if err != nil || len(txs) != 1 {
+		t.Fatalf("failed to get block transactions: %d, %v", len(txs), err)
+	}
+	tx, err := client.TransactionByHash(ctx, txs[0].Hash)
+	if err != nil {
+		t.Fatalf("failed to get transaction: %v", err)
+	}
+	if invocation.From == nil || tx.Hash != *invocation.Hash || tx.From != *invocation.From {
+		t.Fatalf("notified invocation mismatch: have %x, want %x", *invocation.Hash, tx.Hash)
+	}
+	if !tx.Invocation() || *tx.RefHash != common.Hash(spend.TxHash()) || tx.Index != 0 {
+		t.Fatalf("btc origin mismatch: have %v/%d, want %x/0", tx.RefHash, tx.Index, spend.TxHash())
+	}
+	if tx.BlockNumber.Int64() != 1 || tx.To != nil || len(tx.Input) == 0 {
+		t.Fatalf("transaction mismatch: block %d, to %v, input %x", tx.BlockNumber, tx.To, tx.Input)
+	}
+	if _, err := client.TransactionByHash(ctx, common.Hash{1}); err != ethereum.NotFound {
+		t.Fatalf("error mismatch: have %v, want %v", err, ethereum.NotFound)
+	}
+
+	// Rejected invocations are listed along with the accepted ones.
+	listed, err := client.Invocations(ctx, big.NewInt(1))
+	if err != nil || len(listed) != 2 {
+		t.Fatalf("failed to list invocations: %d, %v", len(listed), err)
+	}
+	if accepted := listed[0]; accepted.BlockHash != invocation.BlockHash || accepted.RefHash != invocation.RefHash || accepted.Hash == nil || *accepted.Hash != *invocation.Hash {
+		t.Fatalf("listed invocation mismatch: have %+v, want %+v", listed[0], invocation)
+	}
+	rejected := listed[1]
+	if rejected.RefHash != common.Hash(relaySpend.TxHash()) || rejected.Hash != nil || rejected.Reason != "invalid" || rejected.Error == "" {
+		t.Fatalf("rejected invocation mismatch: %+v", rejected)
+	}
+	if notified := <-invocations; notified.Reason != rejected.Reason || notified.RefHash != rejected.RefHash {
+		t.Fatalf("notified rejection mismatch: %+v", notified)
+	}
+	if listed, err = client.Invocations(ctx, big.NewInt(2)); err != nil || len(listed) != 0 {
+		t.Fatalf("invocations of an empty block: %d, %v", len(listed), err)
+	}
+
+	status, err := client.SyncStatus(ctx)
+	if err != nil {
+		t.Fatalf("failed to get sync status: %v", err)
+	}
+	if status.CurrentBlock != 2 || status.BtcTip != 2 || status.Lag != 0 {
+		t.Fatalf("sync status mismatch: %+v", status)
+	}
+	history, err := client.FeeHistory(ctx, 2, nil, []float64{50})
+	if err != nil {
+		t.Fatalf("failed to get fee history: %v", err)
+	}
+	if history.OldestBlock.ToInt().Int64() != 1 || len(history.TxCount) != 2 || history.TxCount[0] != 1 || history.TxCount[1] != 0 {
+		t.Fatalf("fee history mismatch: oldest %d, counts %v", history.OldestBlock.ToInt(), history.TxCount)
+	}
+	if _, err := client.LatestCheckpoint(ctx); err != ethereum.NotFound {
+		t.Fatalf("error mismatch: have %v, want %v", err, ethereum.NotFound)
+	}
+
+	pub := common.Bytes2Hex(key.PubKey().SerializeCompressed())
+	info, err := client.ConvertAddress(ctx, pub, "regtest")
+	if err != nil {
+		t.Fatalf("failed to convert address: %v", err)
+	}
+	want := protocol.NewAddressEVMFromPubKey(key.PubKey(), &chaincfg.RegressionNetParams)
+	if info.Network != chaincfg.RegressionNetParams.Name || info.EVMScriptAddress != want.EncodeAddress() {
+		t.Fatalf("address mismatch: have %s on %s, want %s", info.EVMScriptAddress, info.Network, want.EncodeAddress())
+	}
+	if _, err := client.ConvertAddress(ctx, "invalid", ""); err == nil {
+		t.Fatal("converted invalid address")
+	}
+
+	witness, err := client.Witness(ctx, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("failed to get witness: %v", err)
+	}
+	// The witness decodes into that of the node.
+	enc, err := rlp.EncodeToBytes(witness.Witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodeWitness bevm.Witness
+	if err := rlp.DecodeBytes(enc, &nodeWitness); err != nil {
+		t.Fatalf("failed to decode witness: %v", err)
+	}
+	if err := bevm.VerifyStateless(testChainConfig, witness.Parent, witness.Block, &nodeWitness); err != nil {
+		t.Fatalf("failed to verify witness: %v", err)
+	}
+	if _, err := client.MessageProof(ctx, 0, nil); err == nil {
+		t.Fatal("proved message of an empty tree")
+	}
+
+	var rpcErr rpc.Error
+	if _, err := client.Witness(ctx, big.NewInt(3)); !errors.As(err, &rpcErr) {
+		t.Fatalf("error mismatch: have %v, want rpc error", err)
+	}
+}
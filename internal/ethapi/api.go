@@ -1281,6 +1281,8 @@ type RPCTransaction struct {
 	V                *hexutil.Big      `json:"v"`
 	R                *hexutil.Big      `json:"r"`
 	S                *hexutil.Big      `json:"s"`
+	RefHash          *common.Hash      `json:"refHash,omitempty"`  // btc transaction carrying the invocation
+	RefIndex         *hexutil.Uint64   `json:"refIndex,omitempty"` // index of the invocation in the btc transaction
 }
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
@@ -1308,6 +1310,10 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		result.BlockNumber = (*hexutil.Big)(new(big.Int).SetUint64(blockNumber))
 		result.TransactionIndex = (*hexutil.Uint64)(&index)
 	}
+	if hash, index, ok := tx.BtcRef(); ok {
+		result.RefHash = &hash
+		result.RefIndex = (*hexutil.Uint64)(&index)
+	}
 	switch tx.Type() {
 	case types.AccessListTxType:
 		al := tx.AccessList()
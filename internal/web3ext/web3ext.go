@@ -18,18 +18,20 @@
 package web3ext
 
 var Modules = map[string]string{
-	"admin":    AdminJs,
-	"clique":   CliqueJs,
-	"ethash":   EthashJs,
-	"debug":    DebugJs,
-	"eth":      EthJs,
-	"miner":    MinerJs,
-	"net":      NetJs,
-	"personal": PersonalJs,
-	"rpc":      RpcJs,
-	"txpool":   TxpoolJs,
-	"les":      LESJs,
-	"vflux":    VfluxJs,
+	"admin":     AdminJs,
+	"clique":    CliqueJs,
+	"ethash":    EthashJs,
+	"debug":     DebugJs,
+	"eth":       EthJs,
+	"miner":     MinerJs,
+	"net":       NetJs,
+	"personal":  PersonalJs,
+	"rpc":       RpcJs,
+	"txpool":    TxpoolJs,
+	"les":       LESJs,
+	"vflux":     VfluxJs,
+	"bevm":      BevmJs,
+	"bevmadmin": BevmAdminJs,
 }
 
 const CliqueJs = `
@@ -848,3 +850,39 @@ web3._extend({
 	]
 });
 `
+
+const BevmJs = `
+web3._extend({
+	property: 'bevm',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'convertAddress',
+			call: 'bevm_convertAddress',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+	]
+});
+`
+
+const BevmAdminJs = `
+web3._extend({
+	property: 'bevmadmin',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'accountInfo',
+			call: 'bevmadmin_accountInfo',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'signInvocation',
+			call: 'bevmadmin_signInvocation',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+	]
+});
+`
@@ -152,6 +152,13 @@ const (
 	Bls12381MapG1Gas          uint64 = 5500   // Gas price for BLS12-381 mapping field element to G1 operation
 	Bls12381MapG2Gas          uint64 = 110000 // Gas price for BLS12-381 mapping field element to G2 operation
 
+	BtcHeaderGas           uint64 = 2600 // Gas price for reading a relayed BTC block header
+	BtcMerkleProofBaseGas  uint64 = 3000 // Base price for a BTC transaction merkle inclusion proof check
+	BtcMerkleProofLevelGas uint64 = 150  // Per-level price for a BTC transaction merkle inclusion proof check
+	BtcHeaderWindow        uint64 = 2016 // Number of past BTC headers reachable from the EVM (one retarget period)
+	SchnorrVerifyGas       uint64 = 3000 // Gas price for a BIP-340 schnorr signature verification
+	TaprootOutputKeyGas    uint64 = 3000 // Gas price for a BIP-341 taproot output key tweak
+
 	// The Refund Quotient is the cap on how much of the used gas can be refunded. Before EIP-3529,
 	// up to half the consumed gas could be refunded. Redefined as 1/5th in EIP-3529
 	RefundQuotient        uint64 = 2
@@ -41,9 +41,9 @@ const (
 	// numberOfAccountsToDerive For hardware wallets, the number of accounts to derive
 	numberOfAccountsToDerive = 10
 	// ExternalAPIVersion -- see extapi_changelog.md
-	ExternalAPIVersion = "6.1.0"
+	ExternalAPIVersion = "6.2.0"
 	// InternalAPIVersion -- see intapi_changelog.md
-	InternalAPIVersion = "7.0.1"
+	InternalAPIVersion = "7.1.0"
 )
 
 // ExternalAPI defines the external API through which signing requests are made.
@@ -64,6 +64,8 @@ type ExternalAPI interface {
 	Version(ctx context.Context) (string, error)
 	// SignGnosisSafeTransaction signs/confirms a gnosis-safe multisig transaction
 	SignGnosisSafeTx(ctx context.Context, signerAddress common.MixedcaseAddress, gnosisTx GnosisSafeTx, methodSelector *string) (*GnosisSafeTx, error)
+	// SignBevmInvocation signs the input of a BTC transaction spending the EVM script of an account
+	SignBevmInvocation(ctx context.Context, args BevmInvocationArgs, methodSelector *string) (*BevmInvocationResult, error)
 }
 
 // UIClientAPI specifies what method a UI needs to implement to be able to be used as a
@@ -73,6 +75,8 @@ type UIClientAPI interface {
 	ApproveTx(request *SignTxRequest) (SignTxResponse, error)
 	// ApproveSignData prompt the user for confirmation to request to sign data
 	ApproveSignData(request *SignDataRequest) (SignDataResponse, error)
+	// ApproveBevmInvocation prompt the user for confirmation to request to sign a bevm invocation
+	ApproveBevmInvocation(request *SignBevmInvocationRequest) (SignBevmInvocationResponse, error)
 	// ApproveListing prompt the user for confirmation to list accounts
 	// the list of accounts to list can be modified by the UI
 	ApproveListing(request *ListRequest) (ListResponse, error)
@@ -243,6 +247,18 @@ type (
 	SignDataResponse struct {
 		Approved bool `json:"approved"`
 	}
+	// SignBevmInvocationRequest contains info about a bevm invocation to sign
+	SignBevmInvocationRequest struct {
+		Invocation BevmInvocationArgs        `json:"invocation"`
+		Inputs     []BevmTxInput             `json:"inputs"`
+		Outputs    []BevmTxOutput            `json:"outputs"`
+		Fee        int64                     `json:"fee"` // from the requested amounts, unverified
+		Callinfo   []apitypes.ValidationInfo `json:"call_info"`
+		Meta       Metadata                  `json:"meta"`
+	}
+	SignBevmInvocationResponse struct {
+		Approved bool `json:"approved"`
+	}
 	NewAccountRequest struct {
 		Meta Metadata `json:"meta"`
 	}
@@ -76,6 +76,11 @@ func (ui *headlessUi) ApproveSignData(request *core.SignDataRequest) (core.SignD
 	return core.SignDataResponse{approved}, nil
 }
 
+func (ui *headlessUi) ApproveBevmInvocation(request *core.SignBevmInvocationRequest) (core.SignBevmInvocationResponse, error) {
+	approved := (<-ui.approveCh == "Y")
+	return core.SignBevmInvocationResponse{approved}, nil
+}
+
 func (ui *headlessUi) ApproveListing(request *core.ListRequest) (core.ListResponse, error) {
 	approval := <-ui.approveCh
 	//fmt.Printf("approval %s\n", approval)
@@ -89,6 +89,23 @@ func (l *AuditLogger) SignGnosisSafeTx(ctx context.Context, addr common.Mixedcas
 	return res, e
 }
 
+func (l *AuditLogger) SignBevmInvocation(ctx context.Context, args BevmInvocationArgs, methodSelector *string) (*BevmInvocationResult, error) {
+	sel := "<nil>"
+	if methodSelector != nil {
+		sel = *methodSelector
+	}
+	data, _ := json.Marshal(args) // can ignore error, marshalling what we just unmarshalled
+	l.log.Info("SignBevmInvocation", "type", "request", "metadata", MetadataFromContext(ctx).String(),
+		"from", args.From.String(), "data", string(data), "selector", sel)
+	res, e := l.api.SignBevmInvocation(ctx, args, methodSelector)
+	if res != nil {
+		l.log.Info("SignBevmInvocation", "type", "response", "signature", common.Bytes2Hex(res.Signature), "tx", common.Bytes2Hex(res.Tx), "error", e)
+	} else {
+		l.log.Info("SignBevmInvocation", "type", "response", "data", res, "error", e)
+	}
+	return res, e
+}
+
 func (l *AuditLogger) SignTypedData(ctx context.Context, addr common.MixedcaseAddress, data TypedData) (hexutil.Bytes, error) {
 	l.log.Info("SignTypedData", "type", "request", "metadata", MetadataFromContext(ctx).String(),
 		"addr", addr.String(), "data", data)
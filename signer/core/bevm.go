@@ -0,0 +1,209 @@
+// Copyright 2023 The Goshen network Authors
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/bevm/protocol"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// BevmInvocationArgs is a request to sign the input of a BTC transaction that
+// spends the EVM script of an account, invoking a contract on bevm. The EVM
+// call script is spent if To is set, the EVM deploy script otherwise.
+type BevmInvocationArgs struct {
+	From    common.MixedcaseAddress  `json:"from"`
+	To      *common.MixedcaseAddress `json:"to"`
+	Data    *hexutil.Bytes           `json:"data"`
+	Tx      hexutil.Bytes            `json:"tx"`      // BTC transaction in wire format
+	Input   hexutil.Uint             `json:"input"`   // index of the input spending the EVM script
+	Amounts []hexutil.Uint64         `json:"amounts"` // values in satoshi of the outputs spent by each input
+	Network string                   `json:"network"` // BTC network the output addresses are shown for, mainnet if empty
+}
+
+// BevmTxInput is an input of the BTC transaction of a bevm invocation.
+type BevmTxInput struct {
+	PrevOut string `json:"prevout"`
+	Amount  int64  `json:"amount"`
+	Signed  bool   `json:"signed"` // whether this is the input spending the EVM script
+}
+
+// BevmTxOutput is an output of the BTC transaction of a bevm invocation.
+type BevmTxOutput struct {
+	Address string        `json:"address"` // empty for non standard scripts
+	Script  hexutil.Bytes `json:"script"`
+	Amount  int64         `json:"amount"`
+}
+
+// BevmInvocationResult is the signature of a bevm invocation, along with the
+// witness it was assembled into and the transaction that witness was set in.
+type BevmInvocationResult struct {
+	Signature hexutil.Bytes   `json:"signature"` // DER signature followed by the SIGHASH_ALL byte
+	Witness   []hexutil.Bytes `json:"witness"`
+	Tx        hexutil.Bytes   `json:"tx"`
+}
+
+// decode parses the BTC transaction of the invocation and checks the inputs
+// and amounts match it.
+func (args *BevmInvocationArgs) decode() (*wire.MsgTx, error) {
+	tx := new(wire.MsgTx)
+	if err := tx.Deserialize(bytes.NewReader(args.Tx)); err != nil {
+		return nil, fmt.Errorf("invalid btc transaction: %v", err)
+	}
+	if int(args.Input) >= len(tx.TxIn) {
+		return nil, fmt.Errorf("input %d out of range, transaction has %d inputs", args.Input, len(tx.TxIn))
+	}
+	if len(args.Amounts) != len(tx.TxIn) {
+		return nil, fmt.Errorf("got %d amounts for %d inputs", len(args.Amounts), len(tx.TxIn))
+	}
+	return tx, nil
+}
+
+// evmData returns the invoke data carried by the witness.
+func (args *BevmInvocationArgs) evmData() protocol.EVMInvokeData {
+	var data []byte
+	if args.Data != nil {
+		data = *args.Data
+	}
+	if args.To == nil {
+		return &protocol.EVMDeploy{Data: data}
+	}
+	return &protocol.EVMCall{To: args.To.Address(), Data: data}
+}
+
+// ArgsForValidation returns a SendTxArgs struct, which can be used for the
+// common validations, e.g. look up 4byte destinations. Invocations pay their
+// fees in BTC, so the gas price is zero.
+func (args *BevmInvocationArgs) ArgsForValidation() *apitypes.SendTxArgs {
+	return &apitypes.SendTxArgs{
+		From:     args.From,
+		To:       args.To,
+		GasPrice: new(hexutil.Big),
+		Data:     args.Data,
+	}
+}
+
+// request builds the request shown to the UI, with the inputs, outputs and fee
+// of the BTC transaction.
+func (args *BevmInvocationArgs) request(tx *wire.MsgTx) (*SignBevmInvocationRequest, error) {
+	net := &chaincfg.MainNetParams
+	if args.Network != "" {
+		var err error
+		if net, err = protocol.NetParamsByName(args.Network); err != nil {
+			return nil, err
+		}
+	}
+	req := &SignBevmInvocationRequest{Invocation: *args}
+	for i, txin := range tx.TxIn {
+		amount := int64(args.Amounts[i])
+		req.Inputs = append(req.Inputs, BevmTxInput{
+			PrevOut: txin.PreviousOutPoint.String(),
+			Amount:  amount,
+			Signed:  i == int(args.Input),
+		})
+		req.Fee += amount
+	}
+	for _, txout := range tx.TxOut {
+		output := BevmTxOutput{Script: txout.PkScript, Amount: txout.Value}
+		if _, addrs, _, err := txscript.ExtractPkScriptAddrs(txout.PkScript, net); err == nil && len(addrs) == 1 {
+			output.Address = addrs[0].EncodeAddress()
+		}
+		req.Outputs = append(req.Outputs, output)
+		req.Fee -= txout.Value
+	}
+	if req.Fee < 0 {
+		return nil, fmt.Errorf("outputs exceed inputs by %d satoshi", -req.Fee)
+	}
+	return req, nil
+}
+
+// SignBevmInvocation signs the input of a BTC transaction spending the EVM script
+// of a keystore account, according to BIP-143 with SIGHASH_ALL. The signature is
+// returned along with the witness carrying the invocation and the transaction
+// with that witness set.
+//
+// The BIP-143 sighash commits to the transaction and the spent script, but not to
+// the witness items carrying the invocation, so the signature can be reused with
+// any other To and Data. The fee is computed from the requested amounts, which
+// the signature does not cover for the other inputs and are not verified.
+func (api *SignerAPI) SignBevmInvocation(ctx context.Context, args BevmInvocationArgs, methodSelector *string) (*BevmInvocationResult, error) {
+	tx, err := args.decode()
+	if err != nil {
+		return nil, err
+	}
+	// Do the usual validations, but on the invoked contract
+	msgs, err := api.validator.ValidateTransaction(methodSelector, args.ArgsForValidation())
+	if err != nil {
+		return nil, err
+	}
+	// If we are in 'rejectMode', then reject rather than show the user warnings
+	if api.rejectMode {
+		if err := msgs.GetWarnings(); err != nil {
+			return nil, err
+		}
+	}
+	req, err := args.request(tx)
+	if err != nil {
+		return nil, err
+	}
+	req.Callinfo = msgs.Messages
+	req.Meta = MetadataFromContext(ctx)
+
+	result, err := api.UI.ApproveBevmInvocation(req)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Approved {
+		return nil, ErrRequestDenied
+	}
+	// Only keystore accounts can sign arbitrary hashes
+	backends := api.am.Backends(keystore.KeyStoreType)
+	if len(backends) == 0 {
+		return nil, errors.New("no keystore backend")
+	}
+	ks := backends[0].(*keystore.KeyStore)
+	acc, err := ks.Find(accounts.Account{Address: args.From.Address()})
+	if err != nil {
+		return nil, err
+	}
+	pw, err := api.lookupOrQueryPassword(acc.Address, "Account password",
+		fmt.Sprintf("Please enter the password for account %s", acc.Address.String()))
+	if err != nil {
+		return nil, err
+	}
+	sign := func(hash []byte) ([]byte, error) {
+		sig, err := ks.SignHashWithPassphrase(acc, pw, hash)
+		if err != nil {
+			api.UI.ShowError(err.Error())
+		}
+		return sig, err
+	}
+	// Sign the input spending the EVM script of the key
+	idx := int(args.Input)
+	witness, err := protocol.EVMWitnessSignWith(tx, idx, int64(args.Amounts[idx]), sign, args.evmData())
+	if err != nil {
+		return nil, err
+	}
+	tx.TxIn[idx].Witness = witness
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	response := &BevmInvocationResult{Signature: witness[0], Tx: buf.Bytes()}
+	for _, item := range witness {
+		response.Witness = append(response.Witness, item)
+	}
+	return response, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 The Goshen network Authors
+
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/bevm/protocol"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core"
+)
+
+func mkTestInvocation(from common.MixedcaseAddress) core.BevmInvocationArgs {
+	to := common.NewMixedcaseAddress(common.HexToAddress("0x1337"))
+	data := hexutil.Bytes(common.Hex2Bytes("01020304"))
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{2}, 1), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(9e4, []byte{txscript.OP_TRUE}))
+	var raw bytes.Buffer
+	tx.Serialize(&raw)
+	return core.BevmInvocationArgs{
+		From:    from,
+		To:      &to,
+		Data:    &data,
+		Tx:      raw.Bytes(),
+		Input:   1,
+		Amounts: []hexutil.Uint64{5e4, 1e5},
+	}
+}
+
+func TestSignBevmInvocation(t *testing.T) {
+	api, control := setup(t)
+	createAccount(control, api, t)
+	control.approveCh <- "A"
+	list, err := api.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := mkTestInvocation(common.NewMixedcaseAddress(list[0]))
+
+	control.approveCh <- "No way"
+	if _, err := api.SignBevmInvocation(context.Background(), args, nil); err != core.ErrRequestDenied {
+		t.Errorf("Expected ErrRequestDenied! %v", err)
+	}
+	control.approveCh <- "Y"
+	control.inputCh <- "a_long_password"
+	res, err := api.SignBevmInvocation(context.Background(), args, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(res.Tx)); err != nil {
+		t.Fatal(err)
+	}
+	witness := tx.TxIn[1].Witness
+	if len(tx.TxIn[0].Witness) != 0 || len(witness) != len(res.Witness) || !bytes.Equal(witness[0], res.Signature) {
+		t.Fatal("Expected the signature in the witness of the requested input")
+	}
+	// The script must hold the key of the account
+	script := witness[len(witness)-1]
+	_, policy := protocol.SplitEVMScript(script)
+	pub, err := btcec.ParsePubKey(policy[1:34])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := crypto.PubkeyToAddress(*pub.ToECDSA()); addr != list[0] {
+		t.Errorf("Expected script of %x, got %x", list[0], addr)
+	}
+	// The witness must satisfy the script and carry the call
+	scriptHash := sha256.Sum256(script)
+	pkScript, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(scriptHash[:]).Script()
+	fetcher := txscript.NewCannedPrevOutputFetcher(pkScript, 1e5)
+	vm, err := txscript.NewEngine(pkScript, &tx, 1, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(&tx, fetcher), 1e5, fetcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Invalid witness: %v", err)
+	}
+	invocations := protocol.ExtractEVMWitness(&tx, fetcher)
+	if len(invocations) != 1 {
+		t.Fatalf("Expected 1 invocation, got %d", len(invocations))
+	}
+	call, ok := invocations[0].(*protocol.EVMCall)
+	if !ok || call.To != args.To.Address() || !bytes.Equal(call.Data, *args.Data) {
+		t.Errorf("Invocation mismatch: %+v", invocations[0])
+	}
+	// Outputs exceeding the inputs are rejected before reaching the UI
+	args.Amounts = []hexutil.Uint64{0, 1e4}
+	if _, err := api.SignBevmInvocation(context.Background(), args, nil); err == nil {
+		t.Error("Expected error for negative fee")
+	}
+}
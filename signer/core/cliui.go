@@ -186,6 +186,59 @@ func (ui *CommandlineUI) ApproveSignData(request *SignDataRequest) (SignDataResp
 	return SignDataResponse{true}, nil
 }
 
+// ApproveBevmInvocation prompt the user for confirmation to request to sign a bevm invocation
+func (ui *CommandlineUI) ApproveBevmInvocation(request *SignBevmInvocationRequest) (SignBevmInvocationResponse, error) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	invocation := request.Invocation
+	fmt.Printf("-------- Bevm invocation request--------------\n")
+	fmt.Printf("from:     %v\n", invocation.From.String())
+	if to := invocation.To; to != nil {
+		fmt.Printf("to:       %v\n", to.Original())
+		if !to.ValidChecksum() {
+			fmt.Printf("\nWARNING: Invalid checksum on to-address!\n\n")
+		}
+	} else {
+		fmt.Printf("to:       <contact creation>\n")
+	}
+	if invocation.Data != nil && len(*invocation.Data) > 0 {
+		fmt.Printf("data:     %v\n", hexutil.Encode(*invocation.Data))
+	}
+	if request.Callinfo != nil {
+		fmt.Printf("\nInvocation validation:\n")
+		for _, m := range request.Callinfo {
+			fmt.Printf("  * %s : %s\n", m.Typ, m.Message)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("BTC inputs:\n")
+	for i, input := range request.Inputs {
+		signed := ""
+		if input.Signed {
+			signed = " (signed)"
+		}
+		fmt.Printf("  %d. %v: %v sat%s\n", i, input.PrevOut, input.Amount, signed)
+	}
+	fmt.Printf("BTC outputs:\n")
+	for i, output := range request.Outputs {
+		to := output.Address
+		if to == "" {
+			to = hexutil.Encode(output.Script)
+		}
+		fmt.Printf("  %d. %v: %v sat\n", i, to, output.Amount)
+	}
+	fmt.Printf("fee:      %v sat (unverified, computed from the requested input amounts)\n", request.Fee)
+	fmt.Printf("\nWARNING: The signature does not commit to the invocation, it can be reused\n")
+	fmt.Printf("with any other to-address and data spending the same input.\n\n")
+	showMetadata(request.Meta)
+	fmt.Printf("-------------------------------------------\n")
+	if !ui.confirm() {
+		return SignBevmInvocationResponse{false}, nil
+	}
+	return SignBevmInvocationResponse{true}, nil
+}
+
 // ApproveListing prompt the user for confirmation to list accounts
 // the list of accounts to list can be modified by the UI
 func (ui *CommandlineUI) ApproveListing(request *ListRequest) (ListResponse, error) {
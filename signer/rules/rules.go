@@ -183,6 +183,19 @@ func (r *rulesetUI) ApproveSignData(request *core.SignDataRequest) (core.SignDat
 	return core.SignDataResponse{Approved: false}, err
 }
 
+func (r *rulesetUI) ApproveBevmInvocation(request *core.SignBevmInvocationRequest) (core.SignBevmInvocationResponse, error) {
+	jsonreq, err := json.Marshal(request)
+	approved, err := r.checkApproval("ApproveBevmInvocation", jsonreq, err)
+	if err != nil {
+		log.Info("Rule-based approval error, going to manual", "error", err)
+		return r.next.ApproveBevmInvocation(request)
+	}
+	if approved {
+		return core.SignBevmInvocationResponse{Approved: true}, nil
+	}
+	return core.SignBevmInvocationResponse{Approved: false}, err
+}
+
 // OnInputRequired not handled by rules
 func (r *rulesetUI) OnInputRequired(info core.UserInputRequest) (core.UserInputResponse, error) {
 	return r.next.OnInputRequired(info)
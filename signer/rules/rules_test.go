@@ -92,6 +92,10 @@ func (alwaysDenyUI) ApproveSignData(request *core.SignDataRequest) (core.SignDat
 	return core.SignDataResponse{Approved: false}, nil
 }
 
+func (alwaysDenyUI) ApproveBevmInvocation(request *core.SignBevmInvocationRequest) (core.SignBevmInvocationResponse, error) {
+	return core.SignBevmInvocationResponse{Approved: false}, nil
+}
+
 func (alwaysDenyUI) ApproveListing(request *core.ListRequest) (core.ListResponse, error) {
 	return core.ListResponse{Accounts: nil}, nil
 }
@@ -218,6 +222,11 @@ func (d *dummyUI) ApproveSignData(request *core.SignDataRequest) (core.SignDataR
 	return core.SignDataResponse{}, core.ErrRequestDenied
 }
 
+func (d *dummyUI) ApproveBevmInvocation(request *core.SignBevmInvocationRequest) (core.SignBevmInvocationResponse, error) {
+	d.calls = append(d.calls, "ApproveBevmInvocation")
+	return core.SignBevmInvocationResponse{}, core.ErrRequestDenied
+}
+
 func (d *dummyUI) ApproveListing(request *core.ListRequest) (core.ListResponse, error) {
 	d.calls = append(d.calls, "ApproveListing")
 	return core.ListResponse{}, core.ErrRequestDenied
@@ -257,6 +266,7 @@ func TestForwarding(t *testing.T) {
 		t.Fatalf("Failed to load bootstrap js: %v", err)
 	}
 	r.ApproveSignData(nil)
+	r.ApproveBevmInvocation(nil)
 	r.ApproveTx(nil)
 	r.ApproveNewAccount(nil)
 	r.ApproveListing(nil)
@@ -266,7 +276,7 @@ func TestForwarding(t *testing.T) {
 	//This one is not forwarded
 	r.OnApprovedTx(ethapi.SignTransactionResult{})
 
-	expCalls := 6
+	expCalls := 7
 	if len(ui.calls) != expCalls {
 
 		t.Errorf("Expected %d forwarded calls, got %d: %s", expCalls, len(ui.calls), strings.Join(ui.calls, ","))
@@ -522,6 +532,11 @@ func (d *dontCallMe) ApproveSignData(request *core.SignDataRequest) (core.SignDa
 	return core.SignDataResponse{}, core.ErrRequestDenied
 }
 
+func (d *dontCallMe) ApproveBevmInvocation(request *core.SignBevmInvocationRequest) (core.SignBevmInvocationResponse, error) {
+	d.t.Fatalf("Did not expect next-handler to be called")
+	return core.SignBevmInvocationResponse{}, core.ErrRequestDenied
+}
+
 func (d *dontCallMe) ApproveListing(request *core.ListRequest) (core.ListResponse, error) {
 	d.t.Fatalf("Did not expect next-handler to be called")
 	return core.ListResponse{}, core.ErrRequestDenied
@@ -626,3 +641,41 @@ function ApproveSignData(r){
 		t.Fatalf("Expected approved")
 	}
 }
+
+func TestApproveBevmInvocation(t *testing.T) {
+	js := `function ApproveBevmInvocation(r){
+    if(r.invocation.to.toLowerCase() != "0x694267f14675d7e1b9494fd8d72fefe1755710fa"){
+        return "Reject"
+    }
+    if(r.fee > 10000){
+        return "Reject"
+    }
+    return "Approve"
+}`
+	r, err := initRuleEngine(js)
+	if err != nil {
+		t.Fatalf("Couldn't create evaluator %v", err)
+	}
+	to, _ := mixAddr("0x694267f14675d7e1b9494fd8d72fefe1755710fa")
+	req := &core.SignBevmInvocationRequest{
+		Invocation: core.BevmInvocationArgs{From: *to, To: to},
+		Inputs:     []core.BevmTxInput{{PrevOut: "0000000000000000000000000000000000000000000000000000000000000000:0", Amount: 20000, Signed: true}},
+		Outputs:    []core.BevmTxOutput{{Amount: 15000}},
+		Fee:        5000,
+	}
+	resp, err := r.ApproveBevmInvocation(req)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !resp.Approved {
+		t.Fatalf("Expected approved")
+	}
+	req.Fee = 20000
+	resp, err = r.ApproveBevmInvocation(req)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if resp.Approved {
+		t.Fatalf("Expected rejected")
+	}
+}